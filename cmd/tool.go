@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/charlieegan3/toolbelt/pkg/database"
@@ -69,31 +70,23 @@ func main() {
 		if err != nil {
 			log.Fatalf("failed to get jobs: %v", err)
 		}
+		jobs = append(jobs, mt.ManualJobs()...)
 
-		switch os.Args[1] {
-		case "from_export":
-			err = jobs[0].Run(ctx)
-			if err != nil {
-				log.Fatalf("failed to run job: %v", err)
-			}
-		case "activity_poll":
-			err = jobs[1].Run(ctx)
-			if err != nil {
-				log.Fatalf("failed to run job: %v", err)
+		// jobs are selected by name, e.g. activity_sync runs activity-sync
+		jobName := strings.ReplaceAll(os.Args[1], "_", "-")
+		for _, job := range jobs {
+			if job.Name() != jobName {
+				continue
 			}
-		case "activity_sync":
-			err = jobs[2].Run(ctx)
-			if err != nil {
-				log.Fatalf("failed to run job: %v", err)
-			}
-		case "activity_original":
-			err = jobs[3].Run(ctx)
+
+			err = job.Run(ctx)
 			if err != nil {
 				log.Fatalf("failed to run job: %v", err)
 			}
+			os.Exit(0)
 		}
 
-		os.Exit(0)
+		log.Fatalf("unknown job: %s", os.Args[1])
 	}
 
 	// go tb.RunJobs(ctx)
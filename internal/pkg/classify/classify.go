@@ -0,0 +1,299 @@
+// Package classify sorts activities into commutes, indoor activities and
+// virtual activities with simple rules, so training can be reported on
+// separately from commuting
+package classify
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/config"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// StationaryRadius in metres is how far from the first position all the
+// positions of an activity can be for it to be treated as indoors, GPS
+// drifts a little even when a device is not moving
+const StationaryRadius = 100.0
+
+// indoorSubSports are the FIT sub sports recorded on trainers, treadmills
+// and other indoor equipment
+var indoorSubSports = map[string]bool{
+	"treadmill":      true,
+	"spin":           true,
+	"indoor_cycling": true,
+	"indoor_rowing":  true,
+	"indoor_skiing":  true,
+	"indoor_walking": true,
+	"indoor_running": true,
+	"elliptical":     true,
+	"stair_climbing": true,
+}
+
+// virtualManufacturers are the FIT manufacturers of apps which ride or run
+// in a virtual world
+var virtualManufacturers = map[string]bool{
+	"zwift": true,
+}
+
+// Place is a circle around a location commutes start or end at
+type Place struct {
+	Name   string
+	Center geo.Point
+	// Radius is in metres
+	Radius float64
+}
+
+// Contains returns true if the point is inside the place
+func (p Place) Contains(point geo.Point) bool {
+	return geo.Distance(p.Center, point) <= p.Radius
+}
+
+// Window is a time of day in which commutes start, From and To are minutes
+// since midnight and To is exclusive. Days limits the window to some days
+// of the week, all days are included when empty.
+type Window struct {
+	Days []time.Weekday
+	From int
+	To   int
+}
+
+// Contains returns true if the wall clock time is inside the window
+func (w Window) Contains(t time.Time) bool {
+	if len(w.Days) > 0 {
+		found := false
+		for _, d := range w.Days {
+			if d == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	return minute >= w.From && minute < w.To
+}
+
+// Rules are the places and times commutes are made between. A nil Rules
+// still classifies indoor and virtual activities.
+type Rules struct {
+	Places  []Place
+	Windows []Window
+	// WriteBack sets the commute flag on Strava for activities classified
+	// as commutes
+	WriteBack bool
+}
+
+// ParseConfig reads the classification section of the tool config, which
+// has a list of places with a name, lat, lon and radius, a list of commute
+// windows with from and to times such as "07:30" and optional days such as
+// "mon", and write_back to set the commute flag on Strava. Commutes are
+// only found when there are at least two places, and at any time when
+// there are no windows. Nil is returned when the section is missing.
+func ParseConfig(data any) (*Rules, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	section, ok := data.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("classification config must be a map")
+	}
+
+	var r Rules
+	r.WriteBack, _ = section["write_back"].(bool)
+
+	places, _ := section["places"].([]any)
+	for i, p := range places {
+		place, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("classification place %d must be a map", i)
+		}
+
+		name, _ := place["name"].(string)
+		lat, latOK := config.Float(place["lat"])
+		lon, lonOK := config.Float(place["lon"])
+		radius, radiusOK := config.Float(place["radius"])
+		if name == "" || !latOK || !lonOK || !radiusOK || radius <= 0 {
+			return nil, fmt.Errorf("classification place %d must have a name, lat, lon and positive radius", i)
+		}
+
+		r.Places = append(r.Places, Place{
+			Name:   name,
+			Center: geo.Point{Lat: lat, Lon: lon},
+			Radius: radius,
+		})
+	}
+
+	windows, _ := section["commute_windows"].([]any)
+	for i, w := range windows {
+		window, ok := w.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("commute window %d must be a map", i)
+		}
+
+		from, fromOK := parseTimeOfDay(window["from"])
+		to, toOK := parseTimeOfDay(window["to"])
+		if !fromOK || !toOK || to <= from {
+			return nil, fmt.Errorf("commute window %d must have a from and later to time such as 07:30", i)
+		}
+
+		days, _ := window["days"].([]any)
+		var weekdays []time.Weekday
+		for _, d := range days {
+			day, ok := parseWeekday(d)
+			if !ok {
+				return nil, fmt.Errorf("commute window %d has unknown day %v", i, d)
+			}
+			weekdays = append(weekdays, day)
+		}
+
+		r.Windows = append(r.Windows, Window{Days: weekdays, From: from, To: to})
+	}
+
+	return &r, nil
+}
+
+func parseTimeOfDay(v any) (int, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func parseWeekday(v any) (time.Weekday, bool) {
+	s, _ := v.(string)
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if len(s) >= 3 && strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Activity is what an activity is classified from
+type Activity struct {
+	// Type is the Strava activity type
+	Type string
+	// Start is the local wall clock start time, zero when not known
+	Start time.Time
+	// Positions are the route's points, empty when it has no GPS
+	Positions []geo.Point
+
+	// SubSport and Manufacturer are the names from the original FIT file,
+	// empty for other formats
+	SubSport     string
+	Manufacturer string
+}
+
+// Class is the result of classifying an activity. Virtual activities are
+// also indoor, and indoor activities are never commutes. Reason explains
+// the rule which matched, empty when none did.
+type Class struct {
+	Commute bool
+	Indoor  bool
+	Virtual bool
+	Reason  string
+}
+
+// Classify applies the rules to the activity
+func (r *Rules) Classify(a Activity) Class {
+	switch {
+	case strings.HasPrefix(a.Type, "Virtual"):
+		return Class{Indoor: true, Virtual: true, Reason: "virtual activity type " + a.Type}
+	case a.SubSport == "virtual_activity":
+		return Class{Indoor: true, Virtual: true, Reason: "virtual sub sport"}
+	case virtualManufacturers[a.Manufacturer]:
+		return Class{Indoor: true, Virtual: true, Reason: "recorded by " + a.Manufacturer}
+	case indoorSubSports[a.SubSport]:
+		return Class{Indoor: true, Reason: "indoor sub sport " + a.SubSport}
+	case len(a.Positions) == 0:
+		return Class{Indoor: true, Reason: "no GPS"}
+	case stationary(a.Positions):
+		return Class{Indoor: true, Reason: "stationary"}
+	}
+
+	if from, to, ok := r.commute(a); ok {
+		return Class{Commute: true, Reason: fmt.Sprintf("commute from %s to %s", from, to)}
+	}
+
+	return Class{}
+}
+
+// commute returns the places the activity started and ended in when they
+// are different places and it started in a commute window
+func (r *Rules) commute(a Activity) (string, string, bool) {
+	if r == nil || len(r.Places) < 2 {
+		return "", "", false
+	}
+
+	if len(r.Windows) > 0 {
+		if a.Start.IsZero() {
+			return "", "", false
+		}
+		inWindow := false
+		for _, w := range r.Windows {
+			if w.Contains(a.Start) {
+				inWindow = true
+				break
+			}
+		}
+		if !inWindow {
+			return "", "", false
+		}
+	}
+
+	from := r.placeOf(a.Positions[0])
+	to := r.placeOf(a.Positions[len(a.Positions)-1])
+	if from == "" || to == "" || from == to {
+		return "", "", false
+	}
+
+	return from, to, true
+}
+
+// placeOf returns the name of the first place containing the point
+func (r *Rules) placeOf(point geo.Point) string {
+	for _, p := range r.Places {
+		if p.Contains(point) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// Digest identifies the rules, it changes when the places or windows change
+// so activities classified under old rules can be classified again
+func (r *Rules) Digest() string {
+	h := fnv.New64a()
+	if r != nil {
+		for _, p := range r.Places {
+			fmt.Fprintf(h, "place:%s,%g,%g,%g;", p.Name, p.Center.Lat, p.Center.Lon, p.Radius)
+		}
+		for _, w := range r.Windows {
+			fmt.Fprintf(h, "window:%v,%d,%d;", w.Days, w.From, w.To)
+		}
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func stationary(positions []geo.Point) bool {
+	for _, p := range positions[1:] {
+		if geo.Distance(positions[0], p) > StationaryRadius {
+			return false
+		}
+	}
+	return true
+}
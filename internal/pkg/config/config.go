@@ -0,0 +1,39 @@
+// Package config reads values from sections of the tool config, which are
+// decoded from YAML so numbers may be ints or floats and dates may be
+// strings or times
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Float returns a number from the config as a float, false is returned when
+// the value is not a number
+func Float(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Date returns a date from the config, which is either a time or a string
+// such as 2023-01-31. Name is the key of the value, used in the error.
+func Date(v any, name string) (time.Time, error) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, nil
+	case string:
+		t, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s must be a date such as 2023-01-31", name)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date such as 2023-01-31", name)
+}
@@ -0,0 +1,183 @@
+// Package duplicates finds activities which record the same workout, such
+// as one uploaded from both a watch and a bike computer, by how much their
+// times overlap and how similar their routes are
+package duplicates
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// MinOverlap is the fraction of the longer activity which must overlap
+	// the shorter one, so activities must start at around the same time and
+	// last around as long as each other
+	MinOverlap = 0.75
+	// MinSimilarity is the fraction of route cells which must be shared
+	// when both activities have a route
+	MinSimilarity = 0.5
+)
+
+// Activity is the timing and route of an activity
+type Activity struct {
+	ID       string
+	Start    time.Time
+	Duration time.Duration
+	// Cells are the route cells the activity passes through, empty when it
+	// has no route such as indoor activities
+	Cells []string
+}
+
+// End returns the time the activity finished
+func (a Activity) End() time.Time {
+	return a.Start.Add(a.Duration)
+}
+
+// Pair is two activities with overlapping times
+type Pair struct {
+	A, B    string
+	Overlap float64
+	// Similarity is nil when either activity has no route
+	Similarity *float64
+}
+
+// Duplicate returns true when the pair is likely to be the same workout
+func (p Pair) Duplicate() bool {
+	if p.Overlap < MinOverlap {
+		return false
+	}
+	return p.Similarity == nil || *p.Similarity >= MinSimilarity
+}
+
+// Overlap returns the time both activities were in progress as a fraction
+// of the longer activity
+func Overlap(a, b Activity) float64 {
+	start, end := a.Start, a.End()
+	if b.Start.After(start) {
+		start = b.Start
+	}
+	if b.End().Before(end) {
+		end = b.End()
+	}
+	if !end.After(start) {
+		return 0
+	}
+
+	longest := a.Duration
+	if b.Duration > longest {
+		longest = b.Duration
+	}
+
+	return float64(end.Sub(start)) / float64(longest)
+}
+
+// Similarity returns the Jaccard index of the route cells of the activities
+// and false when either has no route
+func Similarity(a, b Activity) (float64, bool) {
+	if len(a.Cells) == 0 || len(b.Cells) == 0 {
+		return 0, false
+	}
+
+	cells := make(map[string]bool, len(a.Cells))
+	for _, c := range a.Cells {
+		cells[c] = true
+	}
+
+	shared := 0
+	union := len(cells)
+	seen := make(map[string]bool, len(b.Cells))
+	for _, c := range b.Cells {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+
+		if cells[c] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	return float64(shared) / float64(union), true
+}
+
+// OverlappingPairs returns the pairs of activities which overlap by at
+// least MinOverlap. Routes are not compared, so cells need only be loaded
+// for the activities in the returned pairs.
+func OverlappingPairs(activities []Activity) []Pair {
+	sorted := make([]Activity, len(activities))
+	copy(sorted, activities)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	var pairs []Pair
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			// later activities start after this one has finished
+			if !b.Start.Before(a.End()) {
+				break
+			}
+
+			overlap := Overlap(a, b)
+			if overlap < MinOverlap {
+				continue
+			}
+
+			pairs = append(pairs, Pair{A: a.ID, B: b.ID, Overlap: overlap})
+		}
+	}
+
+	return pairs
+}
+
+// Groups returns the IDs of the activities in each group of duplicates,
+// where activities are grouped when they are the duplicate of any activity
+// in the group. IDs are sorted within groups and groups by their first ID.
+func Groups(pairs []Pair) [][]string {
+	parents := make(map[string]string)
+
+	var find func(id string) string
+	find = func(id string) string {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			parents[id] = id
+			return id
+		}
+		root := find(parent)
+		parents[id] = root
+		return root
+	}
+
+	for _, p := range pairs {
+		if !p.Duplicate() {
+			continue
+		}
+
+		a, b := find(p.A), find(p.B)
+		if a != b {
+			parents[b] = a
+		}
+	}
+
+	members := make(map[string][]string)
+	for id := range parents {
+		root := find(id)
+		members[root] = append(members[root], id)
+	}
+
+	var groups [][]string
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		groups = append(groups, ids)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0] < groups[j][0]
+	})
+
+	return groups
+}
@@ -0,0 +1,187 @@
+// Package efforts finds the best efforts within an activity, the highest
+// average power over fixed durations and the fastest time over fixed
+// distances
+package efforts
+
+import (
+	"math"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// PowerDurations are the durations in seconds of the power curve, from one
+// second to five hours
+var PowerDurations = []int{
+	1, 5, 10, 15, 20, 30, 60, 120, 180, 300, 480, 600, 1200, 1800,
+	2700, 3600, 5400, 7200, 10800, 14400, 18000,
+}
+
+// Distance is a distance for best efforts
+type Distance struct {
+	Name   string
+	Metres float64
+}
+
+// RunningDistances are the distances of running best efforts
+var RunningDistances = []Distance{
+	{Name: "400m", Metres: 400},
+	{Name: "1k", Metres: 1000},
+	{Name: "5k", Metres: 5000},
+	{Name: "10k", Metres: 10000},
+	{Name: "half_marathon", Metres: 21097.5},
+	{Name: "marathon", Metres: 42195},
+}
+
+// maxPowerGap is the longest gap between points for which the power of the
+// earlier point is carried forward, longer gaps count as zero power
+const maxPowerGap = 5 * time.Second
+
+// PowerSeries returns the power for each second from the first point with
+// power to the last. Power is resampled to one second intervals so
+// recordings with smart recording or gaps are treated the same as those
+// recorded every second. False is returned when no point has power.
+func PowerSeries(points []track.Point) ([]float64, bool) {
+	var start, end time.Time
+	found := false
+	for _, p := range points {
+		if p.Power == nil {
+			continue
+		}
+		if !found {
+			start = p.Time
+			found = true
+		}
+		end = p.Time
+	}
+	if !found {
+		return nil, false
+	}
+
+	watts := make([]float64, int(end.Sub(start)/time.Second)+1)
+	for i, p := range points {
+		if p.Power == nil || p.Time.Before(start) {
+			continue
+		}
+
+		from := int(p.Time.Sub(start) / time.Second)
+		to := from + 1
+		if i < len(points)-1 && points[i+1].Time.Sub(p.Time) <= maxPowerGap {
+			to = int(points[i+1].Time.Sub(start) / time.Second)
+		}
+
+		for s := from; s < to && s < len(watts); s++ {
+			watts[s] = *p.Power
+		}
+	}
+
+	return watts, true
+}
+
+// PowerCurve returns the highest average power for each duration in
+// PowerDurations which fits in the activity
+func PowerCurve(points []track.Point) map[int]float64 {
+	watts, ok := PowerSeries(points)
+	if !ok {
+		return nil
+	}
+
+	sums := make([]float64, len(watts)+1)
+	for i, w := range watts {
+		sums[i+1] = sums[i] + w
+	}
+
+	curve := make(map[int]float64)
+	for _, d := range PowerDurations {
+		if d > len(watts) {
+			break
+		}
+
+		best := 0.0
+		for i := d; i < len(sums); i++ {
+			best = math.Max(best, sums[i]-sums[i-d])
+		}
+		curve[d] = best / float64(d)
+	}
+
+	return curve
+}
+
+// Effort is the fastest time over a distance
+type Effort struct {
+	Distance Distance
+	Start    time.Time
+	Seconds  float64
+}
+
+// BestEfforts returns the fastest time over each distance which fits in the
+// activity, using the distance recorded with each point. The start and end
+// of each effort are interpolated between points so the times are for the
+// exact distance.
+func BestEfforts(points []track.Point, distances []Distance) []Effort {
+	type sample struct {
+		time     time.Time
+		distance float64
+	}
+
+	var samples []sample
+	for _, p := range points {
+		if p.Distance == nil {
+			continue
+		}
+		if len(samples) > 0 && *p.Distance < samples[len(samples)-1].distance {
+			continue
+		}
+		samples = append(samples, sample{time: p.Time, distance: *p.Distance})
+	}
+	if len(samples) < 2 {
+		return nil
+	}
+
+	// timeAt returns the time the distance was reached between samples i and
+	// i+1
+	timeAt := func(i int, distance float64) time.Time {
+		a, b := samples[i], samples[i+1]
+		if b.distance == a.distance {
+			return a.time
+		}
+		f := (distance - a.distance) / (b.distance - a.distance)
+		return a.time.Add(time.Duration(f * float64(b.time.Sub(a.time))))
+	}
+
+	total := samples[len(samples)-1].distance - samples[0].distance
+
+	var efforts []Effort
+	for _, d := range distances {
+		if d.Metres > total {
+			continue
+		}
+
+		var best *Effort
+
+		// for each starting sample, find the first sample at which the
+		// distance has been covered, the end moves forward with the start
+		end := 1
+		for start := 0; start < len(samples)-1; start++ {
+			target := samples[start].distance + d.Metres
+			for end < len(samples) && samples[end].distance < target {
+				end++
+			}
+			if end == len(samples) {
+				break
+			}
+
+			finish := timeAt(end-1, target)
+			seconds := finish.Sub(samples[start].time).Seconds()
+			if best == nil || seconds < best.Seconds {
+				best = &Effort{Distance: d, Start: samples[start].time, Seconds: seconds}
+			}
+		}
+
+		if best != nil {
+			efforts = append(efforts, *best)
+		}
+	}
+
+	return efforts
+}
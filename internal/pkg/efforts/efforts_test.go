@@ -0,0 +1,159 @@
+package efforts
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+var start = time.Date(2023, time.June, 1, 8, 0, 0, 0, time.UTC)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// powerPoints returns a point with power at each offset in seconds
+func powerPoints(watts map[int]float64) []track.Point {
+	var offsets []int
+	for s := range watts {
+		offsets = append(offsets, s)
+	}
+	sort.Ints(offsets)
+
+	var points []track.Point
+	for _, s := range offsets {
+		points = append(points, track.Point{
+			Time:  start.Add(time.Duration(s) * time.Second),
+			Power: float(watts[s]),
+		})
+	}
+	return points
+}
+
+func TestPowerCurve(t *testing.T) {
+	steady := make(map[int]float64)
+	for s := 0; s <= 10; s++ {
+		steady[s] = 200
+	}
+
+	step := make(map[int]float64)
+	for s := 0; s < 10; s++ {
+		step[s] = 100
+		if s >= 5 {
+			step[s] = 300
+		}
+	}
+
+	testCases := map[string]struct {
+		points   []track.Point
+		expected map[int]float64
+	}{
+		"no power": {
+			points:   []track.Point{{Time: start}, {Time: start.Add(time.Second)}},
+			expected: nil,
+		},
+		"steady power": {
+			points:   powerPoints(steady),
+			expected: map[int]float64{1: 200, 5: 200, 10: 200},
+		},
+		"step in power": {
+			points:   powerPoints(step),
+			expected: map[int]float64{1: 300, 5: 300, 10: 200},
+		},
+		"short gap is carried forward": {
+			points:   powerPoints(map[int]float64{0: 200, 3: 200}),
+			expected: map[int]float64{1: 200},
+		},
+		"long gap is zero": {
+			points:   powerPoints(map[int]float64{0: 100, 10: 100}),
+			expected: map[int]float64{1: 100, 5: 20, 10: 10},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			curve := PowerCurve(tc.points)
+			if !reflect.DeepEqual(curve, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, curve)
+			}
+		})
+	}
+}
+
+// distancePoints returns points every interval seconds at the speed in
+// metres per second for each section of seconds
+func distancePoints(interval int, sections ...[2]float64) []track.Point {
+	points := []track.Point{{Time: start, Distance: float(0)}}
+	var seconds int
+	var distance float64
+	for _, section := range sections {
+		duration, speed := int(section[0]), section[1]
+		for s := interval; s <= duration; s += interval {
+			seconds += interval
+			distance += speed * float64(interval)
+			points = append(points, track.Point{
+				Time:     start.Add(time.Duration(seconds) * time.Second),
+				Distance: float(distance),
+			})
+		}
+	}
+	return points
+}
+
+func TestBestEfforts(t *testing.T) {
+	distances := []Distance{
+		{Name: "400m", Metres: 400},
+		{Name: "1k", Metres: 1000},
+		{Name: "5k", Metres: 5000},
+	}
+
+	testCases := map[string]struct {
+		points   []track.Point
+		expected []Effort
+	}{
+		"no distance": {
+			points:   []track.Point{{Time: start}, {Time: start.Add(time.Second)}},
+			expected: nil,
+		},
+		"steady pace": {
+			points: distancePoints(1, [2]float64{300, 4}),
+			expected: []Effort{
+				{Distance: distances[0], Start: start, Seconds: 100},
+				{Distance: distances[1], Start: start, Seconds: 250},
+			},
+		},
+		"faster second half": {
+			points: distancePoints(1, [2]float64{100, 2}, [2]float64{200, 5}),
+			expected: []Effort{
+				{Distance: distances[0], Start: start.Add(100 * time.Second), Seconds: 80},
+				{Distance: distances[1], Start: start.Add(100 * time.Second), Seconds: 200},
+			},
+		},
+		"interpolated between points": {
+			points: distancePoints(10, [2]float64{400, 3}),
+			expected: []Effort{
+				{Distance: distances[0], Start: start, Seconds: 400.0 / 3},
+				{Distance: distances[1], Start: start, Seconds: 1000.0 / 3},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			efforts := BestEfforts(tc.points, distances)
+			if len(efforts) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, efforts)
+			}
+			for i, e := range tc.expected {
+				got := efforts[i]
+				if got.Distance != e.Distance || !got.Start.Equal(e.Start) || math.Abs(got.Seconds-e.Seconds) > 1e-6 {
+					t.Fatalf("expected %v, got %v", e, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package explorer computes the metrics of the explorer tiles game, where
+// the aim is to visit as many zoom 14 map tiles as possible
+package explorer
+
+import (
+	"sort"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+)
+
+// Zoom is the zoom level of explorer tiles
+const Zoom = 14
+
+// Square is a square block of visited tiles, X and Y are the top left tile
+type Square struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Size int `json:"size"`
+}
+
+// Contains returns true if the tile is inside the square
+func (s Square) Contains(t tiles.Tile) bool {
+	return s.Size > 0 &&
+		t.X >= s.X && t.X < s.X+s.Size &&
+		t.Y >= s.Y && t.Y < s.Y+s.Size
+}
+
+// Stats are the explorer metrics for a set of visited tiles
+type Stats struct {
+	Total int `json:"total"`
+	// MaxSquare is the largest square of visited tiles
+	MaxSquare Square `json:"max_square"`
+	// ClusterTiles are the visited tiles whose four neighbours have also
+	// been visited
+	ClusterTiles int `json:"cluster_tiles"`
+	// MaxCluster is the size of the largest group of connected cluster
+	// tiles
+	MaxCluster int `json:"max_cluster"`
+}
+
+// Result holds the stats and which tiles contribute to them
+type Result struct {
+	Stats
+
+	// Cluster contains the cluster tiles, with true for those in the max
+	// cluster
+	Cluster map[tiles.Tile]bool
+}
+
+// Compute returns the explorer metrics for the visited tiles
+func Compute(visited []tiles.Tile) Result {
+	result := Result{
+		Stats:   Stats{Total: len(visited)},
+		Cluster: make(map[tiles.Tile]bool),
+	}
+
+	seen := make(map[tiles.Tile]bool, len(visited))
+	for _, t := range visited {
+		seen[t] = true
+	}
+
+	result.MaxSquare = maxSquare(seen)
+
+	neighbours := func(t tiles.Tile) []tiles.Tile {
+		return []tiles.Tile{
+			{Z: t.Z, X: t.X - 1, Y: t.Y},
+			{Z: t.Z, X: t.X + 1, Y: t.Y},
+			{Z: t.Z, X: t.X, Y: t.Y - 1},
+			{Z: t.Z, X: t.X, Y: t.Y + 1},
+		}
+	}
+
+	for t := range seen {
+		surrounded := true
+		for _, n := range neighbours(t) {
+			if !seen[n] {
+				surrounded = false
+				break
+			}
+		}
+		if surrounded {
+			result.Cluster[t] = false
+		}
+	}
+	result.ClusterTiles = len(result.Cluster)
+
+	// find the largest connected group of cluster tiles with a flood fill
+	grouped := make(map[tiles.Tile]bool)
+	var largest []tiles.Tile
+	for t := range result.Cluster {
+		if grouped[t] {
+			continue
+		}
+
+		var group []tiles.Tile
+		queue := []tiles.Tile{t}
+		grouped[t] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			group = append(group, current)
+
+			for _, n := range neighbours(current) {
+				if _, ok := result.Cluster[n]; ok && !grouped[n] {
+					grouped[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+
+		if len(group) > len(largest) {
+			largest = group
+		}
+	}
+
+	result.MaxCluster = len(largest)
+	for _, t := range largest {
+		result.Cluster[t] = true
+	}
+
+	return result
+}
+
+// maxSquare finds the largest square of visited tiles. For each tile the
+// size of the largest square with that tile as the bottom right corner is
+// one more than the smallest of the squares ending at its left, top and
+// top left neighbours, so tiles are visited top to bottom, left to right.
+func maxSquare(seen map[tiles.Tile]bool) Square {
+	ordered := make([]tiles.Tile, 0, len(seen))
+	for t := range seen {
+		ordered = append(ordered, t)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].Y != ordered[j].Y {
+			return ordered[i].Y < ordered[j].Y
+		}
+		return ordered[i].X < ordered[j].X
+	})
+
+	sizes := make(map[tiles.Tile]int, len(seen))
+
+	var best Square
+	for _, t := range ordered {
+		s := 1 + minInt(
+			sizes[tiles.Tile{Z: t.Z, X: t.X - 1, Y: t.Y}],
+			minInt(
+				sizes[tiles.Tile{Z: t.Z, X: t.X, Y: t.Y - 1}],
+				sizes[tiles.Tile{Z: t.Z, X: t.X - 1, Y: t.Y - 1}],
+			),
+		)
+		sizes[t] = s
+
+		if s > best.Size {
+			best = Square{X: t.X - s + 1, Y: t.Y - s + 1, Size: s}
+		}
+	}
+
+	return best
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,487 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+type fieldDefinition struct {
+	num      byte
+	size     byte
+	baseType byte
+}
+
+type messageDefinition struct {
+	byteOrder   binary.ByteOrder
+	globalNum   uint16
+	fields      []fieldDefinition
+	devDataSize int
+}
+
+// message is a decoded data message. Numeric fields are held as float64
+// before scaling, invalid values are omitted.
+type message struct {
+	values  map[byte]float64
+	strings map[byte]string
+}
+
+func (m *message) float(num byte, scale, offset float64) (float64, bool) {
+	v, ok := m.values[num]
+	if !ok {
+		return 0, false
+	}
+	return v/scale - offset, true
+}
+
+func (m *message) floatOrZero(num byte, scale, offset float64) float64 {
+	v, _ := m.float(num, scale, offset)
+	return v
+}
+
+func (m *message) floatPtr(num byte, scale, offset float64) *float64 {
+	v, ok := m.float(num, scale, offset)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func (m *message) uint8(num byte) uint8 {
+	return uint8(m.values[num])
+}
+
+func (m *message) uint16(num byte) uint16 {
+	return uint16(m.values[num])
+}
+
+func (m *message) uint32(num byte) uint32 {
+	return uint32(m.values[num])
+}
+
+func (m *message) time(num byte) time.Time {
+	v, ok := m.values[num]
+	if !ok || v < minAbsoluteTimestamp {
+		return time.Time{}
+	}
+	return fitEpoch.Add(time.Duration(v) * time.Second)
+}
+
+func (m *message) position(latNum, lonNum byte) *Position {
+	lat, okLat := m.values[latNum]
+	lon, okLon := m.values[lonNum]
+	if !okLat || !okLon {
+		return nil
+	}
+	return &Position{
+		Lat: SemicirclesToDegrees(int32(lat)),
+		Lon: SemicirclesToDegrees(int32(lon)),
+	}
+}
+
+// SemicirclesToDegrees converts a FIT semicircle value to degrees
+func SemicirclesToDegrees(semicircles int32) float64 {
+	return float64(semicircles) * (180.0 / math.Pow(2, 31))
+}
+
+// Decode parses a FIT file. Chained FIT files are supported and the
+// messages from each are concatenated.
+func Decode(data []byte) (*File, error) {
+	f := &File{}
+
+	pos := 0
+	for pos < len(data) {
+		n, err := decodeChunk(f, data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		// some devices pad the end of the file, only continue when another
+		// header follows
+		if len(data)-pos < 12 || string(data[pos+8:pos+12]) != ".FIT" {
+			break
+		}
+	}
+
+	return f, nil
+}
+
+// decodeChunk decodes a single FIT file from the start of data and returns
+// the number of bytes consumed, including the trailing CRC.
+func decodeChunk(f *File, data []byte) (int, error) {
+	if len(data) < 12 {
+		return 0, fmt.Errorf("file too short for FIT header")
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize {
+		return 0, fmt.Errorf("invalid FIT header size %d", headerSize)
+	}
+	if string(data[8:12]) != ".FIT" {
+		return 0, fmt.Errorf("missing FIT signature")
+	}
+
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if end > len(data) {
+		return 0, fmt.Errorf("FIT data size %d exceeds file length", dataSize)
+	}
+
+	d := decoder{
+		data: data[:end],
+		pos:  headerSize,
+		file: f,
+	}
+	err := d.decode()
+	if err != nil {
+		return 0, err
+	}
+
+	// 2 byte CRC follows the data records
+	end += 2
+	if end > len(data) {
+		end = len(data)
+	}
+
+	return end, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	file *File
+
+	definitions   [16]*messageDefinition
+	lastTimestamp uint32
+}
+
+func (d *decoder) decode() error {
+	for d.pos < len(d.data) {
+		header := d.data[d.pos]
+		d.pos++
+
+		// compressed timestamp header
+		if header&0x80 != 0 {
+			localNum := (header >> 5) & 0x03
+			offset := uint32(header & 0x1f)
+
+			timestamp := (d.lastTimestamp &^ 0x1f) + offset
+			if offset < d.lastTimestamp&0x1f {
+				timestamp += 0x20
+			}
+			d.lastTimestamp = timestamp
+
+			err := d.decodeData(localNum, &timestamp)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		localNum := header & 0x0f
+		if header&0x40 != 0 {
+			err := d.decodeDefinition(localNum, header&0x20 != 0)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := d.decodeData(localNum, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("unexpected end of FIT data at offset %d", d.pos)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) decodeDefinition(localNum byte, hasDevData bool) error {
+	b, err := d.read(5)
+	if err != nil {
+		return err
+	}
+
+	def := &messageDefinition{byteOrder: binary.LittleEndian}
+	if b[1] == 1 {
+		def.byteOrder = binary.BigEndian
+	}
+	def.globalNum = def.byteOrder.Uint16(b[2:4])
+
+	numFields := int(b[4])
+	b, err = d.read(numFields * 3)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numFields; i++ {
+		def.fields = append(def.fields, fieldDefinition{
+			num:      b[i*3],
+			size:     b[i*3+1],
+			baseType: b[i*3+2],
+		})
+	}
+
+	// developer fields are not decoded but their size is needed to skip them
+	if hasDevData {
+		b, err = d.read(1)
+		if err != nil {
+			return err
+		}
+		numDevFields := int(b[0])
+		b, err = d.read(numDevFields * 3)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < numDevFields; i++ {
+			def.devDataSize += int(b[i*3+1])
+		}
+	}
+
+	d.definitions[localNum] = def
+
+	return nil
+}
+
+func (d *decoder) decodeData(localNum byte, compressedTimestamp *uint32) error {
+	def := d.definitions[localNum]
+	if def == nil {
+		return fmt.Errorf("data message for undefined local message type %d at offset %d", localNum, d.pos)
+	}
+
+	m := message{
+		values:  make(map[byte]float64),
+		strings: make(map[byte]string),
+	}
+
+	for _, field := range def.fields {
+		b, err := d.read(int(field.size))
+		if err != nil {
+			return err
+		}
+
+		if field.baseType&0x1f == baseString {
+			// strings are null terminated and may be padded
+			s := string(b)
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				s = string(b[:i])
+			}
+			if s != "" {
+				m.strings[field.num] = s
+			}
+			continue
+		}
+
+		v, ok := decodeValue(b, field.baseType, def.byteOrder)
+		if ok {
+			m.values[field.num] = v
+		}
+	}
+
+	_, err := d.read(def.devDataSize)
+	if err != nil {
+		return err
+	}
+
+	if compressedTimestamp != nil {
+		m.values[fieldTimestamp] = float64(*compressedTimestamp)
+	} else if ts, ok := m.values[fieldTimestamp]; ok {
+		d.lastTimestamp = uint32(ts)
+	}
+
+	d.handle(def.globalNum, &m)
+
+	return nil
+}
+
+// decodeValue decodes the first value of a field, returning false if the
+// value is the invalid value for the base type.
+func decodeValue(b []byte, baseType byte, byteOrder binary.ByteOrder) (float64, bool) {
+	size := baseTypeSize(baseType)
+	if len(b) < size {
+		return 0, false
+	}
+
+	switch baseType & 0x1f {
+	case baseEnum, baseUint8, baseByte:
+		return float64(b[0]), b[0] != 0xff
+	case baseUint8z:
+		return float64(b[0]), b[0] != 0
+	case baseSint8:
+		return float64(int8(b[0])), b[0] != 0x7f
+	case baseUint16:
+		v := byteOrder.Uint16(b)
+		return float64(v), v != 0xffff
+	case baseUint16z:
+		v := byteOrder.Uint16(b)
+		return float64(v), v != 0
+	case baseSint16:
+		v := byteOrder.Uint16(b)
+		return float64(int16(v)), v != 0x7fff
+	case baseUint32:
+		v := byteOrder.Uint32(b)
+		return float64(v), v != 0xffffffff
+	case baseUint32z:
+		v := byteOrder.Uint32(b)
+		return float64(v), v != 0
+	case baseSint32:
+		v := byteOrder.Uint32(b)
+		return float64(int32(v)), v != 0x7fffffff
+	case baseUint64:
+		v := byteOrder.Uint64(b)
+		return float64(v), v != 0xffffffffffffffff
+	case baseUint64z:
+		v := byteOrder.Uint64(b)
+		return float64(v), v != 0
+	case baseSint64:
+		v := byteOrder.Uint64(b)
+		return float64(int64(v)), v != 0x7fffffffffffffff
+	case baseFloat32:
+		v := math.Float32frombits(byteOrder.Uint32(b))
+		return float64(v), !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+	case baseFloat64:
+		v := math.Float64frombits(byteOrder.Uint64(b))
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+
+	return 0, false
+}
+
+func (d *decoder) handle(globalNum uint16, m *message) {
+	switch globalNum {
+	case mesgFileID:
+		d.file.FileID = FileID{
+			Type:         m.uint8(0),
+			Manufacturer: m.uint16(1),
+			Product:      m.uint16(2),
+			SerialNumber: m.uint32(3),
+			TimeCreated:  m.time(4),
+			ProductName:  m.strings[8],
+		}
+	case mesgActivity:
+		d.file.Activity = &Activity{
+			Timestamp:      m.time(fieldTimestamp),
+			LocalTimestamp: m.time(5),
+			TotalTimerTime: m.floatOrZero(0, 1000, 0),
+			NumSessions:    m.uint16(1),
+		}
+	case mesgSession:
+		s := Session{
+			Timestamp:           m.time(fieldTimestamp),
+			StartTime:           m.time(2),
+			StartPosition:       m.position(3, 4),
+			Sport:               m.uint8(5),
+			SubSport:            m.uint8(6),
+			TotalElapsedTime:    m.floatOrZero(7, 1000, 0),
+			TotalTimerTime:      m.floatOrZero(8, 1000, 0),
+			TotalMovingTime:     m.floatOrZero(59, 1000, 0),
+			TotalDistance:       m.floatOrZero(9, 100, 0),
+			TotalCalories:       m.uint16(11),
+			AvgSpeed:            m.floatOrZero(14, 1000, 0),
+			MaxSpeed:            m.floatOrZero(15, 1000, 0),
+			AvgHeartRate:        m.uint8(16),
+			MaxHeartRate:        m.uint8(17),
+			AvgCadence:          m.uint8(18),
+			MaxCadence:          m.uint8(19),
+			AvgPower:            m.uint16(20),
+			MaxPower:            m.uint16(21),
+			TotalAscent:         m.uint16(22),
+			TotalDescent:        m.uint16(23),
+			NumLaps:             m.uint16(26),
+			NormalizedPower:     m.uint16(34),
+			TrainingStressScore: m.floatOrZero(35, 10, 0),
+			IntensityFactor:     m.floatOrZero(36, 1000, 0),
+			ThresholdPower:      m.uint16(45),
+		}
+		if v, ok := m.float(124, 1000, 0); ok {
+			s.AvgSpeed = v
+		}
+		if v, ok := m.float(125, 1000, 0); ok {
+			s.MaxSpeed = v
+		}
+		d.file.Sessions = append(d.file.Sessions, s)
+	case mesgLap:
+		l := Lap{
+			Timestamp:        m.time(fieldTimestamp),
+			StartTime:        m.time(2),
+			StartPosition:    m.position(3, 4),
+			EndPosition:      m.position(5, 6),
+			TotalElapsedTime: m.floatOrZero(7, 1000, 0),
+			TotalTimerTime:   m.floatOrZero(8, 1000, 0),
+			TotalDistance:    m.floatOrZero(9, 100, 0),
+			TotalCalories:    m.uint16(11),
+			AvgSpeed:         m.floatOrZero(13, 1000, 0),
+			MaxSpeed:         m.floatOrZero(14, 1000, 0),
+			AvgHeartRate:     m.uint8(15),
+			MaxHeartRate:     m.uint8(16),
+			AvgCadence:       m.uint8(17),
+			MaxCadence:       m.uint8(18),
+			AvgPower:         m.uint16(19),
+			MaxPower:         m.uint16(20),
+			TotalAscent:      m.uint16(21),
+			TotalDescent:     m.uint16(22),
+			Sport:            m.uint8(25),
+		}
+		if v, ok := m.float(110, 1000, 0); ok {
+			l.AvgSpeed = v
+		}
+		if v, ok := m.float(111, 1000, 0); ok {
+			l.MaxSpeed = v
+		}
+		d.file.Laps = append(d.file.Laps, l)
+	case mesgRecord:
+		r := Record{
+			Timestamp: m.time(fieldTimestamp),
+			Position:  m.position(0, 1),
+			Altitude:  m.floatPtr(2, 5, 500),
+			Distance:  m.floatPtr(5, 100, 0),
+			Speed:     m.floatPtr(6, 1000, 0),
+		}
+		// enhanced fields have a larger range and take precedence
+		if v := m.floatPtr(78, 5, 500); v != nil {
+			r.Altitude = v
+		}
+		if v := m.floatPtr(73, 1000, 0); v != nil {
+			r.Speed = v
+		}
+		if v, ok := m.values[3]; ok {
+			hr := uint8(v)
+			r.HeartRate = &hr
+		}
+		if v, ok := m.values[4]; ok {
+			cadence := uint8(v)
+			r.Cadence = &cadence
+		}
+		if v, ok := m.values[7]; ok {
+			power := uint16(v)
+			r.Power = &power
+		}
+		if v, ok := m.values[13]; ok {
+			temperature := int8(v)
+			r.Temperature = &temperature
+		}
+		d.file.Records = append(d.file.Records, r)
+	case mesgDeviceInfo:
+		d.file.DeviceInfos = append(d.file.DeviceInfos, DeviceInfo{
+			Timestamp:       m.time(fieldTimestamp),
+			DeviceIndex:     m.uint8(0),
+			DeviceType:      m.uint8(1),
+			Manufacturer:    m.uint16(2),
+			SerialNumber:    m.uint32(3),
+			Product:         m.uint16(4),
+			SoftwareVersion: m.floatOrZero(5, 100, 0),
+			HardwareVersion: m.uint8(6),
+			ProductName:     m.strings[27],
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// profileVersion is the FIT SDK profile version written to file headers
+const profileVersion = 2132
+
+// crcTable is the nibble lookup table for the FIT CRC-16
+var crcTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+// CRC returns the FIT CRC-16 of data
+func CRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := crcTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ crcTable[b&0xf]
+
+		tmp = crcTable[crc&0xf]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ crcTable[(b>>4)&0xf]
+	}
+	return crc
+}
+
+// field is a field to be encoded, value is nil when the field is invalid
+type field struct {
+	num      byte
+	baseType byte
+	value    *float64
+}
+
+// Encode writes the messages in f as a FIT activity file. Zero values in
+// the summary messages are written as invalid.
+func Encode(f *File) []byte {
+	e := encoder{}
+
+	e.write(0, mesgFileID, []field{
+		{0, baseEnum, optional(float64(f.FileID.Type))},
+		{1, baseUint16, optional(float64(f.FileID.Manufacturer))},
+		{2, baseUint16, optional(float64(f.FileID.Product))},
+		{3, baseUint32z, optional(float64(f.FileID.SerialNumber))},
+		{4, baseUint32, timestamp(f.FileID.TimeCreated)},
+	})
+
+	for _, r := range f.Records {
+		fields := []field{
+			{fieldTimestamp, baseUint32, timestamp(r.Timestamp)},
+			{0, baseSint32, nil},
+			{1, baseSint32, nil},
+			{78, baseUint32, scaled(r.Altitude, 5, 500)},
+			{5, baseUint32, scaled(r.Distance, 100, 0)},
+			{73, baseUint32, scaled(r.Speed, 1000, 0)},
+			{3, baseUint8, nil},
+			{4, baseUint8, nil},
+			{7, baseUint16, nil},
+			{13, baseSint8, nil},
+		}
+		if r.Position != nil {
+			fields[1].value = value(float64(DegreesToSemicircles(r.Position.Lat)))
+			fields[2].value = value(float64(DegreesToSemicircles(r.Position.Lon)))
+		}
+		if r.HeartRate != nil {
+			fields[6].value = value(float64(*r.HeartRate))
+		}
+		if r.Cadence != nil {
+			fields[7].value = value(float64(*r.Cadence))
+		}
+		if r.Power != nil {
+			fields[8].value = value(float64(*r.Power))
+		}
+		if r.Temperature != nil {
+			fields[9].value = value(float64(*r.Temperature))
+		}
+		e.write(1, mesgRecord, fields)
+	}
+
+	for _, l := range f.Laps {
+		e.write(2, mesgLap, []field{
+			{fieldTimestamp, baseUint32, timestamp(l.Timestamp)},
+			{2, baseUint32, timestamp(l.StartTime)},
+			{7, baseUint32, optionalScaled(l.TotalElapsedTime, 1000)},
+			{8, baseUint32, optionalScaled(l.TotalTimerTime, 1000)},
+			{9, baseUint32, optionalScaled(l.TotalDistance, 100)},
+			{11, baseUint16, optional(float64(l.TotalCalories))},
+			{15, baseUint8, optional(float64(l.AvgHeartRate))},
+			{16, baseUint8, optional(float64(l.MaxHeartRate))},
+			{17, baseUint8, optional(float64(l.AvgCadence))},
+			{19, baseUint16, optional(float64(l.AvgPower))},
+			{20, baseUint16, optional(float64(l.MaxPower))},
+			{21, baseUint16, optional(float64(l.TotalAscent))},
+			{22, baseUint16, optional(float64(l.TotalDescent))},
+			{25, baseEnum, value(float64(l.Sport))},
+			{110, baseUint32, optionalScaled(l.AvgSpeed, 1000)},
+			{111, baseUint32, optionalScaled(l.MaxSpeed, 1000)},
+		})
+	}
+
+	for _, s := range f.Sessions {
+		e.write(3, mesgSession, []field{
+			{fieldTimestamp, baseUint32, timestamp(s.Timestamp)},
+			{2, baseUint32, timestamp(s.StartTime)},
+			{5, baseEnum, value(float64(s.Sport))},
+			{6, baseEnum, value(float64(s.SubSport))},
+			{7, baseUint32, optionalScaled(s.TotalElapsedTime, 1000)},
+			{8, baseUint32, optionalScaled(s.TotalTimerTime, 1000)},
+			{9, baseUint32, optionalScaled(s.TotalDistance, 100)},
+			{11, baseUint16, optional(float64(s.TotalCalories))},
+			{16, baseUint8, optional(float64(s.AvgHeartRate))},
+			{17, baseUint8, optional(float64(s.MaxHeartRate))},
+			{18, baseUint8, optional(float64(s.AvgCadence))},
+			{20, baseUint16, optional(float64(s.AvgPower))},
+			{21, baseUint16, optional(float64(s.MaxPower))},
+			{22, baseUint16, optional(float64(s.TotalAscent))},
+			{23, baseUint16, optional(float64(s.TotalDescent))},
+			{26, baseUint16, optional(float64(s.NumLaps))},
+			{124, baseUint32, optionalScaled(s.AvgSpeed, 1000)},
+			{125, baseUint32, optionalScaled(s.MaxSpeed, 1000)},
+		})
+	}
+
+	if f.Activity != nil {
+		e.write(4, mesgActivity, []field{
+			{fieldTimestamp, baseUint32, timestamp(f.Activity.Timestamp)},
+			{0, baseUint32, optionalScaled(f.Activity.TotalTimerTime, 1000)},
+			{1, baseUint16, optional(float64(f.Activity.NumSessions))},
+			{5, baseUint32, timestamp(f.Activity.LocalTimestamp)},
+		})
+	}
+
+	data := e.buf.Bytes()
+
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:4], profileVersion)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(data)))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], CRC(header[:12]))
+
+	out := append(header, data...)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, CRC(out))
+
+	return append(out, crc...)
+}
+
+// DegreesToSemicircles converts degrees to a FIT semicircle value
+func DegreesToSemicircles(degrees float64) int32 {
+	return int32(math.Round(degrees * (math.Pow(2, 31) / 180.0)))
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	// defined holds the global message number defined for each local
+	// message type so definitions are only written when needed
+	defined [16]*uint16
+}
+
+func (e *encoder) write(localNum byte, globalNum uint16, fields []field) {
+	if e.defined[localNum] == nil || *e.defined[localNum] != globalNum {
+		e.buf.WriteByte(0x40 | localNum)
+		e.buf.Write([]byte{0, 0})
+		binary.Write(&e.buf, binary.LittleEndian, globalNum)
+		e.buf.WriteByte(byte(len(fields)))
+		for _, f := range fields {
+			e.buf.Write([]byte{f.num, byte(baseTypeSize(f.baseType)), baseTypeByte(f.baseType)})
+		}
+
+		num := globalNum
+		e.defined[localNum] = &num
+	}
+
+	e.buf.WriteByte(localNum)
+	for _, f := range fields {
+		e.writeValue(f)
+	}
+}
+
+// baseTypeByte returns the base type byte including the endian flag which
+// is set for multi byte types
+func baseTypeByte(baseType byte) byte {
+	if baseTypeSize(baseType) > 1 {
+		return baseType | 0x80
+	}
+	return baseType
+}
+
+func (e *encoder) writeValue(f field) {
+	b := make([]byte, baseTypeSize(f.baseType))
+	le := binary.LittleEndian
+
+	if f.value == nil {
+		switch f.baseType {
+		case baseSint8:
+			b[0] = 0x7f
+		case baseSint16:
+			le.PutUint16(b, 0x7fff)
+		case baseSint32:
+			le.PutUint32(b, 0x7fffffff)
+		case baseUint8z, baseUint16z, baseUint32z:
+			// zero is the invalid value
+		default:
+			for i := range b {
+				b[i] = 0xff
+			}
+		}
+		e.buf.Write(b)
+		return
+	}
+
+	v := *f.value
+	switch f.baseType {
+	case baseEnum, baseUint8, baseUint8z, baseByte:
+		b[0] = uint8(v)
+	case baseSint8:
+		b[0] = uint8(int8(v))
+	case baseUint16, baseUint16z:
+		le.PutUint16(b, uint16(v))
+	case baseSint16:
+		le.PutUint16(b, uint16(int16(v)))
+	case baseUint32, baseUint32z:
+		le.PutUint32(b, uint32(v))
+	case baseSint32:
+		le.PutUint32(b, uint32(int32(v)))
+	}
+	e.buf.Write(b)
+}
+
+func value(v float64) *float64 {
+	return &v
+}
+
+// optional returns nil for zero values so they are written as invalid
+func optional(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+func optionalScaled(v, scale float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return value(math.Round(v * scale))
+}
+
+func scaled(v *float64, scale, offset float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	return value(math.Round((*v + offset) * scale))
+}
+
+func timestamp(t time.Time) *float64 {
+	if t.IsZero() {
+		return nil
+	}
+	return value(float64(t.Unix() - fitEpoch.Unix()))
+}
@@ -0,0 +1,161 @@
+package fit
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	start := time.Date(2023, time.May, 6, 7, 30, 0, 0, time.UTC)
+
+	float := func(v float64) *float64 { return &v }
+	heartRate := uint8(142)
+	cadence := uint8(88)
+	power := uint16(231)
+	temperature := int8(-3)
+
+	testCases := map[string]struct {
+		file *File
+	}{
+		"empty activity": {
+			file: &File{
+				FileID: FileID{Type: 4, Manufacturer: 1, Product: 2, SerialNumber: 1234, TimeCreated: start},
+			},
+		},
+		"records without sensors": {
+			file: &File{
+				FileID: FileID{Type: 4, TimeCreated: start},
+				Records: []Record{
+					{Timestamp: start},
+					{Timestamp: start.Add(time.Second), Distance: float(3.25)},
+				},
+			},
+		},
+		"full activity": {
+			file: &File{
+				FileID: FileID{Type: 4, Manufacturer: 1, Product: 3121, SerialNumber: 99, TimeCreated: start},
+				Records: []Record{
+					{
+						Timestamp:   start,
+						Position:    &Position{Lat: 51.5007, Lon: -0.1246},
+						Altitude:    float(12),
+						Distance:    float(0),
+						Speed:       float(3.5),
+						HeartRate:   &heartRate,
+						Cadence:     &cadence,
+						Power:       &power,
+						Temperature: &temperature,
+					},
+					{
+						Timestamp: start.Add(5 * time.Second),
+						Position:  &Position{Lat: 51.5010, Lon: -0.1240},
+						Altitude:  float(13),
+						Distance:  float(17.5),
+						Speed:     float(3.75),
+					},
+				},
+				Laps: []Lap{
+					{
+						Timestamp:        start.Add(5 * time.Second),
+						StartTime:        start,
+						Sport:            2,
+						TotalElapsedTime: 5,
+						TotalTimerTime:   5,
+						TotalDistance:    17.5,
+						TotalAscent:      1,
+						AvgSpeed:         3.5,
+						MaxSpeed:         3.75,
+						AvgHeartRate:     142,
+						MaxHeartRate:     150,
+						AvgCadence:       88,
+						AvgPower:         231,
+						MaxPower:         240,
+					},
+				},
+				Sessions: []Session{
+					{
+						Timestamp:        start.Add(5 * time.Second),
+						StartTime:        start,
+						Sport:            2,
+						SubSport:         58,
+						TotalElapsedTime: 5,
+						TotalTimerTime:   5,
+						TotalDistance:    17.5,
+						TotalAscent:      1,
+						TotalCalories:    2,
+						AvgSpeed:         3.5,
+						MaxSpeed:         3.75,
+						AvgHeartRate:     142,
+						MaxHeartRate:     150,
+						AvgCadence:       88,
+						MaxCadence:       0,
+						AvgPower:         231,
+						MaxPower:         240,
+						NumLaps:          1,
+					},
+				},
+				Activity: &Activity{
+					Timestamp:      start.Add(5 * time.Second),
+					LocalTimestamp: start.Add(time.Hour + 5*time.Second),
+					TotalTimerTime: 5,
+					NumSessions:    1,
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := Decode(Encode(tc.file))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// positions are stored as semicircles so are compared separately
+			if len(decoded.Records) != len(tc.file.Records) {
+				t.Fatalf("expected %d records, got %d", len(tc.file.Records), len(decoded.Records))
+			}
+			for i, r := range tc.file.Records {
+				got := decoded.Records[i].Position
+				if (r.Position == nil) != (got == nil) {
+					t.Fatalf("record %d: expected position %v, got %v", i, r.Position, got)
+				}
+				if r.Position != nil && (math.Abs(r.Position.Lat-got.Lat) > 1e-6 || math.Abs(r.Position.Lon-got.Lon) > 1e-6) {
+					t.Fatalf("record %d: expected position %v, got %v", i, *r.Position, *got)
+				}
+				decoded.Records[i].Position = r.Position
+			}
+
+			if !reflect.DeepEqual(decoded, tc.file) {
+				t.Fatalf("expected %+v, got %+v", tc.file, decoded)
+			}
+		})
+	}
+}
+
+func TestCRC(t *testing.T) {
+	testCases := map[string]struct {
+		data     []byte
+		expected uint16
+	}{
+		"empty": {
+			data:     nil,
+			expected: 0,
+		},
+		"check string": {
+			// the CRC-16/ARC check value
+			data:     []byte("123456789"),
+			expected: 0xbb3d,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := CRC(tc.data); got != tc.expected {
+				t.Fatalf("expected %#04x, got %#04x", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package fit
+
+import "time"
+
+// File holds the messages decoded from a FIT file which are used by the
+// tool, other messages are skipped
+type File struct {
+	FileID      FileID
+	Activity    *Activity
+	Sessions    []Session
+	Laps        []Lap
+	Records     []Record
+	DeviceInfos []DeviceInfo
+}
+
+// Position is a point in degrees, converted from FIT semicircles
+type Position struct {
+	Lat float64
+	Lon float64
+}
+
+// FileID is the file_id message, identifying the device that created the
+// file
+type FileID struct {
+	Type         uint8
+	Manufacturer uint16
+	Product      uint16
+	SerialNumber uint32
+	TimeCreated  time.Time
+	ProductName  string
+}
+
+// Activity is the activity message, written once at the end of an activity
+type Activity struct {
+	Timestamp time.Time
+	// LocalTimestamp is the wall clock time on the device when the activity
+	// message was written. It is returned as a UTC time with the same clock
+	// reading.
+	LocalTimestamp time.Time
+	TotalTimerTime float64
+	NumSessions    uint16
+}
+
+// Session is the session message, summarising a single sport within an
+// activity
+type Session struct {
+	Timestamp     time.Time
+	StartTime     time.Time
+	StartPosition *Position
+
+	Sport    uint8
+	SubSport uint8
+
+	// times in seconds, distance in metres
+	TotalElapsedTime float64
+	TotalTimerTime   float64
+	TotalMovingTime  float64
+	TotalDistance    float64
+
+	TotalAscent   uint16
+	TotalDescent  uint16
+	TotalCalories uint16
+
+	// speeds in metres per second
+	AvgSpeed float64
+	MaxSpeed float64
+
+	AvgHeartRate uint8
+	MaxHeartRate uint8
+	AvgCadence   uint8
+	MaxCadence   uint8
+
+	AvgPower        uint16
+	MaxPower        uint16
+	NormalizedPower uint16
+	ThresholdPower  uint16
+
+	TrainingStressScore float64
+	IntensityFactor     float64
+
+	NumLaps uint16
+}
+
+// Lap is the lap message
+type Lap struct {
+	Timestamp     time.Time
+	StartTime     time.Time
+	StartPosition *Position
+	EndPosition   *Position
+
+	Sport uint8
+
+	TotalElapsedTime float64
+	TotalTimerTime   float64
+	TotalDistance    float64
+
+	TotalAscent   uint16
+	TotalDescent  uint16
+	TotalCalories uint16
+
+	AvgSpeed float64
+	MaxSpeed float64
+
+	AvgHeartRate uint8
+	MaxHeartRate uint8
+	AvgCadence   uint8
+	MaxCadence   uint8
+	AvgPower     uint16
+	MaxPower     uint16
+}
+
+// Record is the record message, a single sample of the activity's sensors.
+// Fields are nil when the value was not recorded.
+type Record struct {
+	Timestamp time.Time
+	Position  *Position
+
+	// Altitude in metres
+	Altitude *float64
+	// Distance in metres from the start of the activity
+	Distance *float64
+	// Speed in metres per second
+	Speed *float64
+
+	HeartRate *uint8
+	Cadence   *uint8
+	Power     *uint16
+	// Temperature in degrees celsius
+	Temperature *int8
+}
+
+// DeviceInfo is the device_info message, describing the recording device
+// and any connected sensors
+type DeviceInfo struct {
+	Timestamp       time.Time
+	DeviceIndex     uint8
+	DeviceType      uint8
+	Manufacturer    uint16
+	SerialNumber    uint32
+	Product         uint16
+	SoftwareVersion float64
+	HardwareVersion uint8
+	ProductName     string
+}
+
+// Creator returns the device_info message for the device which created the
+// file, device index 0.
+func (f *File) Creator() *DeviceInfo {
+	for i := range f.DeviceInfos {
+		if f.DeviceInfos[i].DeviceIndex == 0 {
+			return &f.DeviceInfos[i]
+		}
+	}
+	return nil
+}
+
+// UTCOffset returns the offset of the device's clock from UTC, from the
+// local timestamp of the activity message. It is rounded to the nearest
+// quarter hour as the two timestamps may be written a moment apart. False
+// is returned when the file has no local timestamp.
+func (f *File) UTCOffset() (time.Duration, bool) {
+	if f.Activity == nil || f.Activity.Timestamp.IsZero() || f.Activity.LocalTimestamp.IsZero() {
+		return 0, false
+	}
+
+	return f.Activity.LocalTimestamp.Sub(f.Activity.Timestamp).Round(15 * time.Minute), true
+}
+
+// StartTime returns the start of the first session, or the first record
+// when there are no sessions
+func (f *File) StartTime() (time.Time, bool) {
+	if len(f.Sessions) > 0 && !f.Sessions[0].StartTime.IsZero() {
+		return f.Sessions[0].StartTime, true
+	}
+	for _, r := range f.Records {
+		if !r.Timestamp.IsZero() {
+			return r.Timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
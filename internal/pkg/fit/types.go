@@ -0,0 +1,217 @@
+package fit
+
+import (
+	"fmt"
+	"time"
+)
+
+// fitEpoch is the zero point for FIT timestamps, 1989-12-31T00:00:00Z
+var fitEpoch = time.Date(1989, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// minAbsoluteTimestamp is the smallest timestamp that is relative to the FIT
+// epoch, smaller values are relative to device power on
+const minAbsoluteTimestamp = 0x10000000
+
+// global message numbers
+const (
+	mesgFileID     = 0
+	mesgSession    = 18
+	mesgLap        = 19
+	mesgRecord     = 20
+	mesgDeviceInfo = 23
+	mesgActivity   = 34
+)
+
+// fieldTimestamp is the field number used for the timestamp in all messages
+const fieldTimestamp = 253
+
+// base types, the lower 5 bits of the base type byte in a field definition
+const (
+	baseEnum    = 0x00
+	baseSint8   = 0x01
+	baseUint8   = 0x02
+	baseSint16  = 0x03
+	baseUint16  = 0x04
+	baseSint32  = 0x05
+	baseUint32  = 0x06
+	baseString  = 0x07
+	baseFloat32 = 0x08
+	baseFloat64 = 0x09
+	baseUint8z  = 0x0a
+	baseUint16z = 0x0b
+	baseUint32z = 0x0c
+	baseByte    = 0x0d
+	baseSint64  = 0x0e
+	baseUint64  = 0x0f
+	baseUint64z = 0x10
+)
+
+// baseTypeSize returns the size in bytes of a single value of a base type
+func baseTypeSize(baseType byte) int {
+	switch baseType & 0x1f {
+	case baseSint16, baseUint16, baseUint16z:
+		return 2
+	case baseSint32, baseUint32, baseUint32z, baseFloat32:
+		return 4
+	case baseSint64, baseUint64, baseUint64z, baseFloat64:
+		return 8
+	default:
+		return 1
+	}
+}
+
+// sport and sub_sport enum values with special meanings
+const (
+	// SportMultisport is the sport of a file with sessions of several sports
+	SportMultisport = 18
+	// SubSportGeneric is the sub_sport when there is no more specific one
+	SubSportGeneric = 0
+)
+
+// Sports maps the FIT sport enum to names
+var Sports = map[uint8]string{
+	0:  "generic",
+	1:  "running",
+	2:  "cycling",
+	3:  "transition",
+	4:  "fitness_equipment",
+	5:  "swimming",
+	6:  "basketball",
+	7:  "soccer",
+	8:  "tennis",
+	9:  "american_football",
+	10: "training",
+	11: "walking",
+	12: "cross_country_skiing",
+	13: "alpine_skiing",
+	14: "snowboarding",
+	15: "rowing",
+	16: "mountaineering",
+	17: "hiking",
+	18: "multisport",
+	19: "paddling",
+	20: "flying",
+	21: "e_biking",
+	22: "motorcycling",
+	23: "boating",
+	24: "driving",
+	25: "golf",
+	26: "hang_gliding",
+	27: "horseback_riding",
+	28: "hunting",
+	29: "fishing",
+	30: "inline_skating",
+	31: "rock_climbing",
+	32: "sailing",
+	33: "ice_skating",
+	34: "sky_diving",
+	35: "snowshoeing",
+	36: "snowmobiling",
+	37: "stand_up_paddleboarding",
+	38: "surfing",
+	39: "wakeboarding",
+	40: "water_skiing",
+	41: "kayaking",
+	42: "rafting",
+	43: "windsurfing",
+	44: "kitesurfing",
+}
+
+// SubSports maps the FIT sub_sport enum to names
+var SubSports = map[uint8]string{
+	0:  "generic",
+	1:  "treadmill",
+	2:  "street",
+	3:  "trail",
+	4:  "track",
+	5:  "spin",
+	6:  "indoor_cycling",
+	7:  "road",
+	8:  "mountain",
+	9:  "downhill",
+	10: "recumbent",
+	11: "cyclocross",
+	12: "hand_cycling",
+	13: "track_cycling",
+	14: "indoor_rowing",
+	15: "elliptical",
+	16: "stair_climbing",
+	17: "lap_swimming",
+	18: "open_water",
+	19: "flexibility_training",
+	20: "strength_training",
+	21: "warm_up",
+	22: "match",
+	23: "exercise",
+	24: "challenge",
+	25: "indoor_skiing",
+	26: "cardio_training",
+	27: "indoor_walking",
+	28: "e_bike_fitness",
+	29: "bmx",
+	30: "casual_walking",
+	31: "speed_walking",
+	45: "indoor_running",
+	46: "gravel_cycling",
+	47: "e_bike_mountain",
+	48: "commuting",
+	49: "mixed_surface",
+	58: "virtual_activity",
+}
+
+// Manufacturers maps the FIT manufacturer enum to names, only commonly seen
+// manufacturers are listed
+var Manufacturers = map[uint16]string{
+	1:   "garmin",
+	15:  "dynastream",
+	23:  "suunto",
+	32:  "wahoo_fitness",
+	69:  "stages_cycling",
+	71:  "tomtom",
+	89:  "tacx",
+	95:  "stryd",
+	123: "polar_electro",
+	255: "development",
+	260: "zwift",
+	265: "strava",
+	267: "bryton",
+	268: "sram",
+	281: "trainer_road",
+	289: "hammerhead",
+	294: "coros",
+}
+
+// SportName returns the name for a sport enum value
+func SportName(sport uint8) string {
+	if name, ok := Sports[sport]; ok {
+		return name
+	}
+	return fmt.Sprintf("sport_%d", sport)
+}
+
+// SubSportName returns the name for a sub_sport enum value
+func SubSportName(subSport uint8) string {
+	if name, ok := SubSports[subSport]; ok {
+		return name
+	}
+	return fmt.Sprintf("sub_sport_%d", subSport)
+}
+
+// ManufacturerName returns the name for a manufacturer enum value
+func ManufacturerName(manufacturer uint16) string {
+	if name, ok := Manufacturers[manufacturer]; ok {
+		return name
+	}
+	return fmt.Sprintf("manufacturer_%d", manufacturer)
+}
+
+// SportFromName returns the sport enum value for a sport name, generic is
+// returned for unknown names
+func SportFromName(name string) uint8 {
+	for sport, sportName := range Sports {
+		if sportName == name {
+			return sport
+		}
+	}
+	return 0
+}
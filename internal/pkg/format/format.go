@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+)
+
+const (
+	FIT     = "fit"
+	GPX     = "gpx"
+	TCX     = "tcx"
+	Unknown = "unknown"
+
+	// GeoJSON is only produced by conversion, originals are never GeoJSON
+	GeoJSON = "geojson"
+)
+
+// Detect inspects the content of an activity file and returns the format it
+// is in. Gzipped content is decompressed and inspected in turn, only one
+// layer is decompressed so nested gzip is reported as Unknown. Unrecognised
+// content is reported as Unknown.
+func Detect(data []byte) string {
+	if IsGzip(data) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return Unknown
+		}
+		defer zr.Close()
+
+		inner, err := io.ReadAll(zr)
+		if err != nil {
+			return Unknown
+		}
+
+		return detectUncompressed(inner)
+	}
+
+	return detectUncompressed(data)
+}
+
+// detectUncompressed returns the format of content which is not gzipped
+func detectUncompressed(data []byte) string {
+	if isFIT(data) {
+		return FIT
+	}
+
+	switch xmlRootElement(data) {
+	case "gpx":
+		return GPX
+	case "TrainingCenterDatabase":
+		return TCX
+	}
+
+	return Unknown
+}
+
+// IsGzip reports whether data starts with the gzip magic number.
+func IsGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// isFIT checks for the FIT file header, the first byte is the header size
+// (12 or 14) and bytes 8-11 hold the ".FIT" data type signature.
+func isFIT(data []byte) bool {
+	if len(data) < 12 {
+		return false
+	}
+	if data[0] != 12 && data[0] != 14 {
+		return false
+	}
+
+	return string(data[8:12]) == ".FIT"
+}
+
+// xmlRootElement returns the local name of the first element in an XML
+// document, or an empty string if the data is not XML.
+func xmlRootElement(data []byte) string {
+	// some devices write a byte order mark and leading whitespace which the
+	// decoder will not skip on its own
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 || data[0] != '<' {
+		return ""
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	// only the element names are needed, so other encodings can be read as is
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if el, ok := token.(xml.StartElement); ok {
+			return el.Name.Local
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package gear tracks the use of maintenance components, such as chains,
+// tyres and shoes, fitted to gear and whether they are due for service
+package gear
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/config"
+)
+
+// Component is a part of a piece of gear which is serviced or replaced
+// after an interval of use
+type Component struct {
+	Name   string
+	GearID string
+	// Installed is the local date the component was fitted, activities
+	// from then count towards its use
+	Installed time.Time
+
+	// IntervalDistance in metres, IntervalTime in seconds of moving time and
+	// IntervalDays since installation. Zero intervals are not checked.
+	IntervalDistance float64
+	IntervalTime     float64
+	IntervalDays     int
+}
+
+// Usage is the use of a component since it was installed
+type Usage struct {
+	Activities int
+	// Distance in metres and MovingTime in seconds
+	Distance   float64
+	MovingTime float64
+}
+
+// ParseConfig reads the gear section of the tool config, which has a list
+// of components each with a name, gear_id, installed date and at least one
+// of interval_km, interval_hours or interval_days. Nil is returned when
+// the section is missing.
+func ParseConfig(data any) ([]Component, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	section, ok := data.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("gear config must be a map")
+	}
+
+	entries, _ := section["components"].([]any)
+
+	var components []Component
+	for i, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("gear component %d must be a map", i)
+		}
+
+		c := Component{}
+		c.Name, _ = entry["name"].(string)
+		c.GearID, _ = entry["gear_id"].(string)
+		if c.Name == "" || c.GearID == "" {
+			return nil, fmt.Errorf("gear component %d must have a name and gear_id", i)
+		}
+
+		installed, err := config.Date(entry["installed"], "installed")
+		if err != nil {
+			return nil, fmt.Errorf("gear component %s: %w", c.Name, err)
+		}
+		c.Installed = installed
+
+		if km, ok := config.Float(entry["interval_km"]); ok {
+			c.IntervalDistance = km * 1000
+		}
+		if hours, ok := config.Float(entry["interval_hours"]); ok {
+			c.IntervalTime = hours * 3600
+		}
+		if days, ok := config.Float(entry["interval_days"]); ok {
+			c.IntervalDays = int(days)
+		}
+		if c.IntervalDistance <= 0 && c.IntervalTime <= 0 && c.IntervalDays <= 0 {
+			return nil, fmt.Errorf("gear component %s must have an interval", c.Name)
+		}
+
+		components = append(components, c)
+	}
+
+	return components, nil
+}
+
+// Due returns the reasons the component is due for service, none when it
+// is not due
+func (c Component) Due(usage Usage, now time.Time) []string {
+	var reasons []string
+
+	if c.IntervalDistance > 0 && usage.Distance >= c.IntervalDistance {
+		reasons = append(reasons, fmt.Sprintf("%.0fkm used of %.0fkm", usage.Distance/1000, c.IntervalDistance/1000))
+	}
+	if c.IntervalTime > 0 && usage.MovingTime >= c.IntervalTime {
+		reasons = append(reasons, fmt.Sprintf("%.0fh used of %.0fh", usage.MovingTime/3600, c.IntervalTime/3600))
+	}
+	if c.IntervalDays > 0 && !now.Before(c.Installed.AddDate(0, 0, c.IntervalDays)) {
+		reasons = append(reasons, fmt.Sprintf("installed over %d days ago", c.IntervalDays))
+	}
+
+	return reasons
+}
@@ -0,0 +1,176 @@
+package geo
+
+import "math"
+
+// EarthRadius is the mean radius of the earth in metres
+const EarthRadius = 6371008.8
+
+// Point is a location in degrees
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Distance returns the great circle distance between two points in metres
+// using the haversine formula
+func Distance(a, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BBox is a bounding box in degrees
+type BBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Bounds returns the bounding box of the points, false is returned if there
+// are no points
+func Bounds(points []Point) (BBox, bool) {
+	if len(points) == 0 {
+		return BBox{}, false
+	}
+
+	b := BBox{
+		MinLat: points[0].Lat,
+		MinLon: points[0].Lon,
+		MaxLat: points[0].Lat,
+		MaxLon: points[0].Lon,
+	}
+	for _, p := range points[1:] {
+		b.MinLat = math.Min(b.MinLat, p.Lat)
+		b.MinLon = math.Min(b.MinLon, p.Lon)
+		b.MaxLat = math.Max(b.MaxLat, p.Lat)
+		b.MaxLon = math.Max(b.MaxLon, p.Lon)
+	}
+
+	return b, true
+}
+
+// Contains returns true if the point is inside the bounding box
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat &&
+		p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// Intersects returns true if the bounding boxes overlap
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLat <= o.MaxLat && b.MaxLat >= o.MinLat &&
+		b.MinLon <= o.MaxLon && b.MaxLon >= o.MinLon
+}
+
+// Interpolate returns points between a and b, excluding both, spaced no
+// more than step metres apart
+func Interpolate(a, b Point, step float64) []Point {
+	n := int(math.Ceil(Distance(a, b) / step))
+
+	var points []Point
+	for i := 1; i < n; i++ {
+		f := float64(i) / float64(n)
+		points = append(points, Point{
+			Lat: a.Lat + (b.Lat-a.Lat)*f,
+			Lon: a.Lon + (b.Lon-a.Lon)*f,
+		})
+	}
+
+	return points
+}
+
+// BBoxAround returns a bounding box containing all points within radius
+// metres of p
+func BBoxAround(p Point, radius float64) BBox {
+	dLat := radius / EarthRadius * (180 / math.Pi)
+	dLon := dLat / math.Max(math.Cos(p.Lat*math.Pi/180), 0.01)
+
+	return BBox{
+		MinLat: math.Max(p.Lat-dLat, -90),
+		MinLon: math.Max(p.Lon-dLon, -180),
+		MaxLat: math.Min(p.Lat+dLat, 90),
+		MaxLon: math.Min(p.Lon+dLon, 180),
+	}
+}
+
+// DistanceToLine returns the smallest distance in metres from p to the
+// line. Distances use an equirectangular projection around p, which is
+// accurate for the short distances used in searches.
+func DistanceToLine(p Point, line []Point) float64 {
+	if len(line) == 0 {
+		return math.Inf(1)
+	}
+
+	cosLat := math.Cos(p.Lat * math.Pi / 180)
+	project := func(q Point) (float64, float64) {
+		x := (q.Lon - p.Lon) * math.Pi / 180 * cosLat * EarthRadius
+		y := (q.Lat - p.Lat) * math.Pi / 180 * EarthRadius
+		return x, y
+	}
+
+	ax, ay := project(line[0])
+	best := math.Hypot(ax, ay)
+	for _, q := range line[1:] {
+		bx, by := project(q)
+
+		// closest point to the origin on the segment a-b
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+		}
+		best = math.Min(best, math.Hypot(ax+t*dx, ay+t*dy))
+
+		ax, ay = bx, by
+	}
+
+	return best
+}
+
+// Simplify reduces the number of points in the line using the
+// Douglas-Peucker algorithm, points closer than tolerance metres to the
+// simplified line are removed
+func Simplify(line []Point, tolerance float64) []Point {
+	if len(line) < 3 {
+		return line
+	}
+
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// a stack of ranges is used rather than recursion as tracks can be long
+	stack := [][2]int{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		furthest, furthestDistance := -1, tolerance
+		segment := []Point{line[r[0]], line[r[1]]}
+		for i := r[0] + 1; i < r[1]; i++ {
+			if d := DistanceToLine(line[i], segment); d > furthestDistance {
+				furthest, furthestDistance = i, d
+			}
+		}
+
+		if furthest == -1 {
+			continue
+		}
+		keep[furthest] = true
+		stack = append(stack, [2]int{r[0], furthest}, [2]int{furthest, r[1]})
+	}
+
+	var simplified []Point
+	for i, p := range line {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+
+	return simplified
+}
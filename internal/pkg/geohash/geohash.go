@@ -0,0 +1,147 @@
+package geohash
+
+import (
+	"math"
+	"strings"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Encode returns the geohash of a point at the given precision
+func Encode(p geo.Point, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	var b strings.Builder
+	bit, ch := 0, 0
+	even := true
+
+	for b.Len() < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if p.Lon >= mid {
+				ch = ch<<1 | 1
+				minLon = mid
+			} else {
+				ch <<= 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if p.Lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		bit++
+		if bit == 5 {
+			b.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return b.String()
+}
+
+// Bounds returns the bounding box of a geohash cell
+func Bounds(hash string) geo.BBox {
+	box := geo.BBox{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+	even := true
+
+	for i := 0; i < len(hash); i++ {
+		v := strings.IndexByte(base32, hash[i])
+		for bit := 4; bit >= 0; bit-- {
+			set := v>>bit&1 == 1
+			if even {
+				mid := (box.MinLon + box.MaxLon) / 2
+				if set {
+					box.MinLon = mid
+				} else {
+					box.MaxLon = mid
+				}
+			} else {
+				mid := (box.MinLat + box.MaxLat) / 2
+				if set {
+					box.MinLat = mid
+				} else {
+					box.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return box
+}
+
+// CellSize returns the height and width of cells at a precision in degrees
+func CellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	latBits := bits / 2
+	lonBits := bits - latBits
+
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+// CoverBBox returns the cells at a precision which together cover the
+// bounding box
+func CoverBBox(box geo.BBox, precision int) []string {
+	cellLat, cellLon := CellSize(precision)
+	start := Bounds(Encode(geo.Point{Lat: box.MinLat, Lon: box.MinLon}, precision))
+
+	var cells []string
+	for lat := start.MinLat + cellLat/2; lat-cellLat/2 <= box.MaxLat && lat < 90; lat += cellLat {
+		for lon := start.MinLon + cellLon/2; lon-cellLon/2 <= box.MaxLon && lon < 180; lon += cellLon {
+			cells = append(cells, Encode(geo.Point{Lat: lat, Lon: lon}, precision))
+		}
+	}
+
+	return cells
+}
+
+// CoverCount returns the number of cells CoverBBox would return, without
+// computing them
+func CoverCount(box geo.BBox, precision int) int {
+	cellLat, cellLon := CellSize(precision)
+	rows := math.Floor(box.MaxLat/cellLat) - math.Floor(box.MinLat/cellLat) + 1
+	cols := math.Floor(box.MaxLon/cellLon) - math.Floor(box.MinLon/cellLon) + 1
+
+	return int(rows * cols)
+}
+
+// CoverLine returns the cells at a precision which the line passes through.
+// Long segments are interpolated so cells between points are included.
+func CoverLine(points []geo.Point, precision int) []string {
+	cellLat, _ := CellSize(precision)
+	// step at half the height of a cell, cells are never narrower than
+	// they are tall away from the poles
+	step := cellLat / 2 * (math.Pi / 180) * geo.EarthRadius
+
+	seen := make(map[string]bool)
+	var cells []string
+	add := func(p geo.Point) {
+		cell := Encode(p, precision)
+		if !seen[cell] {
+			seen[cell] = true
+			cells = append(cells, cell)
+		}
+	}
+
+	for i, p := range points {
+		if i > 0 {
+			for _, q := range geo.Interpolate(points[i-1], p, step) {
+				add(q)
+			}
+		}
+		add(p)
+	}
+
+	return cells
+}
@@ -0,0 +1,148 @@
+// Package heatmap draws the density of many activities into transparent map
+// tiles, to be shown over a base map
+package heatmap
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/raster"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+)
+
+// saturation is the number of activities passing through a pixel at which
+// it's drawn at full intensity. A fixed value is used rather than the
+// busiest pixel in the tile so neighbouring tiles match.
+const saturation = 25
+
+// lineHalfWidth is half the width of the lines drawn for each activity in
+// pixels
+const lineHalfWidth = 0.75
+
+// stop is a colour in the ramp from quiet to busy pixels
+type stop struct {
+	at    float64
+	color color.NRGBA
+}
+
+var ramp = []stop{
+	{at: 0, color: color.NRGBA{R: 0xb2, G: 0x18, B: 0x2b, A: 0x80}},
+	{at: 0.4, color: color.NRGBA{R: 0xf4, G: 0x6d, B: 0x43, A: 0xd0}},
+	{at: 0.75, color: color.NRGBA{R: 0xfd, G: 0xdb, B: 0x6d, A: 0xf0}},
+	{at: 1, color: color.NRGBA{R: 0xff, G: 0xff, B: 0xf0, A: 0xff}},
+}
+
+// Canvas accumulates activities drawn into a tile
+type Canvas struct {
+	tile   tiles.Tile
+	counts []float64
+	mask   *raster.Mask
+	empty  bool
+}
+
+// NewCanvas returns an empty canvas for the tile
+func NewCanvas(t tiles.Tile) *Canvas {
+	return &Canvas{
+		tile:   t,
+		counts: make([]float64, tiles.Size*tiles.Size),
+		mask:   raster.NewMask(tiles.Size, tiles.Size),
+		empty:  true,
+	}
+}
+
+// AddActivity draws the lines of one activity into the canvas, each
+// activity adds at most one to the count of a pixel however many times it
+// passes through it
+func (c *Canvas) AddActivity(lines [][]geo.Point) {
+	originX, originY := float64(c.tile.X*tiles.Size), float64(c.tile.Y*tiles.Size)
+	vertex := func(p geo.Point) raster.Vertex {
+		x, y := tiles.Pixel(p, c.tile.Z)
+		return raster.Vertex{X: x - originX, Y: y - originY}
+	}
+
+	c.mask.Clear()
+	for _, line := range lines {
+		if len(line) == 1 {
+			v := vertex(line[0])
+			c.mask.Line(v, v, lineHalfWidth)
+			continue
+		}
+		for i := 1; i < len(line); i++ {
+			c.mask.Line(vertex(line[i-1]), vertex(line[i]), lineHalfWidth)
+		}
+	}
+
+	for i, coverage := range c.mask.Coverage {
+		if coverage > 0 {
+			c.counts[i] += coverage
+			c.empty = false
+		}
+	}
+}
+
+// Empty returns true if nothing has been drawn inside the tile
+func (c *Canvas) Empty() bool {
+	return c.empty
+}
+
+// PNG encodes the canvas as a transparent PNG tile
+func (c *Canvas) PNG() ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, tiles.Size, tiles.Size))
+
+	for i, count := range c.counts {
+		if count == 0 {
+			continue
+		}
+
+		intensity := math.Min(1, math.Log1p(count)/math.Log1p(saturation))
+		col := colorAt(intensity)
+		// counts below one are the antialiased edges of a single line
+		col.A = uint8(float64(col.A) * math.Min(1, count))
+
+		img.Pix[i*4] = col.R
+		img.Pix[i*4+1] = col.G
+		img.Pix[i*4+2] = col.B
+		img.Pix[i*4+3] = col.A
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tile %s: %w", c.tile, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EmptyPNG returns a fully transparent tile
+func EmptyPNG() ([]byte, error) {
+	return NewCanvas(tiles.Tile{}).PNG()
+}
+
+func colorAt(intensity float64) color.NRGBA {
+	for i := 1; i < len(ramp); i++ {
+		if intensity > ramp[i].at {
+			continue
+		}
+
+		a, b := ramp[i-1], ramp[i]
+		f := (intensity - a.at) / (b.at - a.at)
+		mix := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+		}
+
+		return color.NRGBA{
+			R: mix(a.color.R, b.color.R),
+			G: mix(a.color.G, b.color.G),
+			B: mix(a.color.B, b.color.B),
+			A: mix(a.color.A, b.color.A),
+		}
+	}
+
+	return ramp[len(ramp)-1].color
+}
@@ -0,0 +1,140 @@
+// Package load calculates the training stress of activities and the
+// fitness, fatigue and form which follow from the stress over time
+package load
+
+import (
+	"math"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/efforts"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// Methods used to calculate stress
+const (
+	MethodPower = "power"
+	MethodTRIMP = "trimp"
+)
+
+// Time constants of the fitness and fatigue averages in days
+const (
+	FitnessDays = 42
+	FatigueDays = 7
+)
+
+// normalizedPowerWindow is the rolling average used for normalized power
+const normalizedPowerWindow = 30
+
+// maxGap is the longest time between points which is counted for TRIMP,
+// longer gaps are pauses in recording
+const maxGap = 30 * time.Second
+
+// Stress is the training stress of an activity
+type Stress struct {
+	Method string
+	Score  float64
+
+	// NormalizedPower and Intensity are set when the power method is used
+	NormalizedPower float64
+	Intensity       float64
+}
+
+// PowerStress returns the training stress score of the points, relative to
+// an hour at FTP scoring 100. False is returned when the points have no
+// power or the power is too short to normalize.
+func PowerStress(points []track.Point, ftp float64) (Stress, bool) {
+	watts, ok := efforts.PowerSeries(points)
+	if !ok || len(watts) < normalizedPowerWindow || ftp <= 0 {
+		return Stress{}, false
+	}
+
+	sum, fourths := 0.0, 0.0
+	for i, w := range watts {
+		sum += w
+		if i >= normalizedPowerWindow {
+			sum -= watts[i-normalizedPowerWindow]
+		}
+		if i >= normalizedPowerWindow-1 {
+			fourths += math.Pow(sum/normalizedPowerWindow, 4)
+		}
+	}
+
+	np := math.Pow(fourths/float64(len(watts)-normalizedPowerWindow+1), 0.25)
+	intensity := np / ftp
+	hours := float64(len(watts)) / 3600
+
+	return Stress{
+		Method:          MethodPower,
+		Score:           hours * intensity * intensity * 100,
+		NormalizedPower: np,
+		Intensity:       intensity,
+	}, true
+}
+
+// TRIMPStress returns Banister's training impulse of the points from the
+// heart rate reserve. False is returned when the points have no heart rate.
+func TRIMPStress(points []track.Point, maxHR, restingHR float64) (Stress, bool) {
+	if maxHR <= restingHR || restingHR <= 0 {
+		return Stress{}, false
+	}
+
+	score := 0.0
+	found := false
+	for i := 0; i < len(points)-1; i++ {
+		hr := points[i].HeartRate
+		if hr == nil {
+			continue
+		}
+		found = true
+
+		gap := points[i+1].Time.Sub(points[i].Time)
+		if gap <= 0 || gap > maxGap {
+			continue
+		}
+
+		reserve := math.Max(0, math.Min(1, (*hr-restingHR)/(maxHR-restingHR)))
+		score += gap.Minutes() * reserve * 0.64 * math.Exp(1.92*reserve)
+	}
+
+	return Stress{Method: MethodTRIMP, Score: score}, found
+}
+
+// Day is the training load on a day
+type Day struct {
+	Date   time.Time
+	Stress float64
+	// Fitness is the chronic training load, Fatigue the acute training load
+	// and Form the balance of the two at the start of the day
+	Fitness float64
+	Fatigue float64
+	Form    float64
+}
+
+// Series returns the training load for each day from the day after the
+// previous day until the last day, inclusive. Stress is the total for each
+// date and days without stress are included with none. The previous day
+// seeds the averages, use a zero Day with the date before the first
+// activity to start from nothing.
+func Series(previous Day, stress map[time.Time]float64, last time.Time) []Day {
+	var days []Day
+	for date := previous.Date.AddDate(0, 0, 1); !date.After(last); date = date.AddDate(0, 0, 1) {
+		day := Day{
+			Date:   date,
+			Stress: stress[date],
+			Form:   previous.Fitness - previous.Fatigue,
+		}
+		day.Fitness = previous.Fitness + (day.Stress-previous.Fitness)/FitnessDays
+		day.Fatigue = previous.Fatigue + (day.Stress-previous.Fatigue)/FatigueDays
+
+		days = append(days, day)
+		previous = day
+	}
+
+	return days
+}
+
+// Date returns the UTC date of a time, used to total stress by day
+func Date(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,187 @@
+package load
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+var start = time.Date(2023, time.June, 1, 8, 0, 0, 0, time.UTC)
+
+// steadyPoints returns points every interval for the duration, with each
+// value set when not zero
+func steadyPoints(duration, interval time.Duration, power, heartRate float64) []track.Point {
+	var points []track.Point
+	for offset := time.Duration(0); offset < duration; offset += interval {
+		p := track.Point{Time: start.Add(offset)}
+		if power != 0 {
+			w := power
+			p.Power = &w
+		}
+		if heartRate != 0 {
+			hr := heartRate
+			p.HeartRate = &hr
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func TestPowerStress(t *testing.T) {
+	testCases := map[string]struct {
+		points     []track.Point
+		ftp        float64
+		expected   Stress
+		expectedOK bool
+	}{
+		"an hour at ftp": {
+			points:     steadyPoints(time.Hour, time.Second, 250, 0),
+			ftp:        250,
+			expected:   Stress{Method: MethodPower, Score: 100, NormalizedPower: 250, Intensity: 1},
+			expectedOK: true,
+		},
+		"half an hour under ftp": {
+			points:     steadyPoints(30*time.Minute, time.Second, 200, 0),
+			ftp:        250,
+			expected:   Stress{Method: MethodPower, Score: 32, NormalizedPower: 200, Intensity: 0.8},
+			expectedOK: true,
+		},
+		"too short to normalize": {
+			points: steadyPoints(20*time.Second, time.Second, 250, 0),
+			ftp:    250,
+		},
+		"no power": {
+			points: steadyPoints(time.Hour, time.Second, 0, 140),
+			ftp:    250,
+		},
+		"no ftp": {
+			points: steadyPoints(time.Hour, time.Second, 250, 0),
+			ftp:    0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			stress, ok := PowerStress(tc.points, tc.ftp)
+			if ok != tc.expectedOK {
+				t.Fatalf("expected ok %v, got %v", tc.expectedOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if stress.Method != tc.expected.Method ||
+				math.Abs(stress.Score-tc.expected.Score) > 1e-6 ||
+				math.Abs(stress.NormalizedPower-tc.expected.NormalizedPower) > 1e-6 ||
+				math.Abs(stress.Intensity-tc.expected.Intensity) > 1e-6 {
+				t.Fatalf("expected %+v, got %+v", tc.expected, stress)
+			}
+		})
+	}
+}
+
+func TestTRIMPStress(t *testing.T) {
+	// half of the heart rate reserve for an hour
+	halfReserveHour := 60 * 0.5 * 0.64 * math.Exp(1.92*0.5)
+
+	testCases := map[string]struct {
+		points     []track.Point
+		expected   float64
+		expectedOK bool
+	}{
+		"an hour at half reserve": {
+			points:     steadyPoints(time.Hour+10*time.Second, 10*time.Second, 0, 120),
+			expected:   halfReserveHour,
+			expectedOK: true,
+		},
+		"pauses are not counted": {
+			points: append(
+				steadyPoints(time.Hour+10*time.Second, 10*time.Second, 0, 120),
+				track.Point{Time: start.Add(2 * time.Hour)},
+			),
+			expected:   halfReserveHour,
+			expectedOK: true,
+		},
+		"above max is capped": {
+			points:     steadyPoints(time.Hour+10*time.Second, 10*time.Second, 0, 200),
+			expected:   60 * 0.64 * math.Exp(1.92),
+			expectedOK: true,
+		},
+		"no heart rate": {
+			points: steadyPoints(time.Hour, 10*time.Second, 250, 0),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			stress, ok := TRIMPStress(tc.points, 190, 50)
+			if ok != tc.expectedOK {
+				t.Fatalf("expected ok %v, got %v", tc.expectedOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if stress.Method != MethodTRIMP || math.Abs(stress.Score-tc.expected) > 1e-6 {
+				t.Fatalf("expected trimp score %g, got %+v", tc.expected, stress)
+			}
+		})
+	}
+}
+
+func TestSeries(t *testing.T) {
+	day := func(n int) time.Time {
+		return time.Date(2023, time.June, n, 0, 0, 0, 0, time.UTC)
+	}
+
+	fitness1, fatigue1 := 100.0/FitnessDays, 100.0/FatigueDays
+	fitness2, fatigue2 := fitness1*(FitnessDays-1)/FitnessDays, fatigue1*(FatigueDays-1)/FatigueDays
+
+	testCases := map[string]struct {
+		previous Day
+		stress   map[time.Time]float64
+		last     time.Time
+		expected []Day
+	}{
+		"no days": {
+			previous: Day{Date: day(1)},
+			last:     day(1),
+			expected: nil,
+		},
+		"from nothing": {
+			previous: Day{Date: day(1)},
+			stress:   map[time.Time]float64{day(2): 100},
+			last:     day(3),
+			expected: []Day{
+				{Date: day(2), Stress: 100, Fitness: fitness1, Fatigue: fatigue1, Form: 0},
+				{Date: day(3), Stress: 0, Fitness: fitness2, Fatigue: fatigue2, Form: fitness1 - fatigue1},
+			},
+		},
+		"continued from a previous day": {
+			previous: Day{Date: day(2), Fitness: fitness1, Fatigue: fatigue1},
+			last:     day(3),
+			expected: []Day{
+				{Date: day(3), Stress: 0, Fitness: fitness2, Fatigue: fatigue2, Form: fitness1 - fatigue1},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			days := Series(tc.previous, tc.stress, tc.last)
+			if len(days) != len(tc.expected) {
+				t.Fatalf("expected %d days, got %d", len(tc.expected), len(days))
+			}
+			for i, e := range tc.expected {
+				d := days[i]
+				if !d.Date.Equal(e.Date) ||
+					math.Abs(d.Stress-e.Stress) > 1e-9 ||
+					math.Abs(d.Fitness-e.Fitness) > 1e-9 ||
+					math.Abs(d.Fatigue-e.Fatigue) > 1e-9 ||
+					math.Abs(d.Form-e.Form) > 1e-9 {
+					t.Fatalf("day %d: expected %+v, got %+v", i, e, d)
+				}
+			}
+		})
+	}
+}
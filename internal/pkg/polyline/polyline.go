@@ -0,0 +1,99 @@
+package polyline
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// precision is the factor used by the Google encoded polyline format, as
+// used by Strava, for 5 decimal places
+const precision = 1e5
+
+// Decode decodes a Google encoded polyline into points
+func Decode(encoded string) ([]geo.Point, error) {
+	var points []geo.Point
+	var lat, lon int64
+
+	for i := 0; i < len(encoded); {
+		dLat, n, err := decodeValue(encoded[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+
+		dLon, n, err := decodeValue(encoded[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+
+		lat += dLat
+		lon += dLon
+		points = append(points, geo.Point{
+			Lat: float64(lat) / precision,
+			Lon: float64(lon) / precision,
+		})
+	}
+
+	return points, nil
+}
+
+// decodeValue decodes a single signed value from the start of s, returning
+// the number of bytes read
+func decodeValue(s string) (int64, int, error) {
+	var result int64
+	var shift uint
+
+	for i := 0; i < len(s); i++ {
+		b := int64(s[i]) - 63
+		if b < 0 || b > 0x3f {
+			return 0, 0, fmt.Errorf("invalid polyline character %q", s[i])
+		}
+
+		result |= (b & 0x1f) << shift
+		shift += 5
+
+		if b < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), i + 1, nil
+			}
+			return result >> 1, i + 1, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("truncated polyline")
+}
+
+// Encode encodes points as a Google encoded polyline
+func Encode(points []geo.Point) string {
+	var b strings.Builder
+	var lastLat, lastLon int64
+
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * precision))
+		lon := int64(math.Round(p.Lon * precision))
+
+		encodeValue(&b, lat-lastLat)
+		encodeValue(&b, lon-lastLon)
+
+		lastLat, lastLon = lat, lon
+	}
+
+	return b.String()
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	v <<= 1
+	if v < 0 {
+		v = ^v
+	}
+
+	for v >= 0x20 {
+		b.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	b.WriteByte(byte(v + 63))
+}
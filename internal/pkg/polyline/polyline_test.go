@@ -0,0 +1,81 @@
+package polyline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+func TestDecodeEncode(t *testing.T) {
+	testCases := map[string]struct {
+		encoded string
+		points  []geo.Point
+	}{
+		"empty": {
+			encoded: "",
+			points:  nil,
+		},
+		"single point": {
+			encoded: "_p~iF~ps|U",
+			points:  []geo.Point{{Lat: 38.5, Lon: -120.2}},
+		},
+		"documented example": {
+			// the example from Google's encoded polyline format docs
+			encoded: "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+			points: []geo.Point{
+				{Lat: 38.5, Lon: -120.2},
+				{Lat: 40.7, Lon: -120.95},
+				{Lat: 43.252, Lon: -126.453},
+			},
+		},
+		"repeated point": {
+			encoded: "_p~iF~ps|U??",
+			points: []geo.Point{
+				{Lat: 38.5, Lon: -120.2},
+				{Lat: 38.5, Lon: -120.2},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			points, err := Decode(tc.encoded)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(points, tc.points) {
+				t.Fatalf("expected %v, got %v", tc.points, points)
+			}
+
+			if encoded := Encode(tc.points); encoded != tc.encoded {
+				t.Fatalf("expected %q, got %q", tc.encoded, encoded)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	testCases := map[string]struct {
+		encoded string
+	}{
+		"truncated value": {
+			encoded: "_p~iF~ps|",
+		},
+		"missing longitude": {
+			encoded: "_p~iF",
+		},
+		"invalid character": {
+			encoded: "_p~iF ps|U",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(tc.encoded)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
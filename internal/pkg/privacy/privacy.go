@@ -0,0 +1,278 @@
+// Package privacy removes locations from tracks and routes before they are
+// shared, such as the area around home and work
+package privacy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/config"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// minTrimFraction is the fraction of the jitter always trimmed from the
+// start and end of each route
+const minTrimFraction = 0.5
+
+// Zone is a circle in which points are hidden
+type Zone struct {
+	Name   string
+	Center geo.Point
+	// Radius is in metres
+	Radius float64
+}
+
+// Contains returns true if the point is inside the zone
+func (z Zone) Contains(p geo.Point) bool {
+	return geo.Distance(z.Center, p) <= z.Radius
+}
+
+// Policy is the set of zones to hide and the amount of jitter to apply to
+// the start and end of each route. A nil Policy hides nothing.
+type Policy struct {
+	Zones []Zone
+	// Jitter is the most distance in metres trimmed from the start and end
+	// of each route, so the exact start and end are not revealed even when
+	// they are outside a zone. At least half of it is always trimmed.
+	Jitter float64
+}
+
+// ParseConfig reads a policy from the privacy section of the tool config,
+// which has a list of zones with a name, lat, lon and radius, and an
+// optional jitter in metres. Nil is returned when the section is missing.
+func ParseConfig(data any) (*Policy, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	section, ok := data.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("privacy config must be a map")
+	}
+
+	var p Policy
+
+	if jitter, ok := section["jitter"]; ok {
+		p.Jitter, ok = config.Float(jitter)
+		if !ok || p.Jitter < 0 {
+			return nil, fmt.Errorf("privacy jitter must be a positive number")
+		}
+	}
+
+	zones, _ := section["zones"].([]any)
+	for i, z := range zones {
+		zone, ok := z.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("privacy zone %d must be a map", i)
+		}
+
+		name, _ := zone["name"].(string)
+		lat, latOK := config.Float(zone["lat"])
+		lon, lonOK := config.Float(zone["lon"])
+		radius, radiusOK := config.Float(zone["radius"])
+		if !latOK || !lonOK || !radiusOK || radius <= 0 {
+			return nil, fmt.Errorf("privacy zone %d must have a lat, lon and positive radius", i)
+		}
+
+		p.Zones = append(p.Zones, Zone{
+			Name:   name,
+			Center: geo.Point{Lat: lat, Lon: lon},
+			Radius: radius,
+		})
+	}
+
+	return &p, nil
+}
+
+// Enabled returns true if the policy hides anything
+func (p *Policy) Enabled() bool {
+	return p != nil && (len(p.Zones) > 0 || p.Jitter > 0)
+}
+
+// Digest identifies the policy, it changes when the zones or jitter change
+// so outputs stored under an old policy can be regenerated
+func (p *Policy) Digest() string {
+	if !p.Enabled() {
+		return ""
+	}
+
+	h := fnv.New64a()
+	// the digest covers the jitter, its minimum trim and each zone
+	fmt.Fprintf(h, "jitter:%g,min:%g;", p.Jitter, minTrimFraction)
+	for _, z := range p.Zones {
+		fmt.Fprintf(h, "zone:%g,%g,%g;", z.Center.Lat, z.Center.Lon, z.Radius)
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// InZone returns true if the point is inside any of the zones
+func (p *Policy) InZone(point geo.Point) bool {
+	if p == nil {
+		return false
+	}
+	for _, z := range p.Zones {
+		if z.Contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps returns true if any of the zones overlaps the bounding box, for
+// outputs such as map tiles which cover an area rather than a point
+func (p *Policy) Overlaps(box geo.BBox) bool {
+	if p == nil {
+		return false
+	}
+	for _, z := range p.Zones {
+		nearest := geo.Point{
+			Lat: math.Max(box.MinLat, math.Min(box.MaxLat, z.Center.Lat)),
+			Lon: math.Max(box.MinLon, math.Min(box.MaxLon, z.Center.Lon)),
+		}
+		if z.Contains(nearest) {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible returns which points of the line are shown under the policy, see
+// Line for the seed
+func (p *Policy) Visible(line []geo.Point, seed string) []bool {
+	if !p.Enabled() {
+		visible := make([]bool, len(line))
+		for i := range visible {
+			visible[i] = true
+		}
+		return visible
+	}
+
+	positions := make([]*geo.Point, len(line))
+	for i := range line {
+		positions[i] = &line[i]
+	}
+
+	return p.keep(positions, seed)
+}
+
+// Line returns the parts of the line which are outside the zones and
+// jitter. The line is split where it passes through a zone so the hidden
+// section isn't drawn as a straight line. The seed, usually the activity
+// ID, makes the jitter the same each time the line is filtered.
+func (p *Policy) Line(line []geo.Point, seed string) [][]geo.Point {
+	if !p.Enabled() {
+		if len(line) == 0 {
+			return nil
+		}
+		return [][]geo.Point{line}
+	}
+
+	var segments [][]geo.Point
+	var segment []geo.Point
+	for i, keep := range p.Visible(line, seed) {
+		if !keep {
+			if len(segment) > 0 {
+				segments = append(segments, segment)
+			}
+			segment = nil
+			continue
+		}
+		segment = append(segment, line[i])
+	}
+	if len(segment) > 0 {
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+// Track returns a copy of the track without the points in the zones or
+// jitter. Points without a position are kept unless they are in the
+// jittered start or end.
+func (p *Policy) Track(t *track.Track, seed string) *track.Track {
+	if !p.Enabled() {
+		return t
+	}
+
+	positions := make([]*geo.Point, len(t.Points))
+	for i := range t.Points {
+		positions[i] = t.Points[i].Position
+	}
+
+	filtered := *t
+	filtered.Points = nil
+	for i, keep := range p.keep(positions, seed) {
+		if keep {
+			filtered.Points = append(filtered.Points, t.Points[i])
+		}
+	}
+
+	return &filtered
+}
+
+// keep returns which of the positions are visible under the policy, nil
+// positions have no location and are only hidden by the jitter
+func (p *Policy) keep(positions []*geo.Point, seed string) []bool {
+	keep := make([]bool, len(positions))
+	for i, position := range positions {
+		keep[i] = true
+		if position != nil && p.InZone(*position) {
+			keep[i] = false
+		}
+	}
+
+	if p.Jitter <= 0 {
+		return keep
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	random := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	order := make([]int, len(positions))
+	for i := range order {
+		order[i] = i
+	}
+	trim(keep, positions, order, p.jitterDistance(random))
+
+	for i := range order {
+		order[i] = len(positions) - 1 - i
+	}
+	trim(keep, positions, order, p.jitterDistance(random))
+
+	return keep
+}
+
+// jitterDistance returns the distance to trim from one end of a route,
+// between the minimum trim and the jitter so the true start and end are
+// always hidden by at least the minimum
+func (p *Policy) jitterDistance(random *rand.Rand) float64 {
+	minimum := p.Jitter * minTrimFraction
+	return minimum + random.Float64()*(p.Jitter-minimum)
+}
+
+// trim walks the visible positions in order, hiding them until the distance
+// travelled reaches the distance to trim
+func trim(keep []bool, positions []*geo.Point, order []int, distance float64) {
+	var travelled float64
+	var last *geo.Point
+	for _, i := range order {
+		if !keep[i] {
+			continue
+		}
+		if positions[i] != nil {
+			if last != nil {
+				travelled += geo.Distance(*last, *positions[i])
+			}
+			last = positions[i]
+		}
+		if last != nil && travelled >= distance {
+			return
+		}
+		keep[i] = false
+	}
+}
@@ -0,0 +1,226 @@
+package privacy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// straightLine returns n points along the equator 0.001 degrees, about
+// 111 metres, apart
+func straightLine(n int) []geo.Point {
+	line := make([]geo.Point, n)
+	for i := range line {
+		line[i] = geo.Point{Lat: 0, Lon: float64(i) * 0.001}
+	}
+	return line
+}
+
+func TestLineZones(t *testing.T) {
+	line := straightLine(11)
+
+	testCases := map[string]struct {
+		policy   *Policy
+		expected [][]geo.Point
+	}{
+		"nil policy": {
+			policy:   nil,
+			expected: [][]geo.Point{line},
+		},
+		"empty policy": {
+			policy:   &Policy{},
+			expected: [][]geo.Point{line},
+		},
+		"zone in the middle": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0, Lon: 0.005}, Radius: 150},
+			}},
+			expected: [][]geo.Point{line[:4], line[7:]},
+		},
+		"zone at the start": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0, Lon: 0}, Radius: 250},
+			}},
+			expected: [][]geo.Point{line[3:]},
+		},
+		"zone away from the line": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 1, Lon: 0.005}, Radius: 150},
+			}},
+			expected: [][]geo.Point{line},
+		},
+		"line inside a zone": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0, Lon: 0.005}, Radius: 1000},
+			}},
+			expected: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			segments := tc.policy.Line(line, "seed")
+			if !reflect.DeepEqual(segments, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, segments)
+			}
+		})
+	}
+}
+
+func TestLineJitter(t *testing.T) {
+	line := straightLine(101)
+	spacing := geo.Distance(line[0], line[1])
+
+	testCases := map[string]struct {
+		jitter float64
+		seed   string
+	}{
+		"small jitter": {
+			jitter: 300,
+			seed:   "1",
+		},
+		"large jitter": {
+			jitter: 2000,
+			seed:   "1",
+		},
+		"another seed": {
+			jitter: 2000,
+			seed:   "2",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			policy := &Policy{Jitter: tc.jitter}
+
+			segments := policy.Line(line, tc.seed)
+			if len(segments) != 1 {
+				t.Fatalf("expected one segment, got %d", len(segments))
+			}
+			visible := segments[0]
+
+			// at least half the jitter is always trimmed, and no more than
+			// the jitter plus the point which reaches it
+			for end, distance := range map[string]float64{
+				"start": geo.Distance(line[0], visible[0]),
+				"end":   geo.Distance(line[len(line)-1], visible[len(visible)-1]),
+			} {
+				if distance < tc.jitter/2 || distance > tc.jitter+spacing {
+					t.Fatalf("expected %s trimmed by %g to %g metres, got %g", end, tc.jitter/2, tc.jitter, distance)
+				}
+			}
+
+			if again := policy.Line(line, tc.seed); !reflect.DeepEqual(again, segments) {
+				t.Fatalf("expected the same seed to trim the same points")
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	testCases := map[string]struct {
+		config   any
+		expected *Policy
+		err      bool
+	}{
+		"missing": {
+			config:   nil,
+			expected: nil,
+		},
+		"zones and jitter": {
+			config: map[string]any{
+				"jitter": 200,
+				"zones": []any{
+					map[string]any{"name": "home", "lat": 51.5, "lon": -0.1, "radius": 300},
+				},
+			},
+			expected: &Policy{
+				Jitter: 200,
+				Zones: []Zone{
+					{Name: "home", Center: geo.Point{Lat: 51.5, Lon: -0.1}, Radius: 300},
+				},
+			},
+		},
+		"not a map": {
+			config: "zones",
+			err:    true,
+		},
+		"negative jitter": {
+			config: map[string]any{"jitter": -1},
+			err:    true,
+		},
+		"zone without radius": {
+			config: map[string]any{
+				"zones": []any{
+					map[string]any{"name": "home", "lat": 51.5, "lon": -0.1},
+				},
+			},
+			err: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			policy, err := ParseConfig(tc.config)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(policy, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, policy)
+			}
+		})
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	box := geo.BBox{MinLat: 0, MinLon: 0, MaxLat: 0.01, MaxLon: 0.01}
+
+	testCases := map[string]struct {
+		policy   *Policy
+		expected bool
+	}{
+		"nil policy": {
+			policy:   nil,
+			expected: false,
+		},
+		"zone inside the box": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0.005, Lon: 0.005}, Radius: 100},
+			}},
+			expected: true,
+		},
+		"zone reaching over an edge": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0.005, Lon: 0.011}, Radius: 150},
+			}},
+			expected: true,
+		},
+		"zone near a corner": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 0.011, Lon: 0.011}, Radius: 150},
+			}},
+			expected: false,
+		},
+		"zone away from the box": {
+			policy: &Policy{Zones: []Zone{
+				{Center: geo.Point{Lat: 1, Lon: 1}, Radius: 1000},
+			}},
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.policy.Overlaps(box); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
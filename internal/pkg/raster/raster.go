@@ -0,0 +1,97 @@
+// Package raster draws antialiased lines into images without any
+// dependencies beyond the standard library
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Vertex is a point in image coordinates
+type Vertex struct {
+	X, Y float64
+}
+
+// Mask holds the coverage of a shape for each pixel. Shapes are drawn into
+// the mask before painting so overlapping lines don't darken the stroke.
+type Mask struct {
+	Width, Height int
+	Coverage      []float64
+}
+
+// NewMask returns an empty mask of the given size
+func NewMask(width, height int) *Mask {
+	return &Mask{Width: width, Height: height, Coverage: make([]float64, width*height)}
+}
+
+// Clear removes everything drawn into the mask
+func (m *Mask) Clear() {
+	for i := range m.Coverage {
+		m.Coverage[i] = 0
+	}
+}
+
+// Line adds a line from a to b with round caps, the edges are antialiased
+// using the distance from each pixel centre to the line. Lines outside the
+// mask are clipped.
+func (m *Mask) Line(a, b Vertex, halfWidth float64) {
+	minX := int(math.Floor(math.Min(a.X, b.X) - halfWidth - 1))
+	maxX := int(math.Ceil(math.Max(a.X, b.X) + halfWidth + 1))
+	minY := int(math.Floor(math.Min(a.Y, b.Y) - halfWidth - 1))
+	maxY := int(math.Ceil(math.Max(a.Y, b.Y) + halfWidth + 1))
+
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := dx*dx + dy*dy
+
+	for y := maxInt(minY, 0); y <= minInt(maxY, m.Height-1); y++ {
+		for x := maxInt(minX, 0); x <= minInt(maxX, m.Width-1); x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+
+			t := 0.0
+			if length > 0 {
+				t = math.Max(0, math.Min(1, ((px-a.X)*dx+(py-a.Y)*dy)/length))
+			}
+			distance := math.Hypot(px-(a.X+t*dx), py-(a.Y+t*dy))
+
+			coverage := math.Max(0, math.Min(1, halfWidth+0.5-distance))
+			i := y*m.Width + x
+			if coverage > m.Coverage[i] {
+				m.Coverage[i] = coverage
+			}
+		}
+	}
+}
+
+// Paint blends the colour into the image using the mask's coverage
+func (m *Mask) Paint(img *image.RGBA, c color.RGBA) {
+	for i, coverage := range m.Coverage {
+		if coverage == 0 {
+			continue
+		}
+		alpha := coverage * float64(c.A) / 0xff
+		p := img.Pix[i*4 : i*4+4]
+		p[0] = blend(p[0], c.R, alpha)
+		p[1] = blend(p[1], c.G, alpha)
+		p[2] = blend(p[2], c.B, alpha)
+		p[3] = blend(p[3], 0xff, alpha)
+	}
+}
+
+func blend(dst, src uint8, alpha float64) uint8 {
+	return uint8(math.Round(float64(dst)*(1-alpha) + float64(src)*alpha))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
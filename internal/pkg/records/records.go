@@ -0,0 +1,174 @@
+// Package records finds the values of an activity which may be personal
+// records and compares them against the history of previous activities
+package records
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// Categories of records
+const (
+	BestEffort   = "best_effort"
+	Power        = "power"
+	Distance     = "distance"
+	Climb        = "climb"
+	AverageSpeed = "average_speed"
+)
+
+// minAverageSpeedDistance is the shortest activity in metres which counts
+// for the fastest average, shorter activities are often warm ups or
+// recording mistakes
+const minAverageSpeedDistance = 1000
+
+// maxGap is the longest time between points which counts as moving, longer
+// gaps are pauses in recording
+const maxGap = 30 * time.Second
+
+// Value is a value of an activity which is compared against previous
+// activities of the same sport
+type Value struct {
+	Sport    string
+	Category string
+	// Key distinguishes values in a category, such as the distance of a
+	// best effort or the duration of a power
+	Key   string
+	Value float64
+}
+
+// ID identifies the record the value is compared against
+func (v Value) ID() string {
+	return fmt.Sprintf("%s/%s/%s", v.Sport, v.Category, v.Key)
+}
+
+// Better returns true when value a beats value b, best efforts are times
+// so lower is better while everything else is higher
+func Better(category string, a, b float64) bool {
+	if category == BestEffort {
+		return a < b
+	}
+	return a > b
+}
+
+// TrackValues returns the distance, climb and average moving speed of the
+// points. The average speed is not included for short activities.
+func TrackValues(sport string, points []track.Point) []Value {
+	summary := (&track.Track{Points: points}).Summarise()
+
+	var values []Value
+	if summary.Distance > 0 {
+		values = append(values, Value{Sport: sport, Category: Distance, Value: summary.Distance})
+	}
+	if summary.Ascent > 0 {
+		values = append(values, Value{Sport: sport, Category: Climb, Value: summary.Ascent})
+	}
+
+	moving := MovingTime(points)
+	if summary.Distance >= minAverageSpeedDistance && moving > 0 {
+		values = append(values, Value{Sport: sport, Category: AverageSpeed, Value: summary.Distance / moving})
+	}
+
+	return values
+}
+
+// MovingTime returns the seconds between points where the distance
+// increased, pauses in recording are not counted
+func MovingTime(points []track.Point) float64 {
+	seconds := 0.0
+	for i := 0; i < len(points)-1; i++ {
+		a, b := points[i], points[i+1]
+		if a.Distance == nil || b.Distance == nil || *b.Distance <= *a.Distance {
+			continue
+		}
+
+		gap := b.Time.Sub(a.Time)
+		if gap <= 0 || gap > maxGap {
+			continue
+		}
+
+		seconds += gap.Seconds()
+	}
+
+	return seconds
+}
+
+// History holds the values of previous activities
+type History struct {
+	entries map[string][]entry
+}
+
+type entry struct {
+	activityID string
+	timestamp  time.Time
+	value      float64
+}
+
+// Record is a value which beat all values of earlier activities
+type Record struct {
+	Value
+	// Previous is the value and activity of the previous record, empty for
+	// the first activity with the value
+	PreviousValue      *float64
+	PreviousActivityID string
+}
+
+// NewHistory returns an empty history
+func NewHistory() *History {
+	return &History{entries: make(map[string][]entry)}
+}
+
+// Add adds the value of an activity to the history
+func (h *History) Add(activityID string, timestamp time.Time, v Value) {
+	h.entries[v.ID()] = append(h.entries[v.ID()], entry{
+		activityID: activityID,
+		timestamp:  timestamp,
+		value:      v.Value,
+	})
+}
+
+// Remove removes all the values of an activity from the history
+func (h *History) Remove(activityID string) {
+	for id, entries := range h.entries {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.activityID != activityID {
+				kept = append(kept, e)
+			}
+		}
+		h.entries[id] = kept
+	}
+}
+
+// Compare returns the values which beat the same values of all activities
+// before the timestamp
+func (h *History) Compare(timestamp time.Time, values []Value) []Record {
+	var found []Record
+	for _, v := range values {
+		var best *entry
+		for i, e := range h.entries[v.ID()] {
+			if !e.timestamp.Before(timestamp) {
+				continue
+			}
+			if best == nil || Better(v.Category, e.value, best.value) {
+				best = &h.entries[v.ID()][i]
+			}
+		}
+
+		if best == nil {
+			found = append(found, Record{Value: v})
+			continue
+		}
+		if Better(v.Category, v.Value, best.value) {
+			previous := best.value
+			found = append(found, Record{
+				Value:              v,
+				PreviousValue:      &previous,
+				PreviousActivityID: best.activityID,
+			})
+		}
+	}
+
+	return found
+}
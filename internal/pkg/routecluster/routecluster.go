@@ -0,0 +1,120 @@
+// Package routecluster groups activities which follow substantially the
+// same path, using the Hausdorff distance between simplified routes, so
+// performances on repeated routes can be compared
+package routecluster
+
+import (
+	"math"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+const (
+	// SimplifyTolerance in metres is used to simplify routes before they
+	// are compared
+	SimplifyTolerance = 20.0
+	// MaxDistance in metres is the largest Hausdorff distance between two
+	// routes which are the same
+	MaxDistance = 100.0
+	// EndpointRadius in metres is the distance the start and end of two
+	// routes must be within, so routes in opposite directions differ
+	EndpointRadius = 200.0
+	// LengthTolerance is the largest difference in length, as a fraction of
+	// the longer route
+	LengthTolerance = 0.1
+	// MinLength in metres excludes routes too short to compare
+	MinLength = 500.0
+
+	// step in metres at which segments are interpolated when measuring the
+	// distance from one route to another
+	step = 25.0
+)
+
+// Route is a simplified route
+type Route struct {
+	Points []geo.Point
+	Length float64
+	Bounds geo.BBox
+}
+
+// NewRoute simplifies the line, false is returned when it is too short to
+// be clustered
+func NewRoute(line []geo.Point) (Route, bool) {
+	if len(line) < 2 {
+		return Route{}, false
+	}
+
+	length := 0.0
+	for i := 1; i < len(line); i++ {
+		length += geo.Distance(line[i-1], line[i])
+	}
+	if length < MinLength {
+		return Route{}, false
+	}
+
+	points := geo.Simplify(line, SimplifyTolerance)
+	bounds, _ := geo.Bounds(points)
+
+	return Route{
+		Points: points,
+		Length: length,
+		Bounds: bounds,
+	}, true
+}
+
+// Start returns the first point of the route
+func (r Route) Start() geo.Point {
+	return r.Points[0]
+}
+
+// End returns the last point of the route
+func (r Route) End() geo.Point {
+	return r.Points[len(r.Points)-1]
+}
+
+// Same returns true when the routes follow the same path in the same
+// direction, and the Hausdorff distance between them when it was measured
+func Same(a, b Route) (bool, float64) {
+	longest := math.Max(a.Length, b.Length)
+	if math.Abs(a.Length-b.Length) > longest*LengthTolerance {
+		return false, 0
+	}
+
+	if geo.Distance(a.Start(), b.Start()) > EndpointRadius ||
+		geo.Distance(a.End(), b.End()) > EndpointRadius {
+		return false, 0
+	}
+
+	distance := Hausdorff(a, b, MaxDistance)
+
+	return distance <= MaxDistance, distance
+}
+
+// Hausdorff returns the largest distance in metres from any point on
+// either route to the other route. Measuring stops once the distance
+// exceeds limit, as the routes are then known to differ.
+func Hausdorff(a, b Route, limit float64) float64 {
+	distance := directed(a, b, limit)
+	if distance > limit {
+		return distance
+	}
+
+	return math.Max(distance, directed(b, a, limit))
+}
+
+// directed returns the largest distance from a point on a to b
+func directed(a, b Route, limit float64) float64 {
+	furthest := geo.DistanceToLine(a.Points[0], b.Points)
+
+	for i := 1; i < len(a.Points); i++ {
+		points := append(geo.Interpolate(a.Points[i-1], a.Points[i], step), a.Points[i])
+		for _, p := range points {
+			furthest = math.Max(furthest, geo.DistanceToLine(p, b.Points))
+			if furthest > limit {
+				return furthest
+			}
+		}
+	}
+
+	return furthest
+}
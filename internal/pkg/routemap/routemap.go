@@ -0,0 +1,197 @@
+// Package routemap draws activity routes as standalone images, without any
+// map tiles, for use as thumbnails
+package routemap
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/raster"
+)
+
+// Options control the size and style of a rendered map
+type Options struct {
+	Width       int
+	Height      int
+	Padding     float64
+	StrokeWidth float64
+
+	Background color.RGBA
+	Stroke     color.RGBA
+	Start      color.RGBA
+	End        color.RGBA
+}
+
+// DefaultOptions are used for the maps stored for each activity
+var DefaultOptions = Options{
+	Width:       400,
+	Height:      300,
+	Padding:     16,
+	StrokeWidth: 3,
+
+	Background: color.RGBA{R: 0xf6, G: 0xf6, B: 0xf4, A: 0xff},
+	Stroke:     color.RGBA{R: 0xfc, G: 0x4c, B: 0x02, A: 0xff},
+	Start:      color.RGBA{R: 0x2e, G: 0x9e, B: 0x44, A: 0xff},
+	End:        color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff},
+}
+
+// simplifyPixels is the tolerance used to simplify routes before drawing,
+// detail smaller than this is not visible at the rendered size
+const simplifyPixels = 0.5
+
+// project simplifies the route and fits it into the image using a web
+// mercator projection, which is what people expect routes to look like
+func project(lines [][]geo.Point, opts Options) [][]raster.Vertex {
+	var all []geo.Point
+	for _, line := range lines {
+		all = append(all, line...)
+	}
+	bounds, ok := geo.Bounds(all)
+	if !ok {
+		return nil
+	}
+
+	mercator := func(p geo.Point) (float64, float64) {
+		lat := math.Max(-85, math.Min(85, p.Lat)) * math.Pi / 180
+		return p.Lon * math.Pi / 180, math.Log(math.Tan(math.Pi/4 + lat/2))
+	}
+
+	minX, maxY := mercator(geo.Point{Lat: bounds.MaxLat, Lon: bounds.MinLon})
+	maxX, minY := mercator(geo.Point{Lat: bounds.MinLat, Lon: bounds.MaxLon})
+
+	drawWidth := float64(opts.Width) - 2*opts.Padding
+	drawHeight := float64(opts.Height) - 2*opts.Padding
+
+	// routes with no extent, such as a single point, are drawn in the centre
+	scale := math.Inf(1)
+	if maxX > minX {
+		scale = drawWidth / (maxX - minX)
+	}
+	if maxY > minY {
+		scale = math.Min(scale, drawHeight/(maxY-minY))
+	}
+	if math.IsInf(scale, 1) {
+		scale = 0
+	}
+
+	// one mercator unit is EarthRadius metres at the equator and shrinks
+	// with the cosine of the latitude
+	midLat := (bounds.MinLat + bounds.MaxLat) / 2 * math.Pi / 180
+	metresPerPixel := geo.EarthRadius * math.Cos(midLat) / scale
+
+	offsetX := opts.Padding + (drawWidth-(maxX-minX)*scale)/2
+	offsetY := opts.Padding + (drawHeight-(maxY-minY)*scale)/2
+
+	var projected [][]raster.Vertex
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if scale > 0 {
+			line = geo.Simplify(line, simplifyPixels*metresPerPixel)
+		}
+
+		vertices := make([]raster.Vertex, len(line))
+		for i, p := range line {
+			x, y := mercator(p)
+			vertices[i] = raster.Vertex{
+				X: offsetX + (x-minX)*scale,
+				Y: offsetY + (maxY-y)*scale,
+			}
+		}
+		projected = append(projected, vertices)
+	}
+
+	return projected
+}
+
+// SVG renders the route as a standalone SVG document. The route is given as
+// one or more lines, gaps between the lines are not drawn.
+func SVG(lines [][]geo.Point, opts Options) []byte {
+	projected := project(lines, opts)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(
+		&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		opts.Width, opts.Height, opts.Width, opts.Height,
+	)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(opts.Background))
+
+	for _, vertices := range projected {
+		coordinates := make([]string, len(vertices))
+		for i, v := range vertices {
+			coordinates[i] = fmt.Sprintf("%.1f,%.1f", v.X, v.Y)
+		}
+		fmt.Fprintf(
+			&buf,
+			`<polyline points="%s" fill="none" stroke="%s" stroke-width="%g" stroke-linecap="round" stroke-linejoin="round"/>`,
+			strings.Join(coordinates, " "), hex(opts.Stroke), opts.StrokeWidth,
+		)
+	}
+
+	if len(projected) > 0 {
+		last := projected[len(projected)-1]
+		start, end := projected[0][0], last[len(last)-1]
+		radius := opts.StrokeWidth * 1.5
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%g" fill="%s"/>`, end.X, end.Y, radius, hex(opts.End))
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%g" fill="%s"/>`, start.X, start.Y, radius, hex(opts.Start))
+	}
+
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
+
+// PNG renders the route as a PNG image, drawing the same shapes as SVG
+func PNG(lines [][]geo.Point, opts Options) ([]byte, error) {
+	projected := project(lines, opts)
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] =
+			opts.Background.R, opts.Background.G, opts.Background.B, opts.Background.A
+	}
+
+	if len(projected) > 0 {
+		mask := raster.NewMask(opts.Width, opts.Height)
+		for _, vertices := range projected {
+			for i := 1; i < len(vertices); i++ {
+				mask.Line(vertices[i-1], vertices[i], opts.StrokeWidth/2)
+			}
+			if len(vertices) == 1 {
+				mask.Line(vertices[0], vertices[0], opts.StrokeWidth/2)
+			}
+		}
+		mask.Paint(img, opts.Stroke)
+
+		last := projected[len(projected)-1]
+		radius := opts.StrokeWidth * 1.5
+
+		end := raster.NewMask(opts.Width, opts.Height)
+		end.Line(last[len(last)-1], last[len(last)-1], radius)
+		end.Paint(img, opts.End)
+
+		start := raster.NewMask(opts.Width, opts.Height)
+		start.Line(projected[0][0], projected[0][0], radius)
+		start.Paint(img, opts.Start)
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,270 @@
+// Package segments finds efforts on user defined segments, sections of
+// road or trail which are timed each time a track passes along them
+package segments
+
+import (
+	"math"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/routecluster"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+const (
+	// EndpointRadius in metres is how close a track must pass to the start
+	// and end of a segment
+	EndpointRadius = 30.0
+	// MaxDeviation in metres is the largest Hausdorff distance between the
+	// segment and the matching section of a track
+	MaxDeviation = 50.0
+	// MinLength in metres excludes segments too short to time reliably
+	MinLength = 100.0
+
+	// simplifyTolerance in metres is used to simplify segment lines
+	simplifyTolerance = 5.0
+)
+
+// Segment is a line which tracks are matched against
+type Segment struct {
+	Points []geo.Point
+	// Length in metres
+	Length float64
+	Bounds geo.BBox
+}
+
+// NewSegment simplifies the line, false is returned when it is shorter than
+// MinLength
+func NewSegment(line []geo.Point) (Segment, bool) {
+	length := lineLength(line)
+	if len(line) < 2 || length < MinLength {
+		return Segment{}, false
+	}
+
+	points := geo.Simplify(line, simplifyTolerance)
+	bounds, _ := geo.Bounds(points)
+
+	return Segment{
+		Points: points,
+		Length: length,
+		Bounds: bounds,
+	}, true
+}
+
+// Start returns the first point of the segment
+func (s Segment) Start() geo.Point {
+	return s.Points[0]
+}
+
+// End returns the last point of the segment
+func (s Segment) End() geo.Point {
+	return s.Points[len(s.Points)-1]
+}
+
+// Section returns the points of the line between the points closest to
+// start and end, where end comes after start. False is returned when the
+// line has no points near both.
+func Section(line []geo.Point, start, end geo.Point) ([]geo.Point, bool) {
+	first := closest(line, start, 0)
+	if first == -1 || geo.Distance(line[first], start) > EndpointRadius {
+		return nil, false
+	}
+
+	last := closest(line, end, first+1)
+	if last == -1 || geo.Distance(line[last], end) > EndpointRadius {
+		return nil, false
+	}
+
+	return line[first : last+1], true
+}
+
+// Effort is a pass along a segment. Offsets are the indexes of the first
+// and last points in the track.
+type Effort struct {
+	StartOffset int
+	EndOffset   int
+
+	// ElapsedTime in seconds and Distance in metres along the track
+	ElapsedTime float64
+	Distance    float64
+
+	// AveragePower and AverageHeartRate are nil when not recorded
+	AveragePower     *float64
+	AverageHeartRate *float64
+}
+
+// Match returns the efforts along the segment in the track, in order
+func Match(segment Segment, points []track.Point) []Effort {
+	// cumulative distance along the track from its positions, so tracks
+	// without recorded distance can be matched
+	along := make([]float64, len(points))
+	var previous *geo.Point
+	for i, p := range points {
+		if i > 0 {
+			along[i] = along[i-1]
+		}
+		if p.Position == nil {
+			continue
+		}
+		if previous != nil {
+			along[i] += geo.Distance(*previous, *p.Position)
+		}
+		previous = p.Position
+	}
+
+	var efforts []Effort
+	for i := 0; i < len(points); {
+		start := nearestInZone(points, i, segment.Start())
+		if start == -1 {
+			break
+		}
+
+		effort, ok := matchFrom(segment, points, along, start)
+		if !ok {
+			// try the next pass through the start zone
+			i = leaveZone(points, start, segment.Start())
+			continue
+		}
+
+		efforts = append(efforts, effort)
+		i = effort.EndOffset + 1
+	}
+
+	return efforts
+}
+
+// matchFrom returns the effort starting at start, where the track passes
+// through the end zone after around the segment's length and follows the
+// segment in between
+func matchFrom(segment Segment, points []track.Point, along []float64, start int) (Effort, bool) {
+	minDistance := segment.Length * 0.8
+	maxDistance := segment.Length*1.5 + 2*EndpointRadius
+
+	for i := start + 1; i < len(points); {
+		distance := along[i] - along[start]
+		if distance > maxDistance {
+			return Effort{}, false
+		}
+		if distance < minDistance {
+			i++
+			continue
+		}
+
+		end := nearestInZone(points, i, segment.End())
+		if end == -1 || along[end]-along[start] > maxDistance {
+			return Effort{}, false
+		}
+
+		var section []geo.Point
+		for _, p := range points[start : end+1] {
+			if p.Position != nil {
+				section = append(section, *p.Position)
+			}
+		}
+
+		deviation := routecluster.Hausdorff(
+			routecluster.Route{Points: segment.Points},
+			routecluster.Route{Points: section},
+			MaxDeviation,
+		)
+		if deviation <= MaxDeviation {
+			return newEffort(points, along, start, end), true
+		}
+
+		i = leaveZone(points, end, segment.End())
+	}
+
+	return Effort{}, false
+}
+
+// newEffort times the points from start to end and averages the power and
+// heart rate, weighted by the time between points
+func newEffort(points []track.Point, along []float64, start, end int) Effort {
+	effort := Effort{
+		StartOffset: start,
+		EndOffset:   end,
+		ElapsedTime: points[end].Time.Sub(points[start].Time).Seconds(),
+		Distance:    along[end] - along[start],
+	}
+
+	var power, powerTime, heartRate, heartRateTime float64
+	for i := start + 1; i <= end; i++ {
+		dt := points[i].Time.Sub(points[i-1].Time).Seconds()
+		if p := points[i].Power; p != nil {
+			power += *p * dt
+			powerTime += dt
+		}
+		if hr := points[i].HeartRate; hr != nil {
+			heartRate += *hr * dt
+			heartRateTime += dt
+		}
+	}
+	if powerTime > 0 {
+		v := power / powerTime
+		effort.AveragePower = &v
+	}
+	if heartRateTime > 0 {
+		v := heartRate / heartRateTime
+		effort.AverageHeartRate = &v
+	}
+
+	return effort
+}
+
+// nearestInZone returns the index of the point closest to target in the
+// first pass within EndpointRadius of it from index from, or -1
+func nearestInZone(points []track.Point, from int, target geo.Point) int {
+	best, bestDistance := -1, math.Inf(1)
+	for i := from; i < len(points); i++ {
+		if points[i].Position == nil {
+			continue
+		}
+
+		d := geo.Distance(*points[i].Position, target)
+		if d > EndpointRadius {
+			if best != -1 {
+				break
+			}
+			continue
+		}
+		if d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+
+	return best
+}
+
+// leaveZone returns the index of the first point after from which is
+// outside EndpointRadius of target
+func leaveZone(points []track.Point, from int, target geo.Point) int {
+	i := from + 1
+	for ; i < len(points); i++ {
+		if points[i].Position != nil && geo.Distance(*points[i].Position, target) > EndpointRadius {
+			break
+		}
+	}
+
+	return i
+}
+
+// closest returns the index of the point in the line closest to target
+// from index from, or -1 when there are none
+func closest(line []geo.Point, target geo.Point, from int) int {
+	best, bestDistance := -1, math.Inf(1)
+	for i := from; i < len(line); i++ {
+		if d := geo.Distance(line[i], target); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+
+	return best
+}
+
+func lineLength(line []geo.Point) float64 {
+	length := 0.0
+	for i := 1; i < len(line); i++ {
+		length += geo.Distance(line[i-1], line[i])
+	}
+
+	return length
+}
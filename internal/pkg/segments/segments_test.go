@@ -0,0 +1,186 @@
+package segments
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+var start = time.Date(2023, time.June, 1, 8, 0, 0, 0, time.UTC)
+
+// path returns points about 11 metres apart along straight lines between
+// the waypoints, given as lat and lon pairs in thousandths of a degree
+func path(waypoints ...[2]float64) []geo.Point {
+	var points []geo.Point
+	for i := 1; i < len(waypoints); i++ {
+		from, to := waypoints[i-1], waypoints[i]
+		steps := int(math.Round(math.Max(math.Abs(to[0]-from[0]), math.Abs(to[1]-from[1])) * 10))
+		for s := 0; s < steps; s++ {
+			f := float64(s) / float64(steps)
+			points = append(points, geo.Point{
+				Lat: (from[0] + (to[0]-from[0])*f) / 1000,
+				Lon: (from[1] + (to[1]-from[1])*f) / 1000,
+			})
+		}
+	}
+	last := waypoints[len(waypoints)-1]
+	return append(points, geo.Point{Lat: last[0] / 1000, Lon: last[1] / 1000})
+}
+
+// trackPoints returns a point every two seconds at each position, with
+// power when it is not zero
+func trackPoints(positions []geo.Point, power float64) []track.Point {
+	points := make([]track.Point, len(positions))
+	for i := range positions {
+		points[i] = track.Point{
+			Time:     start.Add(time.Duration(2*i) * time.Second),
+			Position: &positions[i],
+		}
+		if power != 0 {
+			w := power
+			points[i].Power = &w
+		}
+	}
+	return points
+}
+
+func TestNewSegment(t *testing.T) {
+	testCases := map[string]struct {
+		line     []geo.Point
+		expected bool
+	}{
+		"long enough": {
+			line:     path([2]float64{0, 1}, [2]float64{0, 5}),
+			expected: true,
+		},
+		"too short": {
+			line:     path([2]float64{0, 1}, [2]float64{0, 1.5}),
+			expected: false,
+		},
+		"single point": {
+			line:     path([2]float64{0, 1}),
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, ok := NewSegment(tc.line)
+			if ok != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, ok)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	segment, ok := NewSegment(path([2]float64{0, 1}, [2]float64{0, 5}))
+	if !ok {
+		t.Fatal("expected a valid segment")
+	}
+
+	type effort struct {
+		startOffset int
+		endOffset   int
+		elapsedTime float64
+	}
+
+	testCases := map[string]struct {
+		points   []track.Point
+		expected []effort
+	}{
+		"single pass": {
+			points: trackPoints(path([2]float64{0, 0}, [2]float64{0, 6}), 0),
+			expected: []effort{
+				{startOffset: 10, endOffset: 50, elapsedTime: 80},
+			},
+		},
+		"two passes": {
+			// back along a parallel road north of the segment between passes
+			points: trackPoints(path(
+				[2]float64{0, 0}, [2]float64{0, 6},
+				[2]float64{2, 6}, [2]float64{2, 0},
+				[2]float64{0, 0}, [2]float64{0, 6},
+			), 0),
+			expected: []effort{
+				{startOffset: 10, endOffset: 50, elapsedTime: 80},
+				{startOffset: 170, endOffset: 210, elapsedTime: 80},
+			},
+		},
+		"wrong direction": {
+			points:   trackPoints(path([2]float64{0, 6}, [2]float64{0, 0}), 0),
+			expected: nil,
+		},
+		"detour from the segment": {
+			points: trackPoints(path(
+				[2]float64{0, 0}, [2]float64{0, 3},
+				[2]float64{1, 3}, [2]float64{0, 3},
+				[2]float64{0, 6},
+			), 0),
+			expected: nil,
+		},
+		"stops before the end": {
+			points:   trackPoints(path([2]float64{0, 0}, [2]float64{0, 4}), 0),
+			expected: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			efforts := Match(segment, tc.points)
+			if len(efforts) != len(tc.expected) {
+				t.Fatalf("expected %d efforts, got %+v", len(tc.expected), efforts)
+			}
+			for i, e := range tc.expected {
+				got := efforts[i]
+				if got.StartOffset != e.startOffset || got.EndOffset != e.endOffset || got.ElapsedTime != e.elapsedTime {
+					t.Fatalf("effort %d: expected %+v, got %+v", i, e, got)
+				}
+				if math.Abs(got.Distance-segment.Length) > 1 {
+					t.Fatalf("effort %d: expected distance %g, got %g", i, segment.Length, got.Distance)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchAverages(t *testing.T) {
+	segment, ok := NewSegment(path([2]float64{0, 1}, [2]float64{0, 5}))
+	if !ok {
+		t.Fatal("expected a valid segment")
+	}
+
+	testCases := map[string]struct {
+		power    float64
+		expected *float64
+	}{
+		"with power": {
+			power:    220,
+			expected: func() *float64 { v := 220.0; return &v }(),
+		},
+		"without power": {
+			power:    0,
+			expected: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			efforts := Match(segment, trackPoints(path([2]float64{0, 0}, [2]float64{0, 6}), tc.power))
+			if len(efforts) != 1 {
+				t.Fatalf("expected one effort, got %d", len(efforts))
+			}
+
+			got := efforts[0].AveragePower
+			if (got == nil) != (tc.expected == nil) || (got != nil && math.Abs(*got-*tc.expected) > 1e-9) {
+				t.Fatalf("expected average power %v, got %v", tc.expected, got)
+			}
+			if efforts[0].AverageHeartRate != nil {
+				t.Fatalf("expected no average heart rate, got %v", *efforts[0].AverageHeartRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package strava
+
+import (
+	"time"
+
+	strava "github.com/strava/go.strava"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// AllStreamTypes lists the stream types needed to build a track
+var AllStreamTypes = []strava.StreamType{
+	strava.StreamTypes.Time,
+	strava.StreamTypes.Location,
+	strava.StreamTypes.Distance,
+	strava.StreamTypes.Elevation,
+	strava.StreamTypes.Speed,
+	strava.StreamTypes.HeartRate,
+	strava.StreamTypes.Cadence,
+	strava.StreamTypes.Power,
+	strava.StreamTypes.Temperature,
+}
+
+// StreamsToTrack converts a set of activity streams into a Track. Stream
+// times are offsets from the start of the activity so the start time is
+// required.
+func StreamsToTrack(startTime time.Time, streams *strava.StreamSet) *track.Track {
+	t := &track.Track{}
+	if streams == nil || streams.Time == nil {
+		return t
+	}
+
+	for i, offset := range streams.Time.Data {
+		p := track.Point{
+			Time: startTime.Add(time.Duration(offset) * time.Second),
+		}
+
+		// [0, 0] is used by Strava when there is no location
+		if streams.Location != nil && i < len(streams.Location.Data) {
+			l := streams.Location.Data[i]
+			if l[0] != 0 || l[1] != 0 {
+				p.Position = &geo.Point{Lat: l[0], Lon: l[1]}
+			}
+		}
+
+		p.Distance = decimalAt(streams.Distance, i)
+		p.Elevation = decimalAt(streams.Elevation, i)
+		p.Speed = decimalAt(streams.Speed, i)
+		p.HeartRate = integerAt(streams.HeartRate, i)
+		p.Cadence = integerAt(streams.Cadence, i)
+		p.Power = integerAt(streams.Power, i)
+		p.Temperature = integerAt(streams.Temperature, i)
+
+		t.Points = append(t.Points, p)
+	}
+
+	return t
+}
+
+func decimalAt(s *strava.DecimalStream, i int) *float64 {
+	if s == nil || i >= len(s.RawData) || s.RawData[i] == nil {
+		return nil
+	}
+	v := *s.RawData[i]
+	return &v
+}
+
+func integerAt(s *strava.IntegerStream, i int) *float64 {
+	if s == nil || i >= len(s.RawData) || s.RawData[i] == nil {
+		return nil
+	}
+	v := float64(*s.RawData[i])
+	return &v
+}
@@ -0,0 +1,62 @@
+package strava
+
+import "strings"
+
+// sportNames maps Strava activity types to FIT sport names
+var sportNames = map[string]string{
+	"Ride":             "cycling",
+	"VirtualRide":      "cycling",
+	"EBikeRide":        "e_biking",
+	"MountainBikeRide": "cycling",
+	"GravelRide":       "cycling",
+	"Handcycle":        "cycling",
+	"Velomobile":       "cycling",
+	"Run":              "running",
+	"TrailRun":         "running",
+	"VirtualRun":       "running",
+	"Walk":             "walking",
+	"Hike":             "hiking",
+	"Swim":             "swimming",
+	"Rowing":           "rowing",
+	"AlpineSki":        "alpine_skiing",
+	"BackcountrySki":   "alpine_skiing",
+	"NordicSki":        "cross_country_skiing",
+	"Snowboard":        "snowboarding",
+	"Snowshoe":         "snowshoeing",
+	"IceSkate":         "ice_skating",
+	"InlineSkate":      "inline_skating",
+	"Kayaking":         "kayaking",
+	"Canoeing":         "paddling",
+	"StandUpPaddling":  "stand_up_paddleboarding",
+	"Surfing":          "surfing",
+	"Windsurf":         "windsurfing",
+	"Kitesurf":         "kitesurfing",
+	"RockClimbing":     "rock_climbing",
+	"Sail":             "sailing",
+	"Golf":             "golf",
+	"Workout":          "training",
+	"WeightTraining":   "training",
+	"Crossfit":         "training",
+	"Elliptical":       "fitness_equipment",
+	"StairStepper":     "fitness_equipment",
+}
+
+// SportName returns the FIT sport name for a Strava activity type
+func SportName(activityType string) string {
+	if name, ok := sportNames[activityType]; ok {
+		return name
+	}
+	return "generic"
+}
+
+// TimeZoneName returns the IANA name from a Strava time zone such as
+// "(GMT+00:00) Europe/London", it is empty when there is none
+func TimeZoneName(stravaTimeZone string) string {
+	if i := strings.LastIndex(stravaTimeZone, ") "); i >= 0 {
+		return strings.TrimSpace(stravaTimeZone[i+2:])
+	}
+	if strings.HasPrefix(stravaTimeZone, "(") {
+		return ""
+	}
+	return strings.TrimSpace(stravaTimeZone)
+}
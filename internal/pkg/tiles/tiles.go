@@ -0,0 +1,142 @@
+// Package tiles implements the XYZ web mercator tile scheme used by slippy
+// maps such as OpenStreetMap and Leaflet
+package tiles
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// Size is the width and height of a tile in pixels
+const Size = 256
+
+// maxLat is the latitude limit of the web mercator projection
+const maxLat = 85.0511287798
+
+// Tile is a map tile at a zoom level
+type Tile struct {
+	Z, X, Y int
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Valid returns true if the tile exists at its zoom level
+func (t Tile) Valid() bool {
+	// the zoom is checked first as shifting by a negative amount panics
+	if t.Z < 0 || t.Z > 30 {
+		return false
+	}
+	n := 1 << t.Z
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// Pixel returns the position of the point in pixels from the top left of
+// the world at the zoom level
+func Pixel(p geo.Point, z int) (float64, float64) {
+	lat := math.Max(-maxLat, math.Min(maxLat, p.Lat)) * math.Pi / 180
+	scale := float64(Size) * float64(int(1)<<z)
+
+	x := (p.Lon + 180) / 360 * scale
+	y := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * scale
+
+	return x, y
+}
+
+// FromPoint returns the tile containing the point at the zoom level
+func FromPoint(p geo.Point, z int) Tile {
+	x, y := Pixel(p, z)
+	n := 1 << z
+
+	return Tile{
+		Z: z,
+		X: clamp(int(math.Floor(x/Size)), 0, n-1),
+		Y: clamp(int(math.Floor(y/Size)), 0, n-1),
+	}
+}
+
+// Parent returns the tile at the lower zoom level which contains this tile
+func (t Tile) Parent(z int) Tile {
+	if z >= t.Z {
+		return t
+	}
+	shift := uint(t.Z - z)
+	return Tile{Z: z, X: t.X >> shift, Y: t.Y >> shift}
+}
+
+// Children returns the range of tiles at the higher zoom level which are
+// inside this tile, as the min and max tiles
+func (t Tile) Children(z int) (Tile, Tile) {
+	if z <= t.Z {
+		return t, t
+	}
+	shift := uint(z - t.Z)
+	return Tile{Z: z, X: t.X << shift, Y: t.Y << shift},
+		Tile{Z: z, X: (t.X+1)<<shift - 1, Y: (t.Y+1)<<shift - 1}
+}
+
+// Bounds returns the bounding box of the tile
+func (t Tile) Bounds() geo.BBox {
+	n := float64(int(1) << t.Z)
+
+	lon := func(x int) float64 {
+		return float64(x)/n*360 - 180
+	}
+	lat := func(y int) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+	}
+
+	return geo.BBox{
+		MinLat: lat(t.Y + 1),
+		MinLon: lon(t.X),
+		MaxLat: lat(t.Y),
+		MaxLon: lon(t.X + 1),
+	}
+}
+
+// Cover returns the tiles at the zoom level which the line passes through
+func Cover(line []geo.Point, z int) []Tile {
+	seen := make(map[Tile]bool)
+	var covered []Tile
+
+	add := func(p geo.Point) {
+		t := FromPoint(p, z)
+		if !seen[t] {
+			seen[t] = true
+			covered = append(covered, t)
+		}
+	}
+
+	for i, p := range line {
+		add(p)
+		if i == 0 {
+			continue
+		}
+
+		// step at a quarter of the tile width so no tile between the points
+		// is skipped
+		bounds := FromPoint(p, z).Bounds()
+		step := geo.Distance(
+			geo.Point{Lat: p.Lat, Lon: bounds.MinLon},
+			geo.Point{Lat: p.Lat, Lon: bounds.MaxLon},
+		) / 4
+		for _, q := range geo.Interpolate(line[i-1], p, step) {
+			add(q)
+		}
+	}
+
+	return covered
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,520 @@
+package track
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/fit"
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+)
+
+// ContentTypes maps the formats a track can be encoded to to their MIME
+// types
+var ContentTypes = map[string]string{
+	format.FIT:     "application/vnd.ant.fit",
+	format.GPX:     "application/gpx+xml",
+	format.TCX:     "application/vnd.garmin.tcx+xml",
+	format.GeoJSON: "application/geo+json",
+}
+
+// Encode writes the track in the given format
+func Encode(t *Track, targetFormat string) ([]byte, error) {
+	switch targetFormat {
+	case format.FIT:
+		return fit.Encode(ToFIT(t)), nil
+	case format.GPX:
+		return encodeGPX(t)
+	case format.TCX:
+		return encodeTCX(t)
+	case format.GeoJSON:
+		return encodeGeoJSON(t)
+	}
+
+	return nil, fmt.Errorf("unsupported format: %s", targetFormat)
+}
+
+// Summary holds totals for a track computed from its points
+type Summary struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	ElapsedTime float64
+	Distance    float64
+	Ascent      float64
+	Descent     float64
+
+	AvgHeartRate float64
+	MaxHeartRate float64
+	AvgCadence   float64
+	AvgPower     float64
+	MaxPower     float64
+	MaxSpeed     float64
+}
+
+// Summarise computes totals for the track from its points
+func (t *Track) Summarise() Summary {
+	s := Summary{}
+	if len(t.Points) == 0 {
+		return s
+	}
+
+	s.StartTime = t.Points[0].Time
+	s.EndTime = t.Points[len(t.Points)-1].Time
+	s.ElapsedTime = s.EndTime.Sub(s.StartTime).Seconds()
+
+	var hrTotal, cadenceTotal, powerTotal float64
+	var hrCount, cadenceCount, powerCount int
+	var lastElevation *float64
+
+	for _, p := range t.Points {
+		if p.Distance != nil && *p.Distance > s.Distance {
+			s.Distance = *p.Distance
+		}
+		if p.Elevation != nil {
+			if lastElevation != nil {
+				if diff := *p.Elevation - *lastElevation; diff > 0 {
+					s.Ascent += diff
+				} else {
+					s.Descent -= diff
+				}
+			}
+			lastElevation = p.Elevation
+		}
+		if p.HeartRate != nil {
+			hrTotal += *p.HeartRate
+			hrCount++
+			if *p.HeartRate > s.MaxHeartRate {
+				s.MaxHeartRate = *p.HeartRate
+			}
+		}
+		if p.Cadence != nil {
+			cadenceTotal += *p.Cadence
+			cadenceCount++
+		}
+		if p.Power != nil {
+			powerTotal += *p.Power
+			powerCount++
+			if *p.Power > s.MaxPower {
+				s.MaxPower = *p.Power
+			}
+		}
+		if p.Speed != nil && *p.Speed > s.MaxSpeed {
+			s.MaxSpeed = *p.Speed
+		}
+	}
+
+	if hrCount > 0 {
+		s.AvgHeartRate = hrTotal / float64(hrCount)
+	}
+	if cadenceCount > 0 {
+		s.AvgCadence = cadenceTotal / float64(cadenceCount)
+	}
+	if powerCount > 0 {
+		s.AvgPower = powerTotal / float64(powerCount)
+	}
+
+	return s
+}
+
+// ToFIT converts the track into FIT messages. A single session is written
+// and, when the track has no laps, a single lap covering the whole track.
+func ToFIT(t *Track) *fit.File {
+	s := t.Summarise()
+	sport := fit.SportFromName(t.Sport)
+
+	f := &fit.File{
+		FileID: fit.FileID{
+			// 4 is the activity file type
+			Type:         4,
+			Manufacturer: 255,
+			TimeCreated:  s.StartTime,
+		},
+	}
+
+	for _, p := range t.Points {
+		r := fit.Record{
+			Timestamp: p.Time,
+			Altitude:  p.Elevation,
+			Distance:  p.Distance,
+			Speed:     p.Speed,
+		}
+		if p.Position != nil {
+			r.Position = &fit.Position{Lat: p.Position.Lat, Lon: p.Position.Lon}
+		}
+		if p.HeartRate != nil {
+			hr := uint8(*p.HeartRate)
+			r.HeartRate = &hr
+		}
+		if p.Cadence != nil {
+			cadence := uint8(*p.Cadence)
+			r.Cadence = &cadence
+		}
+		if p.Power != nil {
+			power := uint16(*p.Power)
+			r.Power = &power
+		}
+		if p.Temperature != nil {
+			temperature := int8(*p.Temperature)
+			r.Temperature = &temperature
+		}
+		f.Records = append(f.Records, r)
+	}
+
+	laps := t.Laps
+	if len(laps) == 0 {
+		laps = []Lap{{
+			StartTime:    s.StartTime,
+			TotalTime:    s.ElapsedTime,
+			Distance:     s.Distance,
+			AvgHeartRate: s.AvgHeartRate,
+			MaxHeartRate: s.MaxHeartRate,
+			AvgCadence:   s.AvgCadence,
+			AvgPower:     s.AvgPower,
+			MaxPower:     s.MaxPower,
+			MaxSpeed:     s.MaxSpeed,
+		}}
+	}
+
+	var calories float64
+	for i, l := range laps {
+		end := s.EndTime
+		if i+1 < len(laps) {
+			end = laps[i+1].StartTime
+		}
+		calories += l.Calories
+
+		f.Laps = append(f.Laps, fit.Lap{
+			Timestamp:        end,
+			StartTime:        l.StartTime,
+			Sport:            sport,
+			TotalElapsedTime: end.Sub(l.StartTime).Seconds(),
+			TotalTimerTime:   l.TotalTime,
+			TotalDistance:    l.Distance,
+			TotalCalories:    uint16(l.Calories),
+			AvgSpeed:         l.AvgSpeed,
+			MaxSpeed:         l.MaxSpeed,
+			AvgHeartRate:     uint8(l.AvgHeartRate),
+			MaxHeartRate:     uint8(l.MaxHeartRate),
+			AvgCadence:       uint8(l.AvgCadence),
+			AvgPower:         uint16(l.AvgPower),
+			MaxPower:         uint16(l.MaxPower),
+		})
+	}
+
+	var timerTime float64
+	for _, l := range laps {
+		timerTime += l.TotalTime
+	}
+
+	f.Sessions = []fit.Session{{
+		Timestamp:        s.EndTime,
+		StartTime:        s.StartTime,
+		Sport:            sport,
+		TotalElapsedTime: s.ElapsedTime,
+		TotalTimerTime:   timerTime,
+		TotalDistance:    s.Distance,
+		TotalAscent:      uint16(s.Ascent),
+		TotalDescent:     uint16(s.Descent),
+		TotalCalories:    uint16(calories),
+		MaxSpeed:         s.MaxSpeed,
+		AvgHeartRate:     uint8(s.AvgHeartRate),
+		MaxHeartRate:     uint8(s.MaxHeartRate),
+		AvgCadence:       uint8(s.AvgCadence),
+		AvgPower:         uint16(s.AvgPower),
+		MaxPower:         uint16(s.MaxPower),
+		NumLaps:          uint16(len(laps)),
+	}}
+
+	f.Activity = &fit.Activity{
+		Timestamp:      s.EndTime,
+		TotalTimerTime: timerTime,
+		NumSessions:    1,
+	}
+
+	return f
+}
+
+type gpxOutput struct {
+	XMLName        xml.Name `xml:"gpx"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	XmlnsGpxtpx    string   `xml:"xmlns:gpxtpx,attr"`
+	XmlnsXsi       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Version        string   `xml:"version,attr"`
+	Creator        string   `xml:"creator,attr"`
+
+	Time  string `xml:"metadata>time,omitempty"`
+	Track struct {
+		Type   string           `xml:"type,omitempty"`
+		Points []gpxOutputPoint `xml:"trkseg>trkpt"`
+	} `xml:"trk"`
+}
+
+type gpxOutputPoint struct {
+	Lat        float64              `xml:"lat,attr"`
+	Lon        float64              `xml:"lon,attr"`
+	Elevation  *float64             `xml:"ele,omitempty"`
+	Time       string               `xml:"time,omitempty"`
+	Extensions *gpxOutputExtensions `xml:"extensions,omitempty"`
+}
+
+type gpxOutputExtensions struct {
+	// power has no place in the Garmin extension and is written alongside it
+	// as Strava does
+	Power *float64 `xml:"power,omitempty"`
+
+	TrackPointExtension *gpxOutputTrackPointExtension `xml:"gpxtpx:TrackPointExtension,omitempty"`
+}
+
+type gpxOutputTrackPointExtension struct {
+	Temperature *float64 `xml:"gpxtpx:atemp,omitempty"`
+	HeartRate   *float64 `xml:"gpxtpx:hr,omitempty"`
+	Cadence     *float64 `xml:"gpxtpx:cad,omitempty"`
+}
+
+func encodeGPX(t *Track) ([]byte, error) {
+	g := gpxOutput{
+		Xmlns:          "http://www.topografix.com/GPX/1/1",
+		XmlnsGpxtpx:    "http://www.garmin.com/xmlschemas/TrackPointExtension/v1",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v1 http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd",
+		Version:        "1.1",
+		Creator:        "tool-activities",
+	}
+	g.Track.Type = t.Sport
+	if len(t.Points) > 0 {
+		g.Time = formatTime(t.Points[0].Time)
+	}
+
+	for _, p := range t.Points {
+		// GPX track points require a position
+		if p.Position == nil {
+			continue
+		}
+
+		pt := gpxOutputPoint{
+			Lat:       p.Position.Lat,
+			Lon:       p.Position.Lon,
+			Elevation: p.Elevation,
+			Time:      formatTime(p.Time),
+		}
+
+		if p.Power != nil || p.HeartRate != nil || p.Cadence != nil || p.Temperature != nil {
+			pt.Extensions = &gpxOutputExtensions{Power: p.Power}
+			if p.HeartRate != nil || p.Cadence != nil || p.Temperature != nil {
+				pt.Extensions.TrackPointExtension = &gpxOutputTrackPointExtension{
+					Temperature: p.Temperature,
+					HeartRate:   p.HeartRate,
+					Cadence:     p.Cadence,
+				}
+			}
+		}
+
+		g.Track.Points = append(g.Track.Points, pt)
+	}
+
+	return marshalXML(g)
+}
+
+type tcxOutput struct {
+	XMLName  xml.Name `xml:"TrainingCenterDatabase"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	XmlnsNs3 string   `xml:"xmlns:ns3,attr"`
+
+	Activity struct {
+		Sport string         `xml:"Sport,attr"`
+		ID    string         `xml:"Id"`
+		Laps  []tcxOutputLap `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+type tcxOutputLap struct {
+	StartTime        string           `xml:"StartTime,attr"`
+	TotalTimeSeconds float64          `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64          `xml:"DistanceMeters"`
+	MaximumSpeed     float64          `xml:"MaximumSpeed,omitempty"`
+	Calories         float64          `xml:"Calories"`
+	AverageHeartRate *float64         `xml:"AverageHeartRateBpm>Value,omitempty"`
+	MaximumHeartRate *float64         `xml:"MaximumHeartRateBpm>Value,omitempty"`
+	Intensity        string           `xml:"Intensity"`
+	TriggerMethod    string           `xml:"TriggerMethod"`
+	Trackpoints      []tcxOutputPoint `xml:"Track>Trackpoint"`
+}
+
+type tcxOutputPoint struct {
+	Time           string        `xml:"Time"`
+	Position       *tcxPosition  `xml:"Position,omitempty"`
+	AltitudeMeters *float64      `xml:"AltitudeMeters,omitempty"`
+	DistanceMeters *float64      `xml:"DistanceMeters,omitempty"`
+	HeartRate      *float64      `xml:"HeartRateBpm>Value,omitempty"`
+	Cadence        *float64      `xml:"Cadence,omitempty"`
+	Extensions     *tcxOutputTPX `xml:"Extensions>ns3:TPX,omitempty"`
+}
+
+type tcxOutputTPX struct {
+	Speed *float64 `xml:"ns3:Speed,omitempty"`
+	Watts *float64 `xml:"ns3:Watts,omitempty"`
+}
+
+func encodeTCX(t *Track) ([]byte, error) {
+	x := tcxOutput{
+		Xmlns:    "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+		XmlnsNs3: "http://www.garmin.com/xmlschemas/ActivityExtension/v2",
+	}
+
+	// TCX only has sport values for running and biking
+	switch t.Sport {
+	case "running":
+		x.Activity.Sport = "Running"
+	case "cycling":
+		x.Activity.Sport = "Biking"
+	default:
+		x.Activity.Sport = "Other"
+	}
+
+	s := t.Summarise()
+	x.Activity.ID = formatTime(s.StartTime)
+
+	laps := t.Laps
+	if len(laps) == 0 {
+		laps = []Lap{{
+			StartTime:    s.StartTime,
+			TotalTime:    s.ElapsedTime,
+			Distance:     s.Distance,
+			AvgHeartRate: s.AvgHeartRate,
+			MaxHeartRate: s.MaxHeartRate,
+			MaxSpeed:     s.MaxSpeed,
+		}}
+	}
+
+	pointIndex := 0
+	for i, l := range laps {
+		lap := tcxOutputLap{
+			StartTime:        formatTime(l.StartTime),
+			TotalTimeSeconds: l.TotalTime,
+			DistanceMeters:   l.Distance,
+			MaximumSpeed:     l.MaxSpeed,
+			Calories:         l.Calories,
+			Intensity:        "Active",
+			TriggerMethod:    "Manual",
+		}
+		if l.AvgHeartRate > 0 {
+			lap.AverageHeartRate = float(l.AvgHeartRate)
+		}
+		if l.MaxHeartRate > 0 {
+			lap.MaximumHeartRate = float(l.MaxHeartRate)
+		}
+
+		for ; pointIndex < len(t.Points); pointIndex++ {
+			p := t.Points[pointIndex]
+			if i+1 < len(laps) && !p.Time.Before(laps[i+1].StartTime) {
+				break
+			}
+
+			tp := tcxOutputPoint{
+				Time:           formatTime(p.Time),
+				AltitudeMeters: p.Elevation,
+				DistanceMeters: p.Distance,
+				HeartRate:      p.HeartRate,
+				Cadence:        p.Cadence,
+			}
+			if p.Position != nil {
+				tp.Position = &tcxPosition{Lat: p.Position.Lat, Lon: p.Position.Lon}
+			}
+			if p.Speed != nil || p.Power != nil {
+				tp.Extensions = &tcxOutputTPX{Speed: p.Speed, Watts: p.Power}
+			}
+
+			lap.Trackpoints = append(lap.Trackpoints, tp)
+		}
+
+		x.Activity.Laps = append(x.Activity.Laps, lap)
+	}
+
+	return marshalXML(x)
+}
+
+type geoJSONFeature struct {
+	Type       string         `json:"type"`
+	Geometry   geoJSONLine    `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geoJSONLine struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+// encodeGeoJSON writes the track as a LineString feature. Per point values
+// are written to coordinateProperties in the style used by togeojson.
+func encodeGeoJSON(t *Track) ([]byte, error) {
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONLine{
+			Type:        "LineString",
+			Coordinates: [][]float64{},
+		},
+	}
+
+	var times []string
+	var heartRates, cadences, powers []*float64
+	var hasHeartRate, hasCadence, hasPower bool
+
+	for _, p := range t.Points {
+		if p.Position == nil {
+			continue
+		}
+
+		coordinate := []float64{p.Position.Lon, p.Position.Lat}
+		if p.Elevation != nil {
+			coordinate = append(coordinate, *p.Elevation)
+		}
+		feature.Geometry.Coordinates = append(feature.Geometry.Coordinates, coordinate)
+
+		times = append(times, formatTime(p.Time))
+		heartRates = append(heartRates, p.HeartRate)
+		cadences = append(cadences, p.Cadence)
+		powers = append(powers, p.Power)
+		hasHeartRate = hasHeartRate || p.HeartRate != nil
+		hasCadence = hasCadence || p.Cadence != nil
+		hasPower = hasPower || p.Power != nil
+	}
+
+	coordinateProperties := map[string]any{"times": times}
+	if hasHeartRate {
+		coordinateProperties["heart"] = heartRates
+	}
+	if hasCadence {
+		coordinateProperties["cadence"] = cadences
+	}
+	if hasPower {
+		coordinateProperties["power"] = powers
+	}
+
+	feature.Properties = map[string]any{
+		"sport":                t.Sport,
+		"coordinateProperties": coordinateProperties,
+	}
+	if len(times) > 0 {
+		feature.Properties["time"] = times[0]
+	}
+
+	return json.Marshal(feature)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
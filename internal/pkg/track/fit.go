@@ -0,0 +1,72 @@
+package track
+
+import (
+	"github.com/charlieegan3/tool-activities/internal/pkg/fit"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+func decodeFIT(data []byte) (*Track, error) {
+	f, err := fit.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromFIT(f), nil
+}
+
+// FromFIT converts a decoded FIT file into a Track
+func FromFIT(f *fit.File) *Track {
+	t := &Track{}
+
+	if len(f.Sessions) > 0 {
+		t.Sport = fit.SportName(f.Sessions[0].Sport)
+	}
+
+	for _, r := range f.Records {
+		if r.Timestamp.IsZero() {
+			continue
+		}
+
+		p := Point{
+			Time:      r.Timestamp,
+			Elevation: r.Altitude,
+			Speed:     r.Speed,
+			Distance:  r.Distance,
+		}
+		if r.Position != nil {
+			p.Position = &geo.Point{Lat: r.Position.Lat, Lon: r.Position.Lon}
+		}
+		if r.HeartRate != nil {
+			p.HeartRate = float(float64(*r.HeartRate))
+		}
+		if r.Cadence != nil {
+			p.Cadence = float(float64(*r.Cadence))
+		}
+		if r.Power != nil {
+			p.Power = float(float64(*r.Power))
+		}
+		if r.Temperature != nil {
+			p.Temperature = float(float64(*r.Temperature))
+		}
+
+		t.Points = append(t.Points, p)
+	}
+
+	for _, l := range f.Laps {
+		t.Laps = append(t.Laps, Lap{
+			StartTime:    l.StartTime,
+			TotalTime:    l.TotalTimerTime,
+			Distance:     l.TotalDistance,
+			Calories:     float64(l.TotalCalories),
+			AvgHeartRate: float64(l.AvgHeartRate),
+			MaxHeartRate: float64(l.MaxHeartRate),
+			AvgCadence:   float64(l.AvgCadence),
+			AvgPower:     float64(l.AvgPower),
+			MaxPower:     float64(l.MaxPower),
+			AvgSpeed:     l.AvgSpeed,
+			MaxSpeed:     l.MaxSpeed,
+		})
+	}
+
+	return t
+}
@@ -0,0 +1,175 @@
+package track
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// gpxFile covers GPX 1.0 and 1.1, element names are matched without their
+// namespace so both versions decode into the same structure
+type gpxFile struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Tracks  []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Type     string       `xml:"type"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+	// Speed is only part of GPX 1.0, it's in extensions for 1.1
+	Speed      *float64     `xml:"speed"`
+	Extensions xmlExtension `xml:"extensions"`
+}
+
+// xmlExtension is a generic element used to read extension values without
+// knowing the schema of each vendor's extensions
+type xmlExtension struct {
+	XMLName  xml.Name
+	Value    string         `xml:",chardata"`
+	Children []xmlExtension `xml:",any"`
+}
+
+// values returns the text values of the leaf elements in the extension by
+// their local name, e.g. hr from gpxtpx:TrackPointExtension/gpxtpx:hr
+func (e *xmlExtension) values() map[string]float64 {
+	values := make(map[string]float64)
+	e.collect(values)
+	return values
+}
+
+func (e *xmlExtension) collect(values map[string]float64) {
+	for i := range e.Children {
+		child := &e.Children[i]
+		if len(child.Children) > 0 {
+			child.collect(values)
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(child.Value), 64)
+		if err == nil {
+			values[strings.ToLower(child.XMLName.Local)] = v
+		}
+	}
+}
+
+func newXMLDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// files declaring other encodings are almost always ASCII in practice
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+func decodeGPX(data []byte) (*Track, error) {
+	var g gpxFile
+	err := newXMLDecoder(data).Decode(&g)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Track{}
+	for _, trk := range g.Tracks {
+		if t.Sport == "" && trk.Type != "" {
+			t.Sport = normaliseSport(trk.Type)
+		}
+
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				timestamp, err := parseTime(pt.Time)
+				if err != nil {
+					return nil, err
+				}
+
+				p := Point{
+					Time:      timestamp,
+					Position:  &geo.Point{Lat: pt.Lat, Lon: pt.Lon},
+					Elevation: pt.Elevation,
+					Speed:     pt.Speed,
+				}
+
+				ext := pt.Extensions.values()
+				if v, ok := ext["hr"]; ok {
+					p.HeartRate = float(v)
+				}
+				if v, ok := ext["cad"]; ok {
+					p.Cadence = float(v)
+				}
+				if v, ok := ext["atemp"]; ok {
+					p.Temperature = float(v)
+				} else if v, ok := ext["wtemp"]; ok {
+					p.Temperature = float(v)
+				}
+				if v, ok := ext["power"]; ok {
+					p.Power = float(v)
+				}
+				if v, ok := ext["speed"]; ok && p.Speed == nil {
+					p.Speed = float(v)
+				}
+
+				t.Points = append(t.Points, p)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// parseTime parses the xsd:dateTime values used in GPX and TCX, some
+// devices omit the timezone and these are assumed to be UTC
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.ParseInLocation("2006-01-02T15:04:05", value, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q: %w", value, err)
+	}
+
+	return t, nil
+}
+
+// normaliseSport maps the sport names used by GPX track types and TCX
+// activities onto the FIT sport names
+func normaliseSport(sport string) string {
+	switch strings.ToLower(strings.TrimSpace(sport)) {
+	case "biking", "cycling", "ride", "1":
+		return "cycling"
+	case "running", "run", "9":
+		return "running"
+	case "walking", "walk":
+		return "walking"
+	case "hiking", "hike":
+		return "hiking"
+	case "swimming", "swim":
+		return "swimming"
+	case "other", "":
+		return "generic"
+	}
+
+	return strings.ToLower(strings.TrimSpace(sport))
+}
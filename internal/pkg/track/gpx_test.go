@@ -0,0 +1,148 @@
+package track
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+var start = time.Date(2023, time.June, 1, 8, 0, 0, 0, time.UTC)
+
+func TestDecodeGPX(t *testing.T) {
+	first, second := geo.Point{Lat: 51.5, Lon: -0.1}, geo.Point{Lat: 51.501, Lon: -0.1}
+	distance := geo.Distance(first, second)
+
+	testCases := map[string]struct {
+		data            string
+		expectedSport   string
+		expectedPoints  []Point
+		expectedSummary Summary
+	}{
+		"track point extensions": {
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <trk>
+    <type>cycling</type>
+    <trkseg>
+      <trkpt lat="51.5" lon="-0.1">
+        <ele>10</ele>
+        <time>2023-06-01T08:00:00Z</time>
+        <extensions>
+          <power>200</power>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:hr>120</gpxtpx:hr>
+            <gpxtpx:cad>80</gpxtpx:cad>
+          </gpxtpx:TrackPointExtension>
+        </extensions>
+      </trkpt>
+      <trkpt lat="51.501" lon="-0.1">
+        <ele>15</ele>
+        <time>2023-06-01T08:00:10Z</time>
+        <extensions>
+          <power>300</power>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:hr>130</gpxtpx:hr>
+            <gpxtpx:cad>90</gpxtpx:cad>
+          </gpxtpx:TrackPointExtension>
+        </extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`,
+			expectedSport: "cycling",
+			expectedPoints: []Point{
+				{
+					Time:      start,
+					Position:  &first,
+					Elevation: float(10),
+					HeartRate: float(120),
+					Cadence:   float(80),
+					Power:     float(200),
+					Distance:  float(0),
+				},
+				{
+					Time:      start.Add(10 * time.Second),
+					Position:  &second,
+					Elevation: float(15),
+					HeartRate: float(130),
+					Cadence:   float(90),
+					Power:     float(300),
+					Distance:  float(distance),
+				},
+			},
+			expectedSummary: Summary{
+				StartTime:    start,
+				EndTime:      start.Add(10 * time.Second),
+				ElapsedTime:  10,
+				Distance:     distance,
+				Ascent:       5,
+				AvgHeartRate: 125,
+				MaxHeartRate: 130,
+				AvgCadence:   85,
+				AvgPower:     250,
+				MaxPower:     300,
+			},
+		},
+		"gpx 1.0 speed without a timezone": {
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.0" creator="test" xmlns="http://www.topografix.com/GPX/1/0">
+  <trk>
+    <type>run</type>
+    <trkseg>
+      <trkpt lat="51.5" lon="-0.1">
+        <time>2023-06-01T08:00:00</time>
+        <speed>3</speed>
+      </trkpt>
+      <trkpt lat="51.501" lon="-0.1">
+        <time>2023-06-01T08:00:30</time>
+        <speed>4</speed>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`,
+			expectedSport: "running",
+			expectedPoints: []Point{
+				{
+					Time:     start,
+					Position: &first,
+					Speed:    float(3),
+					Distance: float(0),
+				},
+				{
+					Time:     start.Add(30 * time.Second),
+					Position: &second,
+					Speed:    float(4),
+					Distance: float(distance),
+				},
+			},
+			expectedSummary: Summary{
+				StartTime:   start,
+				EndTime:     start.Add(30 * time.Second),
+				ElapsedTime: 30,
+				Distance:    distance,
+				MaxSpeed:    4,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			trk, err := Decode([]byte(tc.data), format.GPX)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if trk.Sport != tc.expectedSport {
+				t.Fatalf("expected sport %q, got %q", tc.expectedSport, trk.Sport)
+			}
+			if !reflect.DeepEqual(trk.Points, tc.expectedPoints) {
+				t.Fatalf("expected points %+v, got %+v", tc.expectedPoints, trk.Points)
+			}
+			if summary := trk.Summarise(); !reflect.DeepEqual(summary, tc.expectedSummary) {
+				t.Fatalf("expected summary %+v, got %+v", tc.expectedSummary, summary)
+			}
+		})
+	}
+}
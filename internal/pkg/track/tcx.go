@@ -0,0 +1,118 @@
+package track
+
+import (
+	"encoding/xml"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+type tcxFile struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	Activities []tcxActivity `xml:"Activities>Activity"`
+}
+
+type tcxActivity struct {
+	Sport string   `xml:"Sport,attr"`
+	Laps  []tcxLap `xml:"Lap"`
+}
+
+type tcxLap struct {
+	StartTime        string       `xml:"StartTime,attr"`
+	TotalTimeSeconds float64      `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64      `xml:"DistanceMeters"`
+	MaximumSpeed     float64      `xml:"MaximumSpeed"`
+	Calories         float64      `xml:"Calories"`
+	AverageHeartRate float64      `xml:"AverageHeartRateBpm>Value"`
+	MaximumHeartRate float64      `xml:"MaximumHeartRateBpm>Value"`
+	Cadence          float64      `xml:"Cadence"`
+	Trackpoints      []tcxPoint   `xml:"Track>Trackpoint"`
+	Extensions       xmlExtension `xml:"Extensions"`
+}
+
+type tcxPoint struct {
+	Time           string       `xml:"Time"`
+	Position       *tcxPosition `xml:"Position"`
+	AltitudeMeters *float64     `xml:"AltitudeMeters"`
+	DistanceMeters *float64     `xml:"DistanceMeters"`
+	HeartRate      *float64     `xml:"HeartRateBpm>Value"`
+	Cadence        *float64     `xml:"Cadence"`
+	Extensions     xmlExtension `xml:"Extensions"`
+}
+
+type tcxPosition struct {
+	Lat float64 `xml:"LatitudeDegrees"`
+	Lon float64 `xml:"LongitudeDegrees"`
+}
+
+func decodeTCX(data []byte) (*Track, error) {
+	var x tcxFile
+	err := newXMLDecoder(data).Decode(&x)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Track{}
+	for _, activity := range x.Activities {
+		if t.Sport == "" {
+			t.Sport = normaliseSport(activity.Sport)
+		}
+
+		for _, lap := range activity.Laps {
+			startTime, err := parseTime(lap.StartTime)
+			if err != nil {
+				return nil, err
+			}
+
+			// the ActivityExtension v2 LX element holds the lap averages
+			lapExt := lap.Extensions.values()
+			t.Laps = append(t.Laps, Lap{
+				StartTime:    startTime,
+				TotalTime:    lap.TotalTimeSeconds,
+				Distance:     lap.DistanceMeters,
+				Calories:     lap.Calories,
+				AvgHeartRate: lap.AverageHeartRate,
+				MaxHeartRate: lap.MaximumHeartRate,
+				AvgCadence:   lap.Cadence,
+				AvgPower:     lapExt["avgwatts"],
+				MaxPower:     lapExt["maxwatts"],
+				AvgSpeed:     lapExt["avgspeed"],
+				MaxSpeed:     lap.MaximumSpeed,
+			})
+
+			for _, tp := range lap.Trackpoints {
+				timestamp, err := parseTime(tp.Time)
+				if err != nil {
+					return nil, err
+				}
+
+				p := Point{
+					Time:      timestamp,
+					Elevation: tp.AltitudeMeters,
+					Distance:  tp.DistanceMeters,
+					HeartRate: tp.HeartRate,
+					Cadence:   tp.Cadence,
+				}
+				if tp.Position != nil {
+					p.Position = &geo.Point{Lat: tp.Position.Lat, Lon: tp.Position.Lon}
+				}
+
+				// the ActivityExtension v2 TPX element holds speed, power and
+				// the cadence for running activities
+				ext := tp.Extensions.values()
+				if v, ok := ext["speed"]; ok {
+					p.Speed = float(v)
+				}
+				if v, ok := ext["watts"]; ok {
+					p.Power = float(v)
+				}
+				if v, ok := ext["runcadence"]; ok && p.Cadence == nil {
+					p.Cadence = float(v)
+				}
+
+				t.Points = append(t.Points, p)
+			}
+		}
+	}
+
+	return t, nil
+}
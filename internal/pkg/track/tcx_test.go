@@ -0,0 +1,196 @@
+package track
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+func TestDecodeTCX(t *testing.T) {
+	first, second := geo.Point{Lat: 51.5, Lon: -0.1}, geo.Point{Lat: 51.501, Lon: -0.1}
+
+	testCases := map[string]struct {
+		data            string
+		expectedSport   string
+		expectedPoints  []Point
+		expectedLaps    []Lap
+		expectedSummary Summary
+	}{
+		"activity extensions": {
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2" xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Lap StartTime="2023-06-01T08:00:00Z">
+        <TotalTimeSeconds>10</TotalTimeSeconds>
+        <DistanceMeters>110</DistanceMeters>
+        <Track>
+          <Trackpoint>
+            <Time>2023-06-01T08:00:00Z</Time>
+            <Position>
+              <LatitudeDegrees>51.5</LatitudeDegrees>
+              <LongitudeDegrees>-0.1</LongitudeDegrees>
+            </Position>
+            <AltitudeMeters>10</AltitudeMeters>
+            <DistanceMeters>0</DistanceMeters>
+            <HeartRateBpm><Value>120</Value></HeartRateBpm>
+            <Cadence>80</Cadence>
+            <Extensions>
+              <ns3:TPX>
+                <ns3:Speed>10</ns3:Speed>
+                <ns3:Watts>200</ns3:Watts>
+              </ns3:TPX>
+            </Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2023-06-01T08:00:10Z</Time>
+            <Position>
+              <LatitudeDegrees>51.501</LatitudeDegrees>
+              <LongitudeDegrees>-0.1</LongitudeDegrees>
+            </Position>
+            <AltitudeMeters>8</AltitudeMeters>
+            <DistanceMeters>110</DistanceMeters>
+            <HeartRateBpm><Value>130</Value></HeartRateBpm>
+            <Cadence>90</Cadence>
+            <Extensions>
+              <ns3:TPX>
+                <ns3:Speed>12</ns3:Speed>
+                <ns3:Watts>300</ns3:Watts>
+              </ns3:TPX>
+            </Extensions>
+          </Trackpoint>
+        </Track>
+        <Extensions>
+          <ns3:LX>
+            <ns3:AvgWatts>250</ns3:AvgWatts>
+            <ns3:MaxWatts>300</ns3:MaxWatts>
+          </ns3:LX>
+        </Extensions>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`,
+			expectedSport: "cycling",
+			expectedPoints: []Point{
+				{
+					Time:      start,
+					Position:  &first,
+					Elevation: float(10),
+					HeartRate: float(120),
+					Cadence:   float(80),
+					Power:     float(200),
+					Speed:     float(10),
+					Distance:  float(0),
+				},
+				{
+					Time:      start.Add(10 * time.Second),
+					Position:  &second,
+					Elevation: float(8),
+					HeartRate: float(130),
+					Cadence:   float(90),
+					Power:     float(300),
+					Speed:     float(12),
+					Distance:  float(110),
+				},
+			},
+			expectedLaps: []Lap{
+				{StartTime: start, TotalTime: 10, Distance: 110, AvgPower: 250, MaxPower: 300},
+			},
+			expectedSummary: Summary{
+				StartTime:    start,
+				EndTime:      start.Add(10 * time.Second),
+				ElapsedTime:  10,
+				Distance:     110,
+				Descent:      2,
+				AvgHeartRate: 125,
+				MaxHeartRate: 130,
+				AvgCadence:   85,
+				AvgPower:     250,
+				MaxPower:     300,
+				MaxSpeed:     12,
+			},
+		},
+		"run cadence without positions": {
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2" xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+  <Activities>
+    <Activity Sport="Running">
+      <Lap StartTime="2023-06-01T08:00:00Z">
+        <TotalTimeSeconds>60</TotalTimeSeconds>
+        <DistanceMeters>200</DistanceMeters>
+        <Track>
+          <Trackpoint>
+            <Time>2023-06-01T08:00:00Z</Time>
+            <DistanceMeters>0</DistanceMeters>
+            <HeartRateBpm><Value>140</Value></HeartRateBpm>
+            <Extensions>
+              <ns3:TPX><ns3:RunCadence>85</ns3:RunCadence></ns3:TPX>
+            </Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2023-06-01T08:01:00Z</Time>
+            <DistanceMeters>200</DistanceMeters>
+            <HeartRateBpm><Value>150</Value></HeartRateBpm>
+            <Extensions>
+              <ns3:TPX><ns3:RunCadence>87</ns3:RunCadence></ns3:TPX>
+            </Extensions>
+          </Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`,
+			expectedSport: "running",
+			expectedPoints: []Point{
+				{
+					Time:      start,
+					HeartRate: float(140),
+					Cadence:   float(85),
+					Distance:  float(0),
+				},
+				{
+					Time:      start.Add(time.Minute),
+					HeartRate: float(150),
+					Cadence:   float(87),
+					Distance:  float(200),
+				},
+			},
+			expectedLaps: []Lap{
+				{StartTime: start, TotalTime: 60, Distance: 200},
+			},
+			expectedSummary: Summary{
+				StartTime:    start,
+				EndTime:      start.Add(time.Minute),
+				ElapsedTime:  60,
+				Distance:     200,
+				AvgHeartRate: 145,
+				MaxHeartRate: 150,
+				AvgCadence:   86,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			trk, err := Decode([]byte(tc.data), format.TCX)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if trk.Sport != tc.expectedSport {
+				t.Fatalf("expected sport %q, got %q", tc.expectedSport, trk.Sport)
+			}
+			if !reflect.DeepEqual(trk.Points, tc.expectedPoints) {
+				t.Fatalf("expected points %+v, got %+v", tc.expectedPoints, trk.Points)
+			}
+			if !reflect.DeepEqual(trk.Laps, tc.expectedLaps) {
+				t.Fatalf("expected laps %+v, got %+v", tc.expectedLaps, trk.Laps)
+			}
+			if summary := trk.Summarise(); !reflect.DeepEqual(summary, tc.expectedSummary) {
+				t.Fatalf("expected summary %+v, got %+v", tc.expectedSummary, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package track
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+)
+
+// Track is the common model for activity data, regardless of the format of
+// the original file it was decoded from
+type Track struct {
+	// Sport uses the FIT sport names, e.g. cycling or running
+	Sport  string
+	Points []Point
+	Laps   []Lap
+}
+
+// Point is a single timestamped sample. Fields are nil when the value was
+// not recorded.
+type Point struct {
+	Time     time.Time
+	Position *geo.Point
+
+	// Elevation in metres
+	Elevation *float64
+	// HeartRate in beats per minute
+	HeartRate *float64
+	// Cadence in revolutions or steps per minute
+	Cadence *float64
+	// Power in watts
+	Power *float64
+	// Speed in metres per second
+	Speed *float64
+	// Distance in metres from the start of the activity
+	Distance *float64
+	// Temperature in degrees celsius
+	Temperature *float64
+}
+
+// Lap is a summary of a section of the track. Zero values are used when the
+// original did not record the value.
+type Lap struct {
+	StartTime time.Time
+
+	// TotalTime is the timer time in seconds
+	TotalTime float64
+	// Distance in metres
+	Distance float64
+	Calories float64
+
+	AvgHeartRate float64
+	MaxHeartRate float64
+	AvgCadence   float64
+	AvgPower     float64
+	MaxPower     float64
+	AvgSpeed     float64
+	MaxSpeed     float64
+}
+
+// Decode parses an original file in the given format into a Track
+func Decode(data []byte, originalFormat string) (*Track, error) {
+	var t *Track
+	var err error
+
+	switch originalFormat {
+	case format.FIT:
+		t, err = decodeFIT(data)
+	case format.GPX:
+		t, err = decodeGPX(data)
+	case format.TCX:
+		t, err = decodeTCX(data)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", originalFormat)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", originalFormat, err)
+	}
+
+	t.normalise()
+
+	return t, nil
+}
+
+// HasPositions returns true if any point in the track has a position
+func (t *Track) HasPositions() bool {
+	for _, p := range t.Points {
+		if p.Position != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// normalise orders the points by time and fills in distances from the
+// positions when the original did not record them
+func (t *Track) normalise() {
+	sort.SliceStable(t.Points, func(i, j int) bool {
+		return t.Points[i].Time.Before(t.Points[j].Time)
+	})
+
+	for _, p := range t.Points {
+		if p.Distance != nil {
+			return
+		}
+	}
+
+	var total float64
+	var last *geo.Point
+	for i := range t.Points {
+		p := t.Points[i].Position
+		if p == nil {
+			continue
+		}
+		if last != nil {
+			total += geo.Distance(*last, *p)
+		}
+		last = p
+
+		d := total
+		t.Points[i].Distance = &d
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
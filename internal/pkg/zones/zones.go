@@ -0,0 +1,204 @@
+// Package zones calculates the time spent in heart rate and power zones,
+// using the zones which applied on the date of each activity
+package zones
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/config"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// Kinds of zones
+const (
+	HeartRate = "heart_rate"
+	Power     = "power"
+)
+
+// maxGap is the longest time between points which is counted, longer gaps
+// are pauses in recording
+const maxGap = 30 * time.Second
+
+// heartRatePercentages are the lower bounds of the five heart rate zones as
+// a percentage of max heart rate
+var heartRatePercentages = []float64{0, 60, 70, 80, 90}
+
+// powerPercentages are the lower bounds of the seven Coggan power zones as
+// a percentage of FTP
+var powerPercentages = []float64{0, 55, 75, 90, 105, 120, 150}
+
+// Definition is a set of zones which applies from a date
+type Definition struct {
+	From time.Time
+	// Boundaries are the lower bound of each zone, the first is always zero
+	Boundaries []float64
+	// Threshold is the max heart rate or FTP, zero when only boundaries are
+	// configured
+	Threshold float64
+	// Resting is the resting heart rate, zero when not configured
+	Resting float64
+}
+
+// Zone returns the index of the zone containing the value
+func (d Definition) Zone(value float64) int {
+	zone := 0
+	for i, b := range d.Boundaries {
+		if value >= b {
+			zone = i
+		}
+	}
+	return zone
+}
+
+// Schedule holds the zone definitions of each kind over time
+type Schedule struct {
+	definitions map[string][]Definition
+}
+
+// ParseConfig reads the zones section of the tool config. Each kind has a
+// list of definitions with a from date and either explicit boundaries, the
+// lower bound of each zone after the first, or a max_hr or ftp from which
+// standard zones are calculated. Heart rate definitions may also have a
+// resting_hr. A nil schedule is returned when the section is missing.
+func ParseConfig(data any) (*Schedule, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	section, ok := data.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("zones config must be a map")
+	}
+
+	s := &Schedule{definitions: make(map[string][]Definition)}
+
+	for _, kind := range []string{HeartRate, Power} {
+		entries, _ := section[kind].([]any)
+		for i, e := range entries {
+			entry, ok := e.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s zones %d must be a map", kind, i)
+			}
+
+			from, err := config.Date(entry["from"], "from")
+			if err != nil {
+				return nil, fmt.Errorf("%s zones %d: %w", kind, i, err)
+			}
+
+			d := Definition{From: from, Boundaries: []float64{0}}
+
+			threshold, percentages := entry["max_hr"], heartRatePercentages
+			if kind == Power {
+				threshold, percentages = entry["ftp"], powerPercentages
+			}
+
+			if value, ok := config.Float(threshold); ok && value > 0 {
+				d.Threshold = value
+			}
+			if value, ok := config.Float(entry["resting_hr"]); ok && kind == HeartRate {
+				if value <= 0 || (d.Threshold > 0 && value >= d.Threshold) {
+					return nil, fmt.Errorf("%s zones %d: resting_hr must be between zero and max_hr", kind, i)
+				}
+				d.Resting = value
+			}
+
+			if raw, ok := entry["boundaries"].([]any); ok {
+				for _, r := range raw {
+					b, ok := config.Float(r)
+					if !ok || b <= d.Boundaries[len(d.Boundaries)-1] {
+						return nil, fmt.Errorf("%s zones %d: boundaries must be increasing numbers", kind, i)
+					}
+					d.Boundaries = append(d.Boundaries, b)
+				}
+			} else if d.Threshold > 0 {
+				for _, p := range percentages[1:] {
+					d.Boundaries = append(d.Boundaries, d.Threshold*p/100)
+				}
+			} else {
+				return nil, fmt.Errorf("%s zones %d must have boundaries or a threshold", kind, i)
+			}
+
+			s.definitions[kind] = append(s.definitions[kind], d)
+		}
+
+		sort.Slice(s.definitions[kind], func(i, j int) bool {
+			return s.definitions[kind][i].From.Before(s.definitions[kind][j].From)
+		})
+	}
+
+	return s, nil
+}
+
+// At returns the definition of a kind of zones which applied at the time,
+// which is the latest to start before it. Activities before the first
+// definition use the first.
+func (s *Schedule) At(kind string, t time.Time) (Definition, bool) {
+	if s == nil || len(s.definitions[kind]) == 0 {
+		return Definition{}, false
+	}
+
+	definitions := s.definitions[kind]
+	current := definitions[0]
+	for _, d := range definitions[1:] {
+		if !d.From.After(t) {
+			current = d
+		}
+	}
+
+	return current, true
+}
+
+// Digest identifies the schedule, it changes when any definition changes
+// so times in zones can be calculated again
+func (s *Schedule) Digest() string {
+	if s == nil {
+		return ""
+	}
+
+	h := fnv.New64a()
+	for _, kind := range []string{HeartRate, Power} {
+		for _, d := range s.definitions[kind] {
+			fmt.Fprintf(h, "%s:%s:%v:%v:%v;", kind, d.From.Format("2006-01-02"), d.Boundaries, d.Threshold, d.Resting)
+		}
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// TimeInZones returns the seconds spent in each zone of the definition. The
+// time between each point and the next is counted in the zone of the
+// point's value, points without a value and pauses are not counted. False
+// is returned when no point has a value.
+func TimeInZones(points []track.Point, d Definition, value func(track.Point) *float64) ([]float64, bool) {
+	seconds := make([]float64, len(d.Boundaries))
+	found := false
+
+	for i := 0; i < len(points)-1; i++ {
+		v := value(points[i])
+		if v == nil {
+			continue
+		}
+		found = true
+
+		gap := points[i+1].Time.Sub(points[i].Time)
+		if gap <= 0 || gap > maxGap {
+			continue
+		}
+
+		seconds[d.Zone(*v)] += gap.Seconds()
+	}
+
+	return seconds, found
+}
+
+// Values returns the function which gets the value of a kind of zone from
+// a point
+func Values(kind string) func(track.Point) *float64 {
+	if kind == Power {
+		return func(p track.Point) *float64 { return p.Power }
+	}
+	return func(p track.Point) *float64 { return p.HeartRate }
+}
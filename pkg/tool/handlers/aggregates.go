@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BuildAggregatesHandler returns a handler which serves the weekly, monthly
+// or yearly totals for the period in the path. The from and to dates, type
+// and gear_id query string parameters filter the totals and by sets how
+// they are grouped: type, gear, type_gear or total.
+func BuildAggregatesHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		period := mux.Vars(r)["period"]
+		if !utils.ValidAggregatePeriod(period) {
+			http.Error(w, "period must be one of "+strings.Join(utils.AggregatePeriods, ", "), http.StatusNotFound)
+			return
+		}
+
+		filter := queries.AggregateFilter{
+			Period:  period,
+			Type:    r.URL.Query().Get("type"),
+			GearID:  r.URL.Query().Get("gear_id"),
+			GroupBy: r.URL.Query().Get("by"),
+		}
+		if filter.GroupBy == "" {
+			filter.GroupBy = queries.GroupByType
+		}
+		if !queries.ValidAggregateGrouping(filter.GroupBy) {
+			http.Error(w, "by must be type, gear, type_gear or total", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		filter.From, err = dateParam(r, "from", time.Unix(0, 0))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.To, err = dateParam(r, "to", time.Now().AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		aggregates, err := queries.Aggregates(r.Context(), db, filter)
+		if err != nil {
+			log.Printf("failed to get aggregates: %s", err)
+			http.Error(w, "failed to get aggregates", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, aggregates)
+	}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// maxActivities limits the activities returned in one request
+const maxActivities = 500
+
+// maxBodySize limits the size of note and annotation request bodies
+const maxBodySize = 64 * 1024
+
+// BuildActivitiesHandler returns a handler which serves the list of
+// activities, filtered by the q, tag, type, season or from and to,
+// annotation and class query string parameters. q is a full text query, tag
+// may be repeated to require several tags, annotation is either a key or
+// key=value and class is commute, training, indoor or virtual.
+func BuildActivitiesHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		from, to, err := periodParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter := queries.ActivityFilter{
+			Query: strings.TrimSpace(query.Get("q")),
+			Type:  query.Get("type"),
+			From:  from,
+			To:    to,
+			Limit: 100,
+		}
+
+		for _, raw := range query["tag"] {
+			tag, err := utils.NormaliseTag(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.Tags = append(filter.Tags, tag)
+		}
+
+		if raw := query.Get("annotation"); raw != "" {
+			key, value, _ := strings.Cut(raw, "=")
+			filter.AnnotationKey, err = utils.NormaliseTag(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.AnnotationValue = strings.TrimSpace(value)
+		}
+
+		if raw := query.Get("class"); raw != "" {
+			valid := false
+			for _, class := range queries.ActivityClasses {
+				valid = valid || class == raw
+			}
+			if !valid {
+				http.Error(w, fmt.Sprintf("class must be one of %s", strings.Join(queries.ActivityClasses, ", ")), http.StatusBadRequest)
+				return
+			}
+			filter.Class = raw
+		}
+
+		if raw := query.Get("limit"); raw != "" {
+			limit, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil || limit == 0 || limit > maxActivities {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxActivities), http.StatusBadRequest)
+				return
+			}
+			filter.Limit = uint(limit)
+		}
+		if raw := query.Get("offset"); raw != "" {
+			offset, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				http.Error(w, "offset must be a positive number", http.StatusBadRequest)
+				return
+			}
+			filter.Offset = uint(offset)
+		}
+
+		activities, err := queries.ListActivities(r.Context(), db, filter)
+		if err != nil {
+			log.Printf("failed to list activities: %s", err)
+			http.Error(w, "failed to list activities", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, activities)
+	}
+}
+
+// BuildTagsHandler returns a handler which serves the tags in use with the
+// number of activities they are on
+func BuildTagsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		counts, err := queries.TagCounts(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get tags: %s", err)
+			http.Error(w, "failed to get tags", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, counts)
+	}
+}
+
+// BuildActivityAnnotationsHandler returns a handler which serves the tags,
+// notes and annotations of an activity
+func BuildActivityAnnotationsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		annotations, err := queries.ActivityAnnotations(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get annotations for %s: %s", id, err)
+			http.Error(w, "failed to get annotations", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, annotations)
+	}
+}
+
+// BuildAddTagHandler returns a handler which adds the tag in the path to an
+// activity
+func BuildAddTagHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		tag, err := utils.NormaliseTag(mux.Vars(r)["tag"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = utils.AddTag(r.Context(), db, id, tag)
+		if err != nil {
+			writeAnnotationError(w, id, "add tag", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildRemoveTagHandler returns a handler which removes the tag in the path
+// from an activity
+func BuildRemoveTagHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		tag, err := utils.NormaliseTag(mux.Vars(r)["tag"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = utils.RemoveTag(r.Context(), db, id, tag)
+		if err != nil {
+			writeAnnotationError(w, id, "remove tag", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildAddNoteHandler returns a handler which adds the request body as a
+// note on an activity
+func BuildAddNoteHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "failed to read note", http.StatusBadRequest)
+			return
+		}
+
+		noteID, err := utils.AddNote(r.Context(), db, id, string(body))
+		if err != nil {
+			writeAnnotationError(w, id, "add note", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]int64{"id": noteID})
+	}
+}
+
+// BuildRemoveNoteHandler returns a handler which removes a note from an
+// activity
+func BuildRemoveNoteHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		noteID, err := strconv.ParseInt(mux.Vars(r)["note_id"], 10, 64)
+		if err != nil {
+			http.Error(w, "note ID must be a number", http.StatusBadRequest)
+			return
+		}
+
+		err = utils.RemoveNote(r.Context(), db, id, noteID)
+		if err != nil {
+			writeAnnotationError(w, id, "remove note", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildSetAnnotationHandler returns a handler which sets the annotation in
+// the path on an activity to the request body
+func BuildSetAnnotationHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		key, err := utils.NormaliseTag(mux.Vars(r)["key"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "failed to read annotation", http.StatusBadRequest)
+			return
+		}
+
+		err = utils.SetAnnotation(r.Context(), db, id, key, string(body))
+		if err != nil {
+			writeAnnotationError(w, id, "set annotation", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildRemoveAnnotationHandler returns a handler which removes the
+// annotation in the path from an activity
+func BuildRemoveAnnotationHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		key, err := utils.NormaliseTag(mux.Vars(r)["key"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = utils.RemoveAnnotation(r.Context(), db, id, key)
+		if err != nil {
+			writeAnnotationError(w, id, "remove annotation", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeAnnotationError responds with not found for missing activities and
+// notes, bad request for invalid input and a server error otherwise
+func writeAnnotationError(w http.ResponseWriter, id, action string, err error) {
+	switch {
+	case errors.Is(err, utils.ErrActivityNotFound), errors.Is(err, utils.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, utils.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("failed to %s on %s: %s", action, id, err)
+		http.Error(w, fmt.Sprintf("failed to %s", action), http.StatusInternalServerError)
+	}
+}
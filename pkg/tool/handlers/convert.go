@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BuildConvertHandler returns a handler which serves an activity's original
+// converted to the format in the request path, with the privacy policy
+// applied
+func BuildConvertHandler(db *sql.DB, bucket *storage.BucketHandle, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		targetFormat := mux.Vars(r)["format"]
+
+		contentType, ok := track.ContentTypes[targetFormat]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unsupported format: %s", targetFormat), http.StatusBadRequest)
+			return
+		}
+
+		data, err := utils.ConvertOriginal(r.Context(), db, bucket, policy, id, targetFormat)
+		if errors.Is(err, utils.ErrNoOriginal) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to convert %s to %s: %s", id, targetFormat, err)
+			http.Error(w, "failed to convert activity", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, id, targetFormat))
+		_, err = w.Write(data)
+		if err != nil {
+			log.Printf("failed to write response: %s", err)
+		}
+	}
+}
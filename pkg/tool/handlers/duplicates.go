@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BuildDuplicatesHandler returns a handler which serves the groups of
+// activities detected as recording the same workout
+func BuildDuplicatesHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := queries.DuplicateGroups(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get duplicates: %s", err)
+			http.Error(w, "failed to get duplicates", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, groups)
+	}
+}
+
+// BuildMarkCanonicalHandler returns a handler which marks an activity as
+// the canonical activity of its duplicate group
+func BuildMarkCanonicalHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		err := utils.MarkCanonical(r.Context(), db, id)
+		if err != nil {
+			writeAnnotationError(w, id, "mark canonical", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildSetNotDuplicateHandler returns a handler which marks an activity as
+// wrongly detected as a duplicate on PUT, and clears the mark on DELETE
+func BuildSetNotDuplicateHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		err := utils.SetNotDuplicate(r.Context(), db, id, r.Method == http.MethodPut)
+		if err != nil {
+			writeAnnotationError(w, id, "set not duplicate", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// BuildActivityPowerCurveHandler returns a handler which serves the power
+// curve of an activity
+func BuildActivityPowerCurveHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		curve, err := queries.ActivityPowerCurve(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get power curve for %s: %s", id, err)
+			http.Error(w, "failed to get power curve", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, curve)
+	}
+}
+
+// BuildActivityBestEffortsHandler returns a handler which serves the best
+// efforts of an activity
+func BuildActivityBestEffortsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		bestEfforts, err := queries.ActivityBestEfforts(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get best efforts for %s: %s", id, err)
+			http.Error(w, "failed to get best efforts", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, bestEfforts)
+	}
+}
+
+// BuildPowerCurveHandler returns a handler which serves the best power for
+// each duration over the period in the query string, all time by default
+func BuildPowerCurveHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := periodParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		curve, err := queries.PowerCurveBests(r.Context(), db, from, to)
+		if err != nil {
+			log.Printf("failed to get power curve bests: %s", err)
+			http.Error(w, "failed to get power curve", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, curve)
+	}
+}
+
+// BuildBestEffortsHandler returns a handler which serves the fastest time
+// for each distance over the period in the query string, all time by
+// default
+func BuildBestEffortsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := periodParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bestEfforts, err := queries.BestEffortBests(r.Context(), db, from, to)
+		if err != nil {
+			log.Printf("failed to get best effort bests: %s", err)
+			http.Error(w, "failed to get best efforts", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, bestEfforts)
+	}
+}
+
+// BuildPowerCurveSeasonsHandler returns a handler which serves the best
+// power for each duration in each season
+func BuildPowerCurveSeasonsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		seasons, err := queries.PowerCurveSeasonBests(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get power curve season bests: %s", err)
+			http.Error(w, "failed to get power curve", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, seasons)
+	}
+}
+
+// BuildBestEffortsSeasonsHandler returns a handler which serves the fastest
+// time for each distance in each season
+func BuildBestEffortsSeasonsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		seasons, err := queries.BestEffortSeasonBests(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get best effort season bests: %s", err)
+			http.Error(w, "failed to get best efforts", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, seasons)
+	}
+}
+
+// periodParams returns the period from the season query string parameter,
+// a calendar year, or from the from and to dates which default to all
+// activities
+func periodParams(r *http.Request) (time.Time, time.Time, error) {
+	if raw := r.URL.Query().Get("season"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil || year < 1900 || year > 9999 {
+			return time.Time{}, time.Time{}, fmt.Errorf("season must be a year such as 2023")
+		}
+
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0), nil
+	}
+
+	from, err := dateParam(r, "from", time.Unix(0, 0))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := dateParam(r, "to", time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/explorer"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// BuildExplorerHandler returns a handler which serves the explorer tile
+// metrics. The before query parameter, a date, limits the metrics to tiles
+// first visited before then.
+func BuildExplorerHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, _, ok := explorerResult(w, r, db)
+		if !ok {
+			return
+		}
+
+		writeJSON(w, result.Stats)
+	}
+}
+
+// BuildExplorerGeoJSONHandler returns a handler which serves the visited
+// explorer tiles as a GeoJSON FeatureCollection of polygons, with the
+// first visit and whether the tile is in the max square or max cluster in
+// the properties of each. Tiles overlapping a privacy zone are left out.
+func BuildExplorerGeoJSONHandler(db *sql.DB, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, visited, ok := explorerResult(w, r, db)
+		if !ok {
+			return
+		}
+
+		features := make([]map[string]any, 0, len(visited)+1)
+		for _, v := range visited {
+			t := v.Tile()
+			if policy.Overlaps(t.Bounds()) {
+				continue
+			}
+			inMaxCluster, inCluster := result.Cluster[t]
+
+			features = append(features, map[string]any{
+				"type":     "Feature",
+				"geometry": tilePolygon(t, t),
+				"properties": map[string]any{
+					"kind":              "tile",
+					"x":                 v.X,
+					"y":                 v.Y,
+					"first_visited_at":  v.FirstVisitedAt,
+					"first_activity_id": v.FirstActivityID,
+					"visit_count":       v.VisitCount,
+					"cluster":           inCluster,
+					"max_cluster":       inMaxCluster,
+					"max_square":        result.MaxSquare.Contains(t),
+				},
+			})
+		}
+
+		if s := result.MaxSquare; s.Size > 0 {
+			features = append(features, map[string]any{
+				"type": "Feature",
+				"geometry": tilePolygon(
+					tiles.Tile{Z: explorer.Zoom, X: s.X, Y: s.Y},
+					tiles.Tile{Z: explorer.Zoom, X: s.X + s.Size - 1, Y: s.Y + s.Size - 1},
+				),
+				"properties": map[string]any{
+					"kind": "max_square",
+					"size": s.Size,
+				},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		writeJSON(w, map[string]any{
+			"type":     "FeatureCollection",
+			"features": features,
+		})
+	}
+}
+
+// BuildActivityExplorerHandler returns a handler which lists the explorer
+// tiles visited by an activity, except those overlapping a privacy zone
+func BuildActivityExplorerHandler(db *sql.DB, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		visited, err := queries.ActivityExplorerTiles(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get explorer tiles for %s: %s", id, err)
+			http.Error(w, "failed to get explorer tiles", http.StatusInternalServerError)
+			return
+		}
+
+		shown := make([]queries.ActivityExplorerTile, 0, len(visited))
+		for _, v := range visited {
+			t := tiles.Tile{Z: explorer.Zoom, X: v.X, Y: v.Y}
+			if !policy.Overlaps(t.Bounds()) {
+				shown = append(shown, v)
+			}
+		}
+
+		writeJSON(w, shown)
+	}
+}
+
+func explorerResult(w http.ResponseWriter, r *http.Request, db *sql.DB) (explorer.Result, []queries.ExplorerTile, bool) {
+	before, err := dateParam(r, "before", time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return explorer.Result{}, nil, false
+	}
+
+	visited, err := queries.ExplorerTiles(r.Context(), db, before)
+	if err != nil {
+		log.Printf("failed to get explorer tiles: %s", err)
+		http.Error(w, "failed to get explorer tiles", http.StatusInternalServerError)
+		return explorer.Result{}, nil, false
+	}
+
+	ts := make([]tiles.Tile, len(visited))
+	for i, v := range visited {
+		ts[i] = v.Tile()
+	}
+
+	return explorer.Compute(ts), visited, true
+}
+
+// tilePolygon returns a GeoJSON polygon covering the tiles from the top
+// left to the bottom right tile
+func tilePolygon(topLeft, bottomRight tiles.Tile) map[string]any {
+	a, b := topLeft.Bounds(), bottomRight.Bounds()
+
+	return map[string]any{
+		"type": "Polygon",
+		"coordinates": [][][2]float64{{
+			{a.MinLon, a.MaxLat},
+			{b.MaxLon, a.MaxLat},
+			{b.MaxLon, b.MinLat},
+			{a.MinLon, b.MinLat},
+			{a.MinLon, a.MaxLat},
+		}},
+	}
+}
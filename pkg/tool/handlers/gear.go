@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// BuildGearHandler returns a handler which serves the cumulative distance
+// and time of each piece of gear
+func BuildGearHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		totals, err := queries.GearTotals(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get gear totals: %s", err)
+			http.Error(w, "failed to get gear", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, totals)
+	}
+}
+
+// BuildGearComponentsHandler returns a handler which serves the use of each
+// maintenance component and whether it is due for service
+func BuildGearComponentsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		components, err := queries.GearComponents(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get gear components: %s", err)
+			http.Error(w, "failed to get gear components", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, components)
+	}
+}
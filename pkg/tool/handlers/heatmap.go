@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/heatmap"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BuildHeatmapHandler returns a handler which serves the heatmap tiles for
+// a layer, such as all, run or ride-2023. Tiles with no activities are
+// served as transparent tiles so map clients don't show errors.
+func BuildHeatmapHandler(bucket *storage.BucketHandle) func(http.ResponseWriter, *http.Request) {
+	emptyTile, err := heatmap.EmptyPNG()
+	if err != nil {
+		log.Fatalf("failed to create empty tile: %s", err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		layer := vars["layer"]
+		if !utils.ValidHeatmapLayer(layer) {
+			http.Error(w, "invalid layer", http.StatusBadRequest)
+			return
+		}
+
+		var t tiles.Tile
+		var errs [3]error
+		t.Z, errs[0] = strconv.Atoi(vars["z"])
+		t.X, errs[1] = strconv.Atoi(vars["x"])
+		t.Y, errs[2] = strconv.Atoi(vars["y"])
+		if errs[0] != nil || errs[1] != nil || errs[2] != nil || !t.Valid() {
+			http.Error(w, "invalid tile", http.StatusBadRequest)
+			return
+		}
+
+		data := emptyTile
+		if t.Z >= utils.HeatmapMinZoom && t.Z <= utils.HeatmapMaxZoom {
+			reader, err := bucket.Object(utils.HeatmapObjectName(layer, t)).NewReader(r.Context())
+			if err != nil && err != storage.ErrObjectNotExist {
+				log.Printf("failed to read tile %s %s: %s", layer, t, err)
+				http.Error(w, "failed to read tile", http.StatusInternalServerError)
+				return
+			}
+			if err == nil {
+				defer reader.Close()
+				data, err = io.ReadAll(reader)
+				if err != nil {
+					log.Printf("failed to read tile %s %s: %s", layer, t, err)
+					http.Error(w, "failed to read tile", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, err := w.Write(data)
+		if err != nil {
+			log.Printf("failed to write response: %s", err)
+		}
+	}
+}
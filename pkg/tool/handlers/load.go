@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// defaultTrainingLoadDays is the period of training load served when no
+// from date is given
+const defaultTrainingLoadDays = 90
+
+// BuildActivityTrainingLoadHandler returns a handler which serves the
+// training stress of an activity
+func BuildActivityTrainingLoadHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		activityLoad, found, err := queries.ActivityTrainingLoad(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get training load for %s: %s", id, err)
+			http.Error(w, "failed to get training load", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "training load not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, activityLoad)
+	}
+}
+
+// BuildTrainingLoadHandler returns a handler which serves the daily
+// fitness, fatigue and form between the from and to dates in the query
+// string, which default to the last 90 days
+func BuildTrainingLoadHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		to, err := dateParam(r, "to", time.Now().AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := dateParam(r, "from", to.AddDate(0, 0, -defaultTrainingLoadDays))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		days, err := queries.TrainingLoadDays(r.Context(), db, from, to)
+		if err != nil {
+			log.Printf("failed to get training load days: %s", err)
+			http.Error(w, "failed to get training load", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, days)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BuildMapHandler returns a handler which serves the route map for an
+// activity in the format in the request path, with the privacy policy
+// applied
+func BuildMapHandler(db *sql.DB, bucket *storage.BucketHandle, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		mapFormat := mux.Vars(r)["format"]
+
+		contentType, ok := utils.MapContentTypes[mapFormat]
+		if !ok {
+			http.Error(w, "unsupported map format", http.StatusBadRequest)
+			return
+		}
+
+		data, err := utils.ReadRouteMap(r.Context(), db, bucket, policy, id, mapFormat)
+		if errors.Is(err, utils.ErrNoRoute) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to get %s map for %s: %s", mapFormat, id, err)
+			http.Error(w, "failed to get map", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, err = w.Write(data)
+		if err != nil {
+			log.Printf("failed to write response: %s", err)
+		}
+	}
+}
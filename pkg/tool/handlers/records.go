@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// maxEvents limits the events returned in one request
+const maxEvents = 500
+
+// BuildPersonalRecordsHandler returns a handler which serves the current
+// personal record of each sport, category and key
+func BuildPersonalRecordsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := queries.CurrentPersonalRecords(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get personal records: %s", err)
+			http.Error(w, "failed to get personal records", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, current)
+	}
+}
+
+// BuildActivityPersonalRecordsHandler returns a handler which serves the
+// personal records set by an activity
+func BuildActivityPersonalRecordsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		activityRecords, err := queries.ActivityPersonalRecords(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get personal records for %s: %s", id, err)
+			http.Error(w, "failed to get personal records", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, activityRecords)
+	}
+}
+
+// BuildEventsHandler returns a handler which serves events after the event
+// ID in the after query string parameter, so notifiers can poll for new
+// events instead of using the webhook
+func BuildEventsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var after int64
+		if raw := r.URL.Query().Get("after"); raw != "" {
+			var err error
+			after, err = strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				http.Error(w, "after must be an event ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		limit := uint64(100)
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			var err error
+			limit, err = strconv.ParseUint(raw, 10, 64)
+			if err != nil || limit == 0 || limit > maxEvents {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEvents), http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, err := queries.Events(r.Context(), db, after, uint(limit), false)
+		if err != nil {
+			log.Printf("failed to get events: %s", err)
+			http.Error(w, "failed to get events", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, events)
+	}
+}
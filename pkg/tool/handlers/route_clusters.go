@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// BuildRoutesHandler returns a handler which serves the repeated routes,
+// optionally of the activity type in the type query string parameter.
+// Routes followed by fewer than min_activities activities, default 2, are
+// left out.
+func BuildRoutesHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minActivities := 2
+		if raw := r.URL.Query().Get("min_activities"); raw != "" {
+			var err error
+			minActivities, err = strconv.Atoi(raw)
+			if err != nil || minActivities < 1 {
+				http.Error(w, "min_activities must be a positive number", http.StatusBadRequest)
+				return
+			}
+		}
+
+		routes, err := queries.RouteSummaries(r.Context(), db, r.URL.Query().Get("type"), minActivities)
+		if err != nil {
+			log.Printf("failed to get routes: %s", err)
+			http.Error(w, "failed to get routes", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, routes)
+	}
+}
+
+// BuildRouteHandler returns a handler which serves the history of efforts
+// on a route with a summary of each year. The route's geometry is served as
+// a MultiLineString with the privacy zones removed.
+func BuildRouteHandler(db *sql.DB, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["route_id"], 10, 64)
+		if err != nil {
+			http.Error(w, "route ID must be a number", http.StatusBadRequest)
+			return
+		}
+
+		history, found, err := queries.RouteClusterHistory(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get route %d: %s", id, err)
+			http.Error(w, "failed to get route", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "route not found", http.StatusNotFound)
+			return
+		}
+
+		history.Geometry, err = privateGeometry(policy, history.Geometry, fmt.Sprintf("route-%d", id))
+		if err != nil {
+			log.Printf("failed to filter geometry of route %d: %s", id, err)
+			http.Error(w, "failed to get route", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, history)
+	}
+}
+
+// privateGeometry returns the visible parts of a stored LineString under the
+// privacy policy as a MultiLineString, see privacy.Policy.Line for the seed
+func privateGeometry(policy *privacy.Policy, geometry []byte, seed string) (json.RawMessage, error) {
+	line, err := queries.ParseLineString(geometry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse geometry: %w", err)
+	}
+
+	coordinates := [][][2]float64{}
+	for _, segment := range policy.Line(line, seed) {
+		part := make([][2]float64, len(segment))
+		for i, p := range segment {
+			part[i] = [2]float64{p.Lon, p.Lat}
+		}
+		coordinates = append(coordinates, part)
+	}
+
+	return json.Marshal(map[string]any{
+		"type":        "MultiLineString",
+		"coordinates": coordinates,
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// defaultSearchRadius is used for near searches without a radius, in metres
+const defaultSearchRadius = 100
+
+// maxSearchRadius limits near searches to a reasonable area, in metres
+const maxSearchRadius = 50000
+
+// BuildSearchNearHandler returns a handler which lists the activities that
+// passed within a radius of the lat and lon in the query string, outside
+// the privacy zones
+func BuildSearchNearHandler(db *sql.DB, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, err := floatParams(r, "lat", "lon")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		radius := float64(defaultSearchRadius)
+		if rawRadius := r.URL.Query().Get("radius"); rawRadius != "" {
+			radius, err = strconv.ParseFloat(rawRadius, 64)
+			if err != nil || radius <= 0 || radius > maxSearchRadius {
+				http.Error(w, fmt.Sprintf("radius must be between 0 and %d metres", maxSearchRadius), http.StatusBadRequest)
+				return
+			}
+		}
+
+		matches, err := queries.ActivitiesNear(
+			r.Context(),
+			db,
+			geo.Point{Lat: values[0], Lon: values[1]},
+			radius,
+			policy,
+		)
+		if err != nil {
+			log.Printf("failed to search near point: %s", err)
+			http.Error(w, "failed to search activities", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, matches)
+	}
+}
+
+// BuildSearchBBoxHandler returns a handler which lists the activities with
+// routes crossing the bounding box in the query string, outside the privacy
+// zones
+func BuildSearchBBoxHandler(db *sql.DB, policy *privacy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, err := floatParams(r, "min_lat", "min_lon", "max_lat", "max_lon")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		box := geo.BBox{
+			MinLat: values[0],
+			MinLon: values[1],
+			MaxLat: values[2],
+			MaxLon: values[3],
+		}
+		if box.MinLat > box.MaxLat || box.MinLon > box.MaxLon {
+			http.Error(w, "min values must be less than max values", http.StatusBadRequest)
+			return
+		}
+
+		matches, err := queries.ActivitiesInBBox(r.Context(), db, box, policy)
+		if err != nil {
+			log.Printf("failed to search bounding box: %s", err)
+			http.Error(w, "failed to search activities", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, matches)
+	}
+}
+
+// floatParams parses the named query string parameters, all of which are
+// required
+func floatParams(r *http.Request, names ...string) ([]float64, error) {
+	values := make([]float64, len(names))
+	for i, name := range names {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			return nil, fmt.Errorf("missing parameter: %s", name)
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter: %s", name)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to marshal response: %s", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	// handlers serving JSON based formats such as GeoJSON set their own type
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		log.Printf("failed to write response: %s", err)
+	}
+}
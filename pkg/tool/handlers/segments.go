@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// maxGPXSize limits the size of GPX files used to create segments
+const maxGPXSize = 10 * 1024 * 1024
+
+// BuildSegmentsHandler returns a handler which serves the user defined
+// segments
+func BuildSegmentsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := queries.Segments(r.Context(), db)
+		if err != nil {
+			log.Printf("failed to get segments: %s", err)
+			http.Error(w, "failed to get segments", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, list)
+	}
+}
+
+// BuildCreateSegmentHandler returns a handler which creates a segment named
+// by the name query string parameter. The segment follows the GPX track in
+// the request body, or when there is an activity_id parameter, the
+// activity's track between the points nearest start_lat, start_lon and
+// end_lat, end_lon.
+func BuildCreateSegmentHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var line []geo.Point
+		activityID := r.URL.Query().Get("activity_id")
+
+		if activityID != "" {
+			values, err := floatParams(r, "start_lat", "start_lon", "end_lat", "end_lon")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			line, err = utils.SegmentLineFromActivity(
+				r.Context(),
+				db,
+				activityID,
+				geo.Point{Lat: values[0], Lon: values[1]},
+				geo.Point{Lat: values[2], Lon: values[3]},
+			)
+			if err != nil {
+				writeSegmentError(w, "create segment", err)
+				return
+			}
+		} else {
+			data, err := io.ReadAll(io.LimitReader(r.Body, maxGPXSize))
+			if err != nil {
+				http.Error(w, "failed to read gpx", http.StatusBadRequest)
+				return
+			}
+
+			line, err = utils.SegmentLineFromGPX(data)
+			if err != nil {
+				writeSegmentError(w, "create segment", err)
+				return
+			}
+		}
+
+		id, err := utils.CreateSegment(r.Context(), db, r.URL.Query().Get("name"), line, activityID)
+		if err != nil {
+			writeSegmentError(w, "create segment", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]int64{"id": id})
+	}
+}
+
+// BuildSegmentHandler returns a handler which serves a segment with its
+// efforts, fastest first
+func BuildSegmentHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["segment_id"], 10, 64)
+		if err != nil {
+			http.Error(w, "segment ID must be a number", http.StatusBadRequest)
+			return
+		}
+
+		leaderboard, found, err := queries.SegmentEfforts(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get segment %d: %s", id, err)
+			http.Error(w, "failed to get segment", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "segment not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, leaderboard)
+	}
+}
+
+// BuildDeleteSegmentHandler returns a handler which deletes a segment and
+// its efforts
+func BuildDeleteSegmentHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["segment_id"], 10, 64)
+		if err != nil {
+			http.Error(w, "segment ID must be a number", http.StatusBadRequest)
+			return
+		}
+
+		err = utils.DeleteSegment(r.Context(), db, id)
+		if err != nil {
+			writeSegmentError(w, "delete segment", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BuildActivitySegmentEffortsHandler returns a handler which serves the
+// efforts of an activity on the user defined segments
+func BuildActivitySegmentEffortsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		efforts, err := queries.ActivitySegmentEfforts(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get segment efforts for %s: %s", id, err)
+			http.Error(w, "failed to get segment efforts", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, efforts)
+	}
+}
+
+// BuildStravaSegmentsHandler returns a handler which serves the Strava
+// segments with efforts in the archived activities, optionally only those
+// with at least min_efforts efforts
+func BuildStravaSegmentsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minEfforts := 1
+		if raw := r.URL.Query().Get("min_efforts"); raw != "" {
+			var err error
+			minEfforts, err = strconv.Atoi(raw)
+			if err != nil || minEfforts < 1 {
+				http.Error(w, "min_efforts must be a positive number", http.StatusBadRequest)
+				return
+			}
+		}
+
+		list, err := queries.StravaSegments(r.Context(), db, minEfforts)
+		if err != nil {
+			log.Printf("failed to get strava segments: %s", err)
+			http.Error(w, "failed to get strava segments", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, list)
+	}
+}
+
+// BuildStravaSegmentHandler returns a handler which serves a Strava segment
+// with its efforts, most recent first
+func BuildStravaSegmentHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["segment_id"], 10, 64)
+		if err != nil {
+			http.Error(w, "segment ID must be a number", http.StatusBadRequest)
+			return
+		}
+
+		history, found, err := queries.StravaSegmentEfforts(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get strava segment %d: %s", id, err)
+			http.Error(w, "failed to get strava segment", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "strava segment not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, history)
+	}
+}
+
+// writeSegmentError responds with not found for missing segments and
+// activities, bad request for invalid tracks and names and a server error
+// otherwise
+func writeSegmentError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, utils.ErrActivityNotFound), errors.Is(err, utils.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, utils.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("failed to %s: %s", action, err)
+		http.Error(w, fmt.Sprintf("failed to %s", action), http.StatusInternalServerError)
+	}
+}
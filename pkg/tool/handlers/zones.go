@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// BuildActivityZonesHandler returns a handler which serves the time an
+// activity spent in each heart rate and power zone
+func BuildActivityZonesHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		activityZones, err := queries.ActivityZones(r.Context(), db, id)
+		if err != nil {
+			log.Printf("failed to get zones for %s: %s", id, err)
+			http.Error(w, "failed to get zones", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, activityZones)
+	}
+}
+
+// BuildZoneTotalsHandler returns a handler which serves the total time in
+// each zone for activities between the from and to dates in the query
+// string, which default to all activities
+func BuildZoneTotalsHandler(db *sql.DB) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, err := dateParam(r, "from", time.Unix(0, 0))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := dateParam(r, "to", time.Now().AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		totals, err := queries.ZoneTotals(r.Context(), db, from, to)
+		if err != nil {
+			log.Printf("failed to get zone totals: %s", err)
+			http.Error(w, "failed to get zone totals", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, totals)
+	}
+}
+
+// dateParam parses a date query string parameter, the default is returned
+// when it's not set
+func dateParam(r *http.Request, name string, defaultValue time.Time) (time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date such as 2023-01-31", name)
+	}
+
+	return t, nil
+}
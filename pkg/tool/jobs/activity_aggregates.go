@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// ActivityAggregates is a job that reads the totals of each activity from
+// the archived Strava data and maintains weekly, monthly and yearly totals
+// by type and gear. Duplicate activities are not counted. Only the periods
+// containing changed activities are recalculated.
+type ActivityAggregates struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (a *ActivityAggregates) Name() string {
+	return "activity-aggregates"
+}
+
+func (a *ActivityAggregates) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(a.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(a.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", a.DB)
+
+		// select synced activities where the data has changed since the
+		// stats were read, or which have been marked or unmarked as a
+		// duplicate, with the previous local start so the period it was
+		// counted in is also recalculated
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.data_digest"),
+			goqu.L("a.duplicate_of IS NULL").As("counted"),
+			goqu.I("s.start_date_local"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			LeftJoin(
+				goqu.T("activity_stats").Schema("activities").As("s"),
+				goqu.On(goqu.I("s.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(
+				goqu.I("a.data_digest").Neq(""),
+				goqu.Or(
+					goqu.I("s.activity_id").IsNull(),
+					goqu.I("s.data_digest").Neq(goqu.I("a.data_digest")),
+					goqu.L("s.counted <> (a.duplicate_of IS NULL)"),
+				),
+			).
+			Order(goqu.I("a.id").Asc())
+
+		var rows []struct {
+			ID                     string     `db:"id"`
+			DataDigest             string     `db:"data_digest"`
+			Counted                bool       `db:"counted"`
+			PreviousStartDateLocal *time.Time `db:"start_date_local"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activities: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		// changed holds the start of each period which needs to be
+		// recalculated
+		changed := make(map[string]map[time.Time]bool)
+		for _, period := range utils.AggregatePeriods {
+			changed[period] = make(map[time.Time]bool)
+		}
+		markChanged := func(local time.Time) {
+			for _, period := range utils.AggregatePeriods {
+				changed[period][utils.PeriodStart(period, local)] = true
+			}
+		}
+
+		for _, row := range rows {
+			activity, err := utils.ReadActivityData(ctx, bucket, row.ID)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(row.ID, "data not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			record := goqu.Record{
+				"activity_id":      row.ID,
+				"data_digest":      row.DataDigest,
+				"type":             string(activity.Type),
+				"gear_id":          activity.GearId,
+				"start_date_local": activity.StartDateLocal,
+				"distance":         activity.Distance,
+				"moving_time":      activity.MovingTime,
+				"elapsed_time":     activity.ElapsedTime,
+				"elevation_gain":   activity.TotalElevationGain,
+				"kilojoules":       activity.Kilojoules,
+				"calories":         activity.Calories,
+				"counted":          row.Counted,
+				"updated_at":       time.Now(),
+			}
+			_, err = goquDB.Insert("activities.activity_stats").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to upsert activity stats %s: %v", row.ID, err)
+				return
+			}
+
+			if row.PreviousStartDateLocal != nil {
+				markChanged(*row.PreviousStartDateLocal)
+			}
+			markChanged(activity.StartDateLocal)
+		}
+
+		for _, period := range utils.AggregatePeriods {
+			if len(changed[period]) == 0 {
+				continue
+			}
+
+			var starts []string
+			for start := range changed[period] {
+				starts = append(starts, start.Format("2006-01-02"))
+			}
+			sort.Strings(starts)
+
+			err = rebuildAggregates(ctx, goquDB, period, starts)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			fmt.Println("updated", len(starts), period, "aggregates")
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// rebuildAggregates recalculates the totals of the periods starting on the
+// dates from the stats of counted activities
+func rebuildAggregates(ctx context.Context, goquDB *goqu.Database, period string, starts []string) error {
+	periodStart := goqu.L("date_trunc(?, start_date_local)::date", period)
+
+	totals := goquDB.Select(
+		goqu.V(period),
+		periodStart,
+		goqu.C("type"),
+		goqu.C("gear_id"),
+		goqu.COUNT("*"),
+		goqu.SUM("distance"),
+		goqu.SUM("moving_time"),
+		goqu.SUM("elevation_gain"),
+		goqu.SUM("kilojoules"),
+		goqu.SUM("calories"),
+	).
+		From("activities.activity_stats").
+		Where(
+			periodStart.In(starts),
+			goqu.C("counted").IsTrue(),
+		).
+		GroupBy(periodStart, goqu.C("type"), goqu.C("gear_id"))
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	return tx.Wrap(func() error {
+		_, err := tx.Delete("activities.activity_aggregates").
+			Where(
+				goqu.C("period").Eq(period),
+				goqu.C("period_start").In(starts),
+			).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s aggregates: %v", period, err)
+		}
+
+		_, err = tx.Insert("activities.activity_aggregates").
+			Cols(
+				"period",
+				"period_start",
+				"type",
+				"gear_id",
+				"count",
+				"distance",
+				"moving_time",
+				"elevation_gain",
+				"kilojoules",
+				"calories",
+			).
+			FromQuery(totals).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert %s aggregates: %v", period, err)
+		}
+
+		return nil
+	})
+}
+
+func (a *ActivityAggregates) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (a *ActivityAggregates) Schedule() string {
+	if a.ScheduleOverride != "" {
+		return a.ScheduleOverride
+	}
+	return "0 50 * * * *"
+}
@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	strava "github.com/strava/go.strava"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/classify"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	internalStrava "github.com/charlieegan3/tool-activities/internal/pkg/strava"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// writeBackPeriod limits setting the commute flag on Strava to recent
+// activities, so enabling write back does not update the whole history
+const writeBackPeriod = 10 * 24 * time.Hour
+
+// ActivityClassification is a job that classifies activities as commutes,
+// indoor or virtual from their routes and originals. When write back is
+// enabled the commute flag is set on Strava for recent commutes, it is
+// never cleared.
+type ActivityClassification struct {
+	DB *sql.DB
+
+	Rules *classify.Rules
+
+	StravaClientID     string
+	StravaClientSecret string
+	StravaRefreshToken string
+
+	ScheduleOverride string
+}
+
+func (a *ActivityClassification) Name() string {
+	return "activity-classification"
+}
+
+func (a *ActivityClassification) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", a.DB)
+
+		inputDigest := goqu.L(
+			"CONCAT(r.data_digest, ':', COALESCE(o.original_digest, ''), ':', ?::TEXT)",
+			a.Rules.Digest(),
+		)
+
+		// select activities which have not been classified or whose route,
+		// original or the rules have changed since
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.type"),
+			goqu.I("a.start_date_local"),
+			goqu.I("r.geometry"),
+			goqu.COALESCE(goqu.I("o.sub_sport"), "").As("sub_sport"),
+			goqu.COALESCE(goqu.I("o.device_manufacturer"), "").As("device_manufacturer"),
+			inputDigest.As("input_digest"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			InnerJoin(
+				goqu.T("routes").Schema("activities").As("r"),
+				goqu.On(goqu.I("r.activity_id").Eq(goqu.I("a.id"))),
+			).
+			LeftJoin(
+				goqu.T("original_summaries").Schema("activities").As("o"),
+				goqu.On(goqu.I("o.activity_id").Eq(goqu.I("a.id"))),
+			).
+			LeftJoin(
+				goqu.T("activity_classifications").Schema("activities").As("c"),
+				goqu.On(goqu.I("c.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(goqu.Or(
+				goqu.I("c.input_digest").IsNull(),
+				goqu.I("c.input_digest").Neq(inputDigest),
+			)).
+			Order(goqu.I("a.timestamp").Asc())
+
+		var rows []struct {
+			ID             string     `db:"id"`
+			Type           string     `db:"type"`
+			StartDateLocal *time.Time `db:"start_date_local"`
+			Geometry       []byte     `db:"geometry"`
+			SubSport       string     `db:"sub_sport"`
+			Manufacturer   string     `db:"device_manufacturer"`
+			InputDigest    string     `db:"input_digest"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activities to classify: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			activity := classify.Activity{
+				Type:         row.Type,
+				SubSport:     row.SubSport,
+				Manufacturer: row.Manufacturer,
+			}
+			if row.StartDateLocal != nil {
+				activity.Start = *row.StartDateLocal
+			}
+			if row.Geometry != nil {
+				var points []geo.Point
+				points, err = queries.ParseLineString(row.Geometry)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to parse geometry for %s: %v", row.ID, err)
+					return
+				}
+				activity.Positions = points
+			}
+
+			class := a.Rules.Classify(activity)
+
+			record := goqu.Record{
+				"activity_id":  row.ID,
+				"input_digest": row.InputDigest,
+				"commute":      class.Commute,
+				"indoor":       class.Indoor,
+				"virtual":      class.Virtual,
+				"reason":       class.Reason,
+				"updated_at":   time.Now(),
+			}
+			_, err = goquDB.Insert("activities.activity_classifications").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to upsert classification of %s: %v", row.ID, err)
+				return
+			}
+
+			if class.Reason != "" {
+				fmt.Println(row.ID, class.Reason)
+			}
+		}
+
+		if a.Rules != nil && a.Rules.WriteBack {
+			err = a.writeBackCommutes(ctx, goquDB)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// writeBackCommutes sets the commute flag on Strava for recent commutes
+// where it has not been set. Activities which fail are logged and tried
+// again on the next run.
+func (a *ActivityClassification) writeBackCommutes(ctx context.Context, goquDB *goqu.Database) error {
+	var ids []string
+	err := goquDB.Select(goqu.I("c.activity_id")).
+		From(goqu.T("activity_classifications").Schema("activities").As("c")).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("c.activity_id"))),
+		).
+		Where(
+			goqu.I("c.commute").IsTrue(),
+			goqu.I("c.strava_commute_set").IsFalse(),
+			goqu.I("a.timestamp").Gt(time.Now().Add(-writeBackPeriod)),
+		).
+		Order(goqu.I("a.timestamp").Asc()).
+		ScanValsContext(ctx, &ids)
+	if err != nil {
+		return fmt.Errorf("failed to get commutes to write back: %v", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	accessToken, err := internalStrava.GetAccessToken(
+		a.StravaClientID,
+		a.StravaClientSecret,
+		a.StravaRefreshToken,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	stravaActivities := strava.NewActivitiesService(strava.NewClient(accessToken))
+
+	for _, id := range ids {
+		stravaID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			fmt.Println(id, "is not a strava activity ID, skipping write back")
+			continue
+		}
+
+		_, err = stravaActivities.Update(stravaID).Commute(true).Do()
+		if err != nil {
+			fmt.Println(id, "failed to set commute on strava:", err)
+			continue
+		}
+
+		_, err = goquDB.Update("activities.activity_classifications").
+			Where(goqu.C("activity_id").Eq(id)).
+			Set(goqu.Record{"strava_commute_set": true}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to record commute set for %s: %v", id, err)
+		}
+
+		fmt.Println(id, "set as commute on strava")
+	}
+
+	return nil
+}
+
+func (a *ActivityClassification) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (a *ActivityClassification) Schedule() string {
+	if a.ScheduleOverride != "" {
+		return a.ScheduleOverride
+	}
+	// after route geometry and original summaries have run
+	return "0 50 * * * *"
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,6 +17,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
 	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
 	"github.com/doug-martin/goqu/v9"
 	"google.golang.org/api/option"
@@ -142,7 +144,7 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 		}
 
 		goquDB := goqu.New("postgres", a.DB)
-		query := goquDB.Select("id", "original_digest").
+		query := goquDB.Select("id", "original_digest", "original_format").
 			From("activities.activities").
 			Where(
 				goqu.And(
@@ -158,6 +160,7 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 		var rows []struct {
 			ID             string `db:"id"`
 			OriginalDigest string `db:"original_digest"`
+			OriginalFormat string `db:"original_format"`
 		}
 
 		err = query.Executor().ScanStructs(&rows)
@@ -216,25 +219,27 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 				return
 			}
 
-			contentDisposition := res.Header.Get("Content-Disposition")
-			format := "unknown"
-			if strings.Contains(contentDisposition, `.fit"`) {
-				format = "fit"
-			} else if strings.Contains(contentDisposition, `.gpx"`) {
-				format = "gpx"
-			} else if strings.Contains(contentDisposition, `.tcx"`) {
-				format = "tcx"
-			}
-
 			body, err = io.ReadAll(res.Body)
 			if err != nil {
 				errCh <- err
 				return
 			}
 
+			// some originals are served compressed, these are stored
+			// uncompressed inside the archive's own gzip wrapper
+			if format.IsGzip(body) {
+				body, err = utils.Gunzip(body)
+				if err != nil {
+					errCh <- err
+					return
+				}
+			}
+
+			originalFormat := format.Detect(body)
+
 			var compressedBuf bytes.Buffer
 			zw := gzip.NewWriter(&compressedBuf)
-			zw.Name = fmt.Sprintf("%s.%s", row.ID, format)
+			zw.Name = fmt.Sprintf("%s.%s", row.ID, originalFormat)
 
 			_, err = zw.Write(body)
 			if err != nil {
@@ -249,7 +254,7 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 
 			// only update the bucket object if the original has changed
 			if digest != row.OriginalDigest {
-				obj := bucket.Object(fmt.Sprintf("activities/original/%s.%s.gz", row.ID, format))
+				obj := bucket.Object(utils.OriginalObjectName(row.ID, originalFormat))
 				w := obj.NewWriter(ctx)
 
 				_, err = io.Copy(w, bytes.NewReader(compressedBuf.Bytes()))
@@ -267,7 +272,7 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 					Where(goqu.C("id").Eq(row.ID)).
 					Set(goqu.Record{
 						"original_digest": digest,
-						"original_format": format,
+						"original_format": originalFormat,
 					})
 
 				_, err = query.Executor().Exec()
@@ -275,6 +280,16 @@ func (a *ActivityOriginal) Run(ctx context.Context) error {
 					errCh <- err
 					return
 				}
+
+				// the object for the previously detected format is only
+				// removed once the row points at the new one
+				if row.OriginalFormat != "" && row.OriginalFormat != originalFormat {
+					err = bucket.Object(utils.OriginalObjectName(row.ID, row.OriginalFormat)).Delete(ctx)
+					if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+						errCh <- fmt.Errorf("failed to delete old original %s: %w", row.ID, err)
+						return
+					}
+				}
 			}
 		}
 
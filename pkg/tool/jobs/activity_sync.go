@@ -166,19 +166,32 @@ func (a *ActivitySync) Run(ctx context.Context) error {
 				fmt.Println(row.ID, "object was updated")
 			}
 
+			record := goqu.Record{
+				"data_digest": digest,
+				"type":        activity.Type,
+				"gear_id":     activity.GearId,
+				"timestamp":   activity.StartDate,
+				"name":        activity.Name,
+				"description": activity.Description,
+			}
+			for k, v := range utils.LocalTimeFromData(activity) {
+				record[k] = v
+			}
+
 			query := goquDB.Update("activities.activities").
 				Where(goqu.C("id").Eq(fmt.Sprintf("%d", row.ID))).
-				Set(goqu.Record{
-					"data_digest": digest,
-					"type":        activity.Type,
-					"gear_id":     activity.GearId,
-					"timestamp":   activity.StartDate,
-				})
+				Set(record)
 			_, err = query.Executor().Exec()
 			if err != nil {
 				errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
 				return
 			}
+
+			err = a.syncDetails(ctx, goquDB, stravaActivities, activity, digest)
+			if err != nil {
+				errCh <- err
+				return
+			}
 		}
 
 		doneCh <- true
@@ -194,8 +207,53 @@ func (a *ActivitySync) Run(ctx context.Context) error {
 	}
 }
 
+// syncDetails stores the segment efforts, splits and best efforts of the
+// activity when its data has changed since they were last stored. Laps are
+// not part of the data and are requested once for each version of it,
+// failing to get them does not fail the sync.
+func (a *ActivitySync) syncDetails(ctx context.Context, goquDB *goqu.Database, stravaActivities *strava.ActivitiesService, activity *strava.ActivityDetailed, digest string) error {
+	id := fmt.Sprintf("%d", activity.Id)
+
+	var stored struct {
+		DataDigest string  `db:"data_digest"`
+		LapsDigest *string `db:"laps_digest"`
+	}
+	_, err := goquDB.Select("data_digest", "laps_digest").
+		From("activities.strava_details").
+		Where(goqu.C("activity_id").Eq(id)).
+		ScanStructContext(ctx, &stored)
+	if err != nil {
+		return fmt.Errorf("failed to get strava details of %s: %v", id, err)
+	}
+
+	if stored.DataDigest != digest {
+		err = utils.StoreStravaDetails(ctx, goquDB, id, digest, activity)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stored.LapsDigest == nil || *stored.LapsDigest != digest {
+		laps, err := stravaActivities.ListLaps(activity.Id).Do()
+		if err != nil {
+			// the laps digest is left unset so they are requested again on
+			// the next run
+			fmt.Println(id, "failed to get laps, skipping:", err)
+			return nil
+		}
+
+		err = utils.StoreStravaLaps(ctx, goquDB, id, digest, laps)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (a *ActivitySync) Timeout() time.Duration {
-	return 30 * time.Second
+	// laps are requested for each changed activity, which can be slow
+	return 10 * time.Minute
 }
 
 func (a *ActivitySync) Schedule() string {
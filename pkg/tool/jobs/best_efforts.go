@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/efforts"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// BestEfforts is a job that calculates the power curve of each activity
+// and the fastest times over standard distances for running activities
+type BestEfforts struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (b *BestEfforts) Name() string {
+	return "best-efforts"
+}
+
+func (b *BestEfforts) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", b.DB)
+
+		// select tracks which are new or have changed since the efforts
+		// were last calculated
+		query := goquDB.Select(
+			goqu.I("tr.activity_id"),
+			goqu.I("tr.digest"),
+			goqu.I("tr.sport"),
+		).
+			From(goqu.T("tracks").Schema("activities").As("tr")).
+			LeftJoin(
+				goqu.T("effort_activities").Schema("activities").As("e"),
+				goqu.On(goqu.I("e.activity_id").Eq(goqu.I("tr.activity_id"))),
+			).
+			Where(goqu.Or(
+				goqu.I("e.activity_id").IsNull(),
+				goqu.I("e.track_digest").Neq(goqu.I("tr.digest")),
+			)).
+			Order(goqu.I("tr.activity_id").Asc())
+
+		var rows []struct {
+			ID     string `db:"activity_id"`
+			Digest string `db:"digest"`
+			Sport  string `db:"sport"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			points, err := utils.LoadTrackPoints(ctx, goquDB, row.ID)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			var curveRows []goqu.Record
+			for duration, watts := range efforts.PowerCurve(points) {
+				curveRows = append(curveRows, goqu.Record{
+					"activity_id": row.ID,
+					"duration":    duration,
+					"watts":       watts,
+				})
+			}
+
+			var effortRows []goqu.Record
+			if row.Sport == "running" {
+				for _, e := range efforts.BestEfforts(points, efforts.RunningDistances) {
+					effortRows = append(effortRows, goqu.Record{
+						"activity_id": row.ID,
+						"distance":    e.Distance.Metres,
+						"name":        e.Distance.Name,
+						"start_time":  e.Start,
+						"seconds":     e.Seconds,
+					})
+				}
+			}
+
+			tx, err := goquDB.BeginTx(ctx, nil)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to begin transaction: %v", err)
+				return
+			}
+			err = tx.Wrap(func() error {
+				record := goqu.Record{
+					"activity_id":  row.ID,
+					"track_digest": row.Digest,
+					"updated_at":   time.Now(),
+				}
+				_, err := tx.Insert("activities.effort_activities").
+					Rows(record).
+					OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to upsert effort activity %s: %v", row.ID, err)
+				}
+
+				for table, tableRows := range map[string][]goqu.Record{
+					"activities.power_curves": curveRows,
+					"activities.best_efforts": effortRows,
+				} {
+					_, err = tx.Delete(table).
+						Where(goqu.C("activity_id").Eq(row.ID)).
+						Executor().ExecContext(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to delete from %s for %s: %v", table, row.ID, err)
+					}
+
+					if len(tableRows) == 0 {
+						continue
+					}
+
+					_, err = tx.Insert(table).
+						Rows(tableRows).
+						Executor().ExecContext(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to insert into %s for %s: %v", table, row.ID, err)
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (b *BestEfforts) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (b *BestEfforts) Schedule() string {
+	if b.ScheduleOverride != "" {
+		return b.ScheduleOverride
+	}
+	return "0 35 * * * *"
+}
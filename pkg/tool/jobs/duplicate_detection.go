@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/duplicates"
+)
+
+// DuplicateDetection is a job that finds groups of activities recording the
+// same workout, by their start time and elapsed time from the activity
+// stats and the similarity of their routes. Activities marked as not
+// duplicates are skipped. When a canonical activity has been chosen for a
+// group, activities which later join the group are marked as duplicates of
+// it.
+type DuplicateDetection struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (d *DuplicateDetection) Name() string {
+	return "duplicate-detection"
+}
+
+func (d *DuplicateDetection) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", d.DB)
+
+		var rows []struct {
+			ID          string    `db:"id"`
+			Timestamp   time.Time `db:"timestamp"`
+			ElapsedTime int64     `db:"elapsed_time"`
+			DuplicateOf *string   `db:"duplicate_of"`
+		}
+		err := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.timestamp"),
+			goqu.I("s.elapsed_time"),
+			goqu.I("a.duplicate_of"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			InnerJoin(
+				goqu.T("activity_stats").Schema("activities").As("s"),
+				goqu.On(goqu.I("s.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(
+				goqu.I("a.timestamp").IsNotNull(),
+				goqu.I("a.not_duplicate").IsFalse(),
+				goqu.I("s.elapsed_time").Gt(0),
+			).
+			ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activities: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		activities := make(map[string]duplicates.Activity, len(rows))
+		duplicateOf := make(map[string]string)
+		var all []duplicates.Activity
+		for _, r := range rows {
+			a := duplicates.Activity{
+				ID:       r.ID,
+				Start:    r.Timestamp,
+				Duration: time.Duration(r.ElapsedTime) * time.Second,
+			}
+			activities[r.ID] = a
+			all = append(all, a)
+
+			if r.DuplicateOf != nil {
+				duplicateOf[r.ID] = *r.DuplicateOf
+			}
+		}
+
+		// routes are only compared for activities which overlap in time
+		pairs := duplicates.OverlappingPairs(all)
+		for i, p := range pairs {
+			for _, id := range []string{p.A, p.B} {
+				a := activities[id]
+				if a.Cells != nil {
+					continue
+				}
+
+				a.Cells = []string{}
+				err := goquDB.Select("cell").
+					From("activities.route_cells").
+					Where(goqu.C("activity_id").Eq(id)).
+					ScanValsContext(ctx, &a.Cells)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to get route cells for %s: %v", id, err)
+					return
+				}
+				activities[id] = a
+			}
+
+			similarity, ok := duplicates.Similarity(activities[p.A], activities[p.B])
+			if ok {
+				pairs[i].Similarity = &similarity
+			}
+		}
+
+		groups := duplicates.Groups(pairs)
+
+		groupIDs := make(map[string]string)
+		var candidates []goqu.Record
+		for _, group := range groups {
+			for _, id := range group {
+				groupIDs[id] = group[0]
+				candidates = append(candidates, goqu.Record{
+					"activity_id": id,
+					"group_id":    group[0],
+					"updated_at":  time.Now(),
+				})
+			}
+		}
+
+		var pairRecords []goqu.Record
+		for _, p := range pairs {
+			if !p.Duplicate() {
+				continue
+			}
+			pairRecords = append(pairRecords, goqu.Record{
+				"activity_id":       p.A,
+				"other_activity_id": p.B,
+				"group_id":          groupIDs[p.A],
+				"overlap":           p.Overlap,
+				"similarity":        p.Similarity,
+			})
+		}
+
+		// new members of groups with a canonical activity are duplicates of it
+		marks := make(map[string]string)
+		for _, group := range groups {
+			canonical := ""
+			for _, id := range group {
+				if groupIDs[duplicateOf[id]] == group[0] {
+					canonical = duplicateOf[id]
+					break
+				}
+			}
+			if canonical == "" {
+				continue
+			}
+
+			for _, id := range group {
+				if id != canonical && duplicateOf[id] == "" {
+					marks[id] = canonical
+				}
+			}
+		}
+
+		tx, err := goquDB.BeginTx(ctx, nil)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to begin transaction: %v", err)
+			return
+		}
+		err = tx.Wrap(func() error {
+			_, err := tx.Delete("activities.duplicate_pairs").Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete duplicate pairs: %v", err)
+			}
+			_, err = tx.Delete("activities.duplicate_candidates").Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete duplicate candidates: %v", err)
+			}
+
+			if len(candidates) > 0 {
+				_, err = tx.Insert("activities.duplicate_candidates").
+					Rows(candidates).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert duplicate candidates: %v", err)
+				}
+			}
+
+			if len(pairRecords) > 0 {
+				_, err = tx.Insert("activities.duplicate_pairs").
+					Rows(pairRecords).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert duplicate pairs: %v", err)
+				}
+			}
+
+			for id, canonical := range marks {
+				_, err = tx.Update("activities.activities").
+					Where(goqu.C("id").Eq(id)).
+					Set(goqu.Record{"duplicate_of": canonical}).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to mark %s as a duplicate: %v", id, err)
+				}
+				fmt.Println(id, "is a duplicate of", canonical)
+			}
+
+			return nil
+		})
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		fmt.Println("found", len(groups), "duplicate groups")
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (d *DuplicateDetection) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (d *DuplicateDetection) Schedule() string {
+	if d.ScheduleOverride != "" {
+		return d.ScheduleOverride
+	}
+	return "0 55 * * * *"
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// eventBatchSize is the number of events delivered in each run
+const eventBatchSize = 100
+
+// EventNotifier is a job that delivers undelivered events, such as new
+// personal records, to a webhook as JSON. Events are left for the API when
+// no webhook is configured.
+type EventNotifier struct {
+	DB *sql.DB
+
+	WebhookURL string
+
+	ScheduleOverride string
+}
+
+func (e *EventNotifier) Name() string {
+	return "event-notifier"
+}
+
+func (e *EventNotifier) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		if e.WebhookURL == "" {
+			fmt.Println("no webhook configured")
+			doneCh <- true
+			return
+		}
+
+		goquDB := goqu.New("postgres", e.DB)
+		client := &http.Client{Timeout: 30 * time.Second}
+
+		events, err := queries.Events(ctx, e.DB, 0, eventBatchSize, true)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		fmt.Println("delivering", len(events))
+
+		for _, event := range events {
+			body, err := json.Marshal(event)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to marshal event %d: %v", event.ID, err)
+				return
+			}
+
+			req, err := http.NewRequestWithContext(ctx, "POST", e.WebhookURL, bytes.NewReader(body))
+			if err != nil {
+				errCh <- fmt.Errorf("failed to create webhook request: %v", err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to deliver event %d: %v", event.ID, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				errCh <- fmt.Errorf("webhook returned %d for event %d", resp.StatusCode, event.ID)
+				return
+			}
+
+			_, err = goquDB.Update("activities.events").
+				Set(goqu.Record{"delivered_at": time.Now()}).
+				Where(goqu.C("id").Eq(event.ID)).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to mark event %d delivered: %v", event.ID, err)
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (e *EventNotifier) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (e *EventNotifier) Schedule() string {
+	if e.ScheduleOverride != "" {
+		return e.ScheduleOverride
+	}
+	return "0 */5 * * * *"
+}
@@ -0,0 +1,260 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/explorer"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+)
+
+// ExplorerTiles is a job that finds the zoom 14 tiles visited by each
+// decoded track and records when each tile was first visited
+type ExplorerTiles struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (e *ExplorerTiles) Name() string {
+	return "explorer-tiles"
+}
+
+func (e *ExplorerTiles) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", e.DB)
+
+		// select tracks which are new or have changed since their tiles
+		// were found, or which have been marked or unmarked as a duplicate
+		query := goquDB.Select(
+			goqu.I("t.activity_id"),
+			goqu.I("t.digest"),
+			goqu.L("a.duplicate_of IS NULL").As("counted"),
+		).
+			From(goqu.T("tracks").Schema("activities").As("t")).
+			Join(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("t.activity_id"))),
+			).
+			LeftJoin(
+				goqu.T("explorer_activities").Schema("activities").As("e"),
+				goqu.On(goqu.I("e.activity_id").Eq(goqu.I("t.activity_id"))),
+			).
+			Where(goqu.Or(
+				goqu.I("e.track_digest").IsNull(),
+				goqu.I("e.track_digest").Neq(goqu.I("t.digest")),
+				goqu.L("e.counted <> (a.duplicate_of IS NULL)"),
+			)).
+			Order(goqu.I("t.activity_id").Asc())
+
+		var rows []struct {
+			ID      string `db:"activity_id"`
+			Digest  string `db:"digest"`
+			Counted bool   `db:"counted"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			var points []struct {
+				Lat float64 `db:"lat"`
+				Lon float64 `db:"lon"`
+			}
+			err := goquDB.Select("lat", "lon").
+				From("activities.track_points").
+				Where(
+					goqu.C("activity_id").Eq(row.ID),
+					goqu.C("lat").IsNotNull(),
+					goqu.C("lon").IsNotNull(),
+				).
+				Order(goqu.C("point_offset").Asc()).
+				Executor().ScanStructsContext(ctx, &points)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to get points for %s: %v", row.ID, err)
+				return
+			}
+
+			line := make([]geo.Point, len(points))
+			for i, p := range points {
+				line[i] = geo.Point{Lat: p.Lat, Lon: p.Lon}
+			}
+
+			err = storeExplorerTiles(ctx, goquDB, row.ID, row.Digest, row.Counted, tiles.Cover(line, explorer.Zoom))
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		// the rebuild is checked separately from the rows so a run which
+		// failed after storing tiles is rebuilt on the next
+		stale, err := explorerTilesStale(ctx, goquDB)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if stale {
+			err = rebuildExplorerTiles(ctx, goquDB)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// storeExplorerTiles replaces the tiles visited by an activity
+func storeExplorerTiles(ctx context.Context, goquDB *goqu.Database, id, digest string, counted bool, visited []tiles.Tile) error {
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	return tx.Wrap(func() error {
+		record := goqu.Record{
+			"activity_id":  id,
+			"track_digest": digest,
+			"counted":      counted,
+			"updated_at":   time.Now(),
+		}
+		_, err := tx.Insert("activities.explorer_activities").
+			Rows(record).
+			OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert explorer activity %s: %v", id, err)
+		}
+
+		_, err = tx.Delete("activities.explorer_activity_tiles").
+			Where(goqu.C("activity_id").Eq(id)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete explorer tiles for %s: %v", id, err)
+		}
+
+		if len(visited) == 0 {
+			return nil
+		}
+
+		var rows []goqu.Record
+		for _, t := range visited {
+			rows = append(rows, goqu.Record{"x": t.X, "y": t.Y, "activity_id": id})
+		}
+		_, err = tx.Insert("activities.explorer_activity_tiles").
+			Rows(rows).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert explorer tiles for %s: %v", id, err)
+		}
+
+		return nil
+	})
+}
+
+// explorerTilesStale returns true when the tiles of an activity have been
+// stored since explorer_tiles was last rebuilt, or it has never been
+func explorerTilesStale(ctx context.Context, goquDB *goqu.Database) (bool, error) {
+	var stored, rebuilt sql.NullTime
+	_, err := goquDB.Select(goqu.MAX("updated_at")).
+		From("activities.explorer_activities").
+		ScanValContext(ctx, &stored)
+	if err != nil {
+		return false, fmt.Errorf("failed to get last stored explorer activity: %v", err)
+	}
+	_, err = goquDB.Select(goqu.MAX("rebuilt_at")).
+		From("activities.explorer_tiles").
+		ScanValContext(ctx, &rebuilt)
+	if err != nil {
+		return false, fmt.Errorf("failed to get last explorer tiles rebuild: %v", err)
+	}
+
+	return stored.Valid && (!rebuilt.Valid || !stored.Time.Before(rebuilt.Time)), nil
+}
+
+// rebuildExplorerTiles replaces the first visit of each tile from the tiles
+// visited by each counted activity
+func rebuildExplorerTiles(ctx context.Context, goquDB *goqu.Database) error {
+	// taken before reading the activity tiles, so any stored during the
+	// rebuild are found by the next run
+	rebuiltAt := time.Now()
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	return tx.Wrap(func() error {
+		_, err := tx.Delete("activities.explorer_tiles").Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete explorer tiles: %v", err)
+		}
+
+		// the first activity for each tile is found with DISTINCT ON, and
+		// the number of visits with a window over the same tile
+		firstVisits := tx.Select(
+			goqu.I("t.x"),
+			goqu.I("t.y"),
+			goqu.I("a.timestamp"),
+			goqu.I("a.id"),
+			goqu.L(`COUNT(*) OVER (PARTITION BY "t"."x", "t"."y")`),
+			goqu.L("?::TIMESTAMPTZ", rebuiltAt),
+		).
+			Distinct(goqu.I("t.x"), goqu.I("t.y")).
+			From(goqu.T("explorer_activity_tiles").Schema("activities").As("t")).
+			Join(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("t.activity_id"))),
+			).
+			Join(
+				goqu.T("explorer_activities").Schema("activities").As("e"),
+				goqu.On(goqu.I("e.activity_id").Eq(goqu.I("t.activity_id"))),
+			).
+			Where(goqu.I("e.counted").IsTrue()).
+			Order(goqu.I("t.x").Asc(), goqu.I("t.y").Asc(), goqu.I("a.timestamp").Asc())
+
+		_, err = tx.Insert("activities.explorer_tiles").
+			Cols("x", "y", "first_visited_at", "first_activity_id", "visit_count", "rebuilt_at").
+			FromQuery(firstVisits).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert explorer tiles: %v", err)
+		}
+
+		return nil
+	})
+}
+
+func (e *ExplorerTiles) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (e *ExplorerTiles) Schedule() string {
+	if e.ScheduleOverride != "" {
+		return e.ScheduleOverride
+	}
+	return "0 25 * * * *"
+}
@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/gear"
+)
+
+// EventGearMaintenanceDue is the kind of event emitted when a component
+// becomes due for service
+const EventGearMaintenanceDue = "gear_maintenance_due"
+
+// GearMaintenance is a job that totals the use of each configured gear
+// component since it was installed, from the activity stats, and emits an
+// event when a component becomes due for service
+type GearMaintenance struct {
+	DB *sql.DB
+
+	Components []gear.Component
+
+	ScheduleOverride string
+}
+
+func (g *GearMaintenance) Name() string {
+	return "gear-maintenance"
+}
+
+func (g *GearMaintenance) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", g.DB)
+		now := time.Now()
+
+		var existing []struct {
+			GearID      string     `db:"gear_id"`
+			Name        string     `db:"name"`
+			InstalledOn time.Time  `db:"installed_on"`
+			NotifiedAt  *time.Time `db:"notified_at"`
+		}
+		err := goquDB.Select("gear_id", "name", "installed_on", "notified_at").
+			From("activities.gear_components").
+			ScanStructsContext(ctx, &existing)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get gear components: %v", err)
+			return
+		}
+
+		componentKey := func(gearID, name string, installed time.Time) string {
+			return fmt.Sprintf("%s/%s/%s", gearID, name, installed.Format("2006-01-02"))
+		}
+		notified := make(map[string]*time.Time)
+		for _, e := range existing {
+			notified[componentKey(e.GearID, e.Name, e.InstalledOn)] = e.NotifiedAt
+		}
+
+		fmt.Println("processing", len(g.Components))
+
+		configured := make(map[string]bool)
+		for _, c := range g.Components {
+			key := componentKey(c.GearID, c.Name, c.Installed)
+			configured[key] = true
+
+			usage, err := componentUsage(ctx, goquDB, c)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			reasons := c.Due(usage, now)
+
+			record := goqu.Record{
+				"gear_id":           c.GearID,
+				"name":              c.Name,
+				"installed_on":      c.Installed.Format("2006-01-02"),
+				"interval_distance": nil,
+				"interval_time":     nil,
+				"interval_days":     nil,
+				"activities":        usage.Activities,
+				"distance":          usage.Distance,
+				"moving_time":       usage.MovingTime,
+				"due":               len(reasons) > 0,
+				"due_reasons":       strings.Join(reasons, ", "),
+				"notified_at":       notified[key],
+				"updated_at":        now,
+			}
+			if c.IntervalDistance > 0 {
+				record["interval_distance"] = c.IntervalDistance
+			}
+			if c.IntervalTime > 0 {
+				record["interval_time"] = c.IntervalTime
+			}
+			if c.IntervalDays > 0 {
+				record["interval_days"] = c.IntervalDays
+			}
+
+			// an event is emitted once each time the component becomes due
+			var event goqu.Record
+			switch {
+			case len(reasons) == 0:
+				record["notified_at"] = nil
+			case notified[key] == nil:
+				record["notified_at"] = now
+
+				payload, err := json.Marshal(map[string]any{
+					"gear_id":     c.GearID,
+					"name":        c.Name,
+					"installed":   c.Installed.Format("2006-01-02"),
+					"reasons":     reasons,
+					"distance":    usage.Distance,
+					"moving_time": usage.MovingTime,
+				})
+				if err != nil {
+					errCh <- fmt.Errorf("failed to marshal event payload: %v", err)
+					return
+				}
+				event = goqu.Record{
+					"kind":    EventGearMaintenanceDue,
+					"payload": string(payload),
+				}
+			}
+
+			tx, err := goquDB.BeginTx(ctx, nil)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to begin transaction: %v", err)
+				return
+			}
+			err = tx.Wrap(func() error {
+				_, err := tx.Insert("activities.gear_components").
+					Rows(record).
+					OnConflict(goqu.DoUpdate(
+						"gear_id, name, installed_on",
+						excludedUpdates(record, "gear_id", "name", "installed_on"),
+					)).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to upsert gear component %s: %v", key, err)
+				}
+
+				if event == nil {
+					return nil
+				}
+
+				_, err = tx.Insert("activities.events").
+					Rows(event).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert event for %s: %v", key, err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			if len(reasons) > 0 {
+				fmt.Println(key, "is due:", strings.Join(reasons, ", "))
+			}
+		}
+
+		// components removed from the config are no longer tracked
+		for _, e := range existing {
+			if configured[componentKey(e.GearID, e.Name, e.InstalledOn)] {
+				continue
+			}
+
+			_, err = goquDB.Delete("activities.gear_components").
+				Where(
+					goqu.C("gear_id").Eq(e.GearID),
+					goqu.C("name").Eq(e.Name),
+					goqu.C("installed_on").Eq(e.InstalledOn.Format("2006-01-02")),
+				).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to delete gear component %s: %v", e.Name, err)
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// componentUsage totals the counted activities using the component's gear
+// from the local date it was installed
+func componentUsage(ctx context.Context, goquDB *goqu.Database, c gear.Component) (gear.Usage, error) {
+	var usage struct {
+		Activities int     `db:"activities"`
+		Distance   float64 `db:"distance"`
+		MovingTime float64 `db:"moving_time"`
+	}
+	_, err := goquDB.Select(
+		goqu.COUNT("*").As("activities"),
+		goqu.COALESCE(goqu.SUM("distance"), 0).As("distance"),
+		goqu.COALESCE(goqu.SUM("moving_time"), 0).As("moving_time"),
+	).
+		From("activities.activity_stats").
+		Where(
+			goqu.C("gear_id").Eq(c.GearID),
+			goqu.C("start_date_local").Gte(c.Installed.Format("2006-01-02")),
+			goqu.C("counted").IsTrue(),
+		).
+		ScanStructContext(ctx, &usage)
+	if err != nil {
+		return gear.Usage{}, fmt.Errorf("failed to total use of %s: %v", c.Name, err)
+	}
+
+	return gear.Usage{
+		Activities: usage.Activities,
+		Distance:   usage.Distance,
+		MovingTime: usage.MovingTime,
+	}, nil
+}
+
+func (g *GearMaintenance) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (g *GearMaintenance) Schedule() string {
+	if g.ScheduleOverride != "" {
+		return g.ScheduleOverride
+	}
+	return "0 0 7 * * *"
+}
@@ -0,0 +1,508 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/heatmap"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// heatmapTilesBatchSize is the number of tile index rows inserted in each
+// statement
+const heatmapTilesBatchSize = 1000
+
+// HeatmapTiles is a job that draws the decoded tracks into heatmap tiles
+// stored in the bucket. Only the tiles touched by activities which are new
+// or have changed since the last run are drawn again. The tiles to draw are
+// stored with the index so they are not lost when a run fails.
+type HeatmapTiles struct {
+	DB *sql.DB
+
+	Privacy *privacy.Policy
+
+	ScheduleOverride string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (h *HeatmapTiles) Name() string {
+	return "heatmap-tiles"
+}
+
+func (h *HeatmapTiles) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(h.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(h.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", h.DB)
+
+		err := h.index(ctx, goquDB)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		var dirty []struct {
+			Z     int    `db:"z"`
+			X     int    `db:"x"`
+			Y     int    `db:"y"`
+			Layer string `db:"layer"`
+		}
+		err = goquDB.Select("z", "x", "y", "layer").
+			From("activities.heatmap_dirty_tiles").
+			Executor().ScanStructsContext(ctx, &dirty)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get dirty tiles: %v", err)
+			return
+		}
+
+		render := make(map[tiles.Tile]map[string]bool)
+		for _, d := range dirty {
+			t := tiles.Tile{Z: d.Z, X: d.X, Y: d.Y}
+			if render[t] == nil {
+				render[t] = make(map[string]bool)
+			}
+			render[t][d.Layer] = true
+		}
+
+		fmt.Println("rendering", len(render), "tiles")
+
+		for t, layers := range render {
+			err := h.renderTile(ctx, goquDB, bucket, t, layers)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			var drawn []string
+			for layer := range layers {
+				drawn = append(drawn, layer)
+			}
+			_, err = goquDB.Delete("activities.heatmap_dirty_tiles").
+				Where(
+					goqu.C("z").Eq(t.Z),
+					goqu.C("x").Eq(t.X),
+					goqu.C("y").Eq(t.Y),
+					goqu.C("layer").In(drawn),
+				).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to clear dirty tile %s: %v", t, err)
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// index updates the tile index for tracks which are new or have changed.
+// The tiles at each zoom level which need to be drawn again are stored
+// along with the layers affected in the same transaction as the index.
+func (h *HeatmapTiles) index(ctx context.Context, goquDB *goqu.Database) error {
+	privacyDigest := h.Privacy.Digest()
+	year := goqu.L("EXTRACT(YEAR FROM a.timestamp)::INTEGER")
+
+	query := goquDB.Select(
+		goqu.I("t.activity_id"),
+		goqu.I("t.digest"),
+		goqu.I("a.type"),
+		year.As("year"),
+		goqu.COALESCE(goqu.I("h.type"), "").As("previous_type"),
+		goqu.COALESCE(goqu.I("h.year"), 0).As("previous_year"),
+	).
+		From(goqu.T("tracks").Schema("activities").As("t")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("t.activity_id"))),
+		).
+		LeftJoin(
+			goqu.T("heatmap_activities").Schema("activities").As("h"),
+			goqu.On(goqu.I("h.activity_id").Eq(goqu.I("t.activity_id"))),
+		).
+		Where(goqu.Or(
+			goqu.I("h.activity_id").IsNull(),
+			goqu.I("h.track_digest").Neq(goqu.I("t.digest")),
+			goqu.I("h.privacy_digest").Neq(privacyDigest),
+			goqu.I("h.type").Neq(goqu.I("a.type")),
+			goqu.I("h.year").Neq(year),
+		)).
+		Order(goqu.I("t.activity_id").Asc())
+
+	var rows []struct {
+		ID           string `db:"activity_id"`
+		Digest       string `db:"digest"`
+		Type         string `db:"type"`
+		Year         int    `db:"year"`
+		PreviousType string `db:"previous_type"`
+		PreviousYear int    `db:"previous_year"`
+	}
+	err := query.Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return fmt.Errorf("failed to get tracks: %v", err)
+	}
+
+	fmt.Println("indexing", len(rows))
+
+	for _, row := range rows {
+		var points []struct {
+			Offset int     `db:"point_offset"`
+			Lat    float64 `db:"lat"`
+			Lon    float64 `db:"lon"`
+		}
+		err := goquDB.Select("point_offset", "lat", "lon").
+			From("activities.track_points").
+			Where(
+				goqu.C("activity_id").Eq(row.ID),
+				goqu.C("lat").IsNotNull(),
+				goqu.C("lon").IsNotNull(),
+			).
+			Order(goqu.C("point_offset").Asc()).
+			Executor().ScanStructsContext(ctx, &points)
+		if err != nil {
+			return fmt.Errorf("failed to get points for %s: %v", row.ID, err)
+		}
+
+		line := make([]geo.Point, len(points))
+		for i, p := range points {
+			line[i] = geo.Point{Lat: p.Lat, Lon: p.Lon}
+		}
+
+		// the visible range is empty when the whole track is hidden
+		visibleFrom, visibleTo := 0, -1
+		var lines [][]geo.Point
+		var segment []geo.Point
+		for i, visible := range h.Privacy.Visible(line, row.ID) {
+			if !visible {
+				if len(segment) > 0 {
+					lines = append(lines, segment)
+				}
+				segment = nil
+				continue
+			}
+			if visibleTo == -1 {
+				visibleFrom = points[i].Offset
+			}
+			visibleTo = points[i].Offset
+			segment = append(segment, line[i])
+		}
+		if len(segment) > 0 {
+			lines = append(lines, segment)
+		}
+
+		seen := make(map[tiles.Tile]bool)
+		var covered []tiles.Tile
+		for _, l := range lines {
+			for _, t := range tiles.Cover(l, utils.HeatmapMaxZoom) {
+				if !seen[t] {
+					seen[t] = true
+					covered = append(covered, t)
+				}
+			}
+		}
+
+		var previous []struct {
+			X int `db:"x"`
+			Y int `db:"y"`
+		}
+		err = goquDB.Select("x", "y").
+			From("activities.heatmap_tiles").
+			Where(goqu.C("activity_id").Eq(row.ID)).
+			Executor().ScanStructsContext(ctx, &previous)
+		if err != nil {
+			return fmt.Errorf("failed to get previous tiles for %s: %v", row.ID, err)
+		}
+
+		// the tiles at each zoom level containing a tile the activity is
+		// now or was previously drawn in are drawn again, for the layers of
+		// its current and previous type
+		dirty := make(map[tiles.Tile]map[string]bool)
+		markDirty := func(t tiles.Tile, layers []string) {
+			for z := utils.HeatmapMinZoom; z <= utils.HeatmapMaxZoom; z++ {
+				parent := t.Parent(z)
+				if dirty[parent] == nil {
+					dirty[parent] = make(map[string]bool)
+				}
+				for _, layer := range layers {
+					dirty[parent][layer] = true
+				}
+			}
+		}
+		for _, t := range covered {
+			markDirty(t, utils.HeatmapLayers(row.Type, row.Year))
+		}
+		previousLayers := utils.HeatmapLayers(row.Type, row.Year)
+		if row.PreviousType != "" {
+			previousLayers = append(previousLayers, utils.HeatmapLayers(row.PreviousType, row.PreviousYear)...)
+		}
+		for _, p := range previous {
+			markDirty(tiles.Tile{Z: utils.HeatmapMaxZoom, X: p.X, Y: p.Y}, previousLayers)
+		}
+
+		var dirtyRows []goqu.Record
+		for t, layers := range dirty {
+			for layer := range layers {
+				dirtyRows = append(dirtyRows, goqu.Record{"z": t.Z, "x": t.X, "y": t.Y, "layer": layer})
+			}
+		}
+
+		tx, err := goquDB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		err = tx.Wrap(func() error {
+			record := goqu.Record{
+				"activity_id":    row.ID,
+				"track_digest":   row.Digest,
+				"privacy_digest": privacyDigest,
+				"type":           row.Type,
+				"year":           row.Year,
+				"visible_from":   visibleFrom,
+				"visible_to":     visibleTo,
+				"updated_at":     time.Now(),
+			}
+			_, err := tx.Insert("activities.heatmap_activities").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to upsert heatmap activity %s: %v", row.ID, err)
+			}
+
+			_, err = tx.Delete("activities.heatmap_tiles").
+				Where(goqu.C("activity_id").Eq(row.ID)).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete tiles for %s: %v", row.ID, err)
+			}
+
+			var tileRows []goqu.Record
+			for i, t := range covered {
+				tileRows = append(tileRows, goqu.Record{"x": t.X, "y": t.Y, "activity_id": row.ID})
+
+				if len(tileRows) == heatmapTilesBatchSize || i == len(covered)-1 {
+					_, err = tx.Insert("activities.heatmap_tiles").
+						Rows(tileRows).
+						Executor().ExecContext(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to insert tiles for %s: %v", row.ID, err)
+					}
+					tileRows = nil
+				}
+			}
+
+			for i := 0; i < len(dirtyRows); i += heatmapTilesBatchSize {
+				batch := dirtyRows[i:]
+				if len(batch) > heatmapTilesBatchSize {
+					batch = batch[:heatmapTilesBatchSize]
+				}
+				_, err = tx.Insert("activities.heatmap_dirty_tiles").
+					Rows(batch).
+					OnConflict(goqu.DoNothing()).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert dirty tiles for %s: %v", row.ID, err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderTile draws the layers of a tile from all the activities passing
+// through it and stores them in the bucket, empty tiles are removed
+func (h *HeatmapTiles) renderTile(
+	ctx context.Context,
+	goquDB *goqu.Database,
+	bucket *storage.BucketHandle,
+	t tiles.Tile,
+	layers map[string]bool,
+) error {
+	minTile, maxTile := t.Children(utils.HeatmapMaxZoom)
+
+	activityIDs := goquDB.From("activities.heatmap_tiles").
+		Select("activity_id").
+		Distinct().
+		Where(
+			goqu.C("x").Between(goqu.Range(minTile.X, maxTile.X)),
+			goqu.C("y").Between(goqu.Range(minTile.Y, maxTile.Y)),
+		)
+
+	// points just outside the tile are loaded so lines crossing the edge
+	// are drawn
+	bounds := t.Bounds()
+	padLat := (bounds.MaxLat - bounds.MinLat) / 10
+	padLon := (bounds.MaxLon - bounds.MinLon) / 10
+
+	// at lower zoom levels a pixel covers many points, so only every nth
+	// point is drawn
+	step := 1 << uint(utils.HeatmapMaxZoom-t.Z)
+
+	query := goquDB.Select(
+		goqu.I("p.activity_id"),
+		goqu.I("p.point_offset"),
+		goqu.I("p.lat"),
+		goqu.I("p.lon"),
+		goqu.I("h.type"),
+		goqu.I("h.year"),
+	).
+		From(goqu.T("track_points").Schema("activities").As("p")).
+		Join(
+			goqu.T("heatmap_activities").Schema("activities").As("h"),
+			goqu.On(goqu.I("h.activity_id").Eq(goqu.I("p.activity_id"))),
+		).
+		Where(
+			goqu.I("p.activity_id").In(activityIDs),
+			goqu.I("p.point_offset").Between(goqu.Range(goqu.I("h.visible_from"), goqu.I("h.visible_to"))),
+			goqu.I("p.lat").Between(goqu.Range(bounds.MinLat-padLat, bounds.MaxLat+padLat)),
+			goqu.I("p.lon").Between(goqu.Range(bounds.MinLon-padLon, bounds.MaxLon+padLon)),
+			goqu.L("? % ? = 0", goqu.I("p.point_offset"), step),
+		).
+		Order(goqu.I("p.activity_id").Asc(), goqu.I("p.point_offset").Asc())
+
+	var points []struct {
+		ActivityID string  `db:"activity_id"`
+		Offset     int     `db:"point_offset"`
+		Lat        float64 `db:"lat"`
+		Lon        float64 `db:"lon"`
+		Type       string  `db:"type"`
+		Year       int     `db:"year"`
+	}
+	err := query.Executor().ScanStructsContext(ctx, &points)
+	if err != nil {
+		return fmt.Errorf("failed to get points for tile %s: %v", t, err)
+	}
+
+	canvases := make(map[string]*heatmap.Canvas)
+	for layer := range layers {
+		canvases[layer] = heatmap.NewCanvas(t)
+	}
+
+	var lines [][]geo.Point
+	var segment []geo.Point
+	flush := func(activityType string, year int) {
+		if len(segment) > 0 {
+			lines = append(lines, segment)
+		}
+		segment = nil
+		if len(lines) == 0 {
+			return
+		}
+		for _, layer := range utils.HeatmapLayers(activityType, year) {
+			if canvas, ok := canvases[layer]; ok {
+				canvas.AddActivity(lines)
+			}
+		}
+		lines = nil
+	}
+
+	for i, p := range points {
+		point := geo.Point{Lat: p.Lat, Lon: p.Lon}
+
+		// lines are split where points are missing, either outside the
+		// padded tile or inside a privacy zone
+		if i > 0 {
+			previous := points[i-1]
+			if previous.ActivityID != p.ActivityID {
+				flush(previous.Type, previous.Year)
+			} else if p.Offset-previous.Offset != step && len(segment) > 0 {
+				lines = append(lines, segment)
+				segment = nil
+			}
+		}
+
+		if h.Privacy.InZone(point) {
+			if len(segment) > 0 {
+				lines = append(lines, segment)
+			}
+			segment = nil
+			continue
+		}
+		segment = append(segment, point)
+	}
+	if len(points) > 0 {
+		last := points[len(points)-1]
+		flush(last.Type, last.Year)
+	}
+
+	for layer, canvas := range canvases {
+		obj := bucket.Object(utils.HeatmapObjectName(layer, t))
+
+		if canvas.Empty() {
+			err := obj.Delete(ctx)
+			if err != nil && err != storage.ErrObjectNotExist {
+				return fmt.Errorf("failed to delete tile %s %s: %v", layer, t, err)
+			}
+			continue
+		}
+
+		data, err := canvas.PNG()
+		if err != nil {
+			return err
+		}
+
+		w := obj.NewWriter(ctx)
+		w.ContentType = "image/png"
+
+		_, err = io.Copy(w, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to write tile to google storage: %w", err)
+		}
+		err = w.Close()
+		if err != nil {
+			return fmt.Errorf("failed to close google storage writer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *HeatmapTiles) Timeout() time.Duration {
+	return 30 * time.Minute
+}
+
+func (h *HeatmapTiles) Schedule() string {
+	if h.ScheduleOverride != "" {
+		return h.ScheduleOverride
+	}
+	return "0 20 * * * *"
+}
@@ -0,0 +1,120 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// AreaSearch is a job that lists the activities which passed through an
+// area. It expects either "near <lat> <lon> <radius>" or
+// "bbox <min lat> <min lon> <max lat> <max lon>" as arguments.
+type AreaSearch struct {
+	DB *sql.DB
+}
+
+func (a *AreaSearch) Name() string {
+	return "area-search"
+}
+
+func (a *AreaSearch) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		if len(os.Args) < 3 {
+			errCh <- fmt.Errorf("expected near or bbox")
+			return
+		}
+
+		var matches []queries.AreaMatch
+
+		switch os.Args[2] {
+		case "near":
+			values, err := parseFloatArgs(os.Args[3:], 3)
+			if err != nil {
+				errCh <- fmt.Errorf("expected a lat, lon and radius: %v", err)
+				return
+			}
+
+			matches, err = queries.ActivitiesNear(
+				ctx,
+				a.DB,
+				geo.Point{Lat: values[0], Lon: values[1]},
+				values[2],
+				nil,
+			)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		case "bbox":
+			values, err := parseFloatArgs(os.Args[3:], 4)
+			if err != nil {
+				errCh <- fmt.Errorf("expected a min lat, min lon, max lat and max lon: %v", err)
+				return
+			}
+
+			matches, err = queries.ActivitiesInBBox(ctx, a.DB, geo.BBox{
+				MinLat: values[0],
+				MinLon: values[1],
+				MaxLat: values[2],
+				MaxLon: values[3],
+			}, nil)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		default:
+			errCh <- fmt.Errorf("unknown search: %s", os.Args[2])
+			return
+		}
+
+		for _, m := range matches {
+			fmt.Printf("%s\t%s\t%s\t%.0fm\n", m.ID, m.Timestamp.Format(time.RFC3339), m.Type, m.Distance)
+		}
+		fmt.Println("found", len(matches))
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func parseFloatArgs(args []string, count int) ([]float64, error) {
+	if len(args) < count {
+		return nil, fmt.Errorf("got %d arguments", len(args))
+	}
+
+	values := make([]float64, count)
+	for i := range values {
+		value, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", args[i], err)
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+func (a *AreaSearch) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (a *AreaSearch) Schedule() string {
+	return ""
+}
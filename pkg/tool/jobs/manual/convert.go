@@ -0,0 +1,92 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// Convert is a job that converts an activity's original to another format
+// and writes it to a local file. It expects the activity ID, the target
+// format and optionally an output path as arguments. The file stays local
+// so privacy zones are not applied.
+type Convert struct {
+	DB *sql.DB
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (c *Convert) Name() string {
+	return "convert"
+}
+
+func (c *Convert) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(c.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(c.GoogleBucketName)
+
+	go func() {
+		if len(os.Args) < 4 {
+			errCh <- fmt.Errorf("expected an activity ID and a target format")
+			return
+		}
+		id := os.Args[2]
+		targetFormat := os.Args[3]
+
+		outputPath := fmt.Sprintf("%s.%s", id, targetFormat)
+		if len(os.Args) > 4 {
+			outputPath = os.Args[4]
+		}
+
+		data, err := utils.ConvertOriginal(ctx, c.DB, bucket, nil, id, targetFormat)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		err = os.WriteFile(outputPath, data, 0644)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to write %s: %v", outputPath, err)
+			return
+		}
+
+		fmt.Println("written", outputPath)
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (c *Convert) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (c *Convert) Schedule() string {
+	return ""
+}
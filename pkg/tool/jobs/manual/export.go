@@ -9,12 +9,12 @@ import (
 	_ "embed"
 	"encoding/csv"
 	"fmt"
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
 	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
 	"google.golang.org/api/option"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
@@ -119,21 +119,13 @@ func (f *FromExport) Run(ctx context.Context) error {
 				return
 			}
 
-			var rawBytes []byte
-			if strings.HasSuffix(file.Name(), ".gz") {
-				zr, err := gzip.NewReader(file)
-				if err != nil {
-					errCh <- err
-					return
-				}
-
-				rawBytes, err = io.ReadAll(zr)
-				if err != nil {
-					errCh <- err
-					return
-				}
-			} else {
-				rawBytes, err = io.ReadAll(file)
+			rawBytes, err := io.ReadAll(file)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if format.IsGzip(rawBytes) {
+				rawBytes, err = utils.Gunzip(rawBytes)
 				if err != nil {
 					errCh <- err
 					return
@@ -154,18 +146,11 @@ func (f *FromExport) Run(ctx context.Context) error {
 				return
 			}
 
-			format := "unknown"
-			fmt.Println(file.Name())
-			if strings.Contains(file.Name(), ".fit") {
-				format = "fit"
-			} else if strings.Contains(file.Name(), ".gpx") {
-				format = "gpx"
-			} else if strings.Contains(file.Name(), ".tcx") {
-				format = "tcx"
-			}
+			originalFormat := format.Detect(rawBytes)
+			fmt.Println(file.Name(), originalFormat)
 			digest := utils.CRC32Hash(compressedBuf.Bytes())
 
-			obj := bucket.Object(fmt.Sprintf("activities/original/%s.%s.gz", id, format))
+			obj := bucket.Object(utils.OriginalObjectName(id, originalFormat))
 			w := obj.NewWriter(ctx)
 			_, err = io.Copy(w, bytes.NewReader(compressedBuf.Bytes()))
 			if err != nil {
@@ -178,7 +163,7 @@ func (f *FromExport) Run(ctx context.Context) error {
 				Where(goqu.C("id").Eq(id)).
 				Set(goqu.Record{
 					"original_digest": digest,
-					"original_format": format,
+					"original_format": originalFormat,
 				})
 
 			_, err = query.Executor().Exec()
@@ -187,6 +172,23 @@ func (f *FromExport) Run(ctx context.Context) error {
 				return
 			}
 
+			// the local time from Strava is kept when the activity has
+			// already been synced as it also has the time zone name
+			if localTime, ok := utils.LocalTimeFromOriginal(rawBytes, originalFormat); ok {
+				query := goquDB.Update("activities.activities").
+					Where(
+						goqu.C("id").Eq(id),
+						goqu.C("start_date_local").IsNull(),
+					).
+					Set(localTime)
+
+				_, err = query.Executor().Exec()
+				if err != nil {
+					errCh <- err
+					return
+				}
+			}
+
 			fmt.Println(id)
 		}
 
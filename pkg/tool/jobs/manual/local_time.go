@@ -0,0 +1,130 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// LocalTimeBackfill is a job that sets the local start time of activities
+// synced or imported before it was stored. The archived Strava data is
+// used when there is some, otherwise the time is derived from a FIT
+// original, so no requests are made to Strava.
+type LocalTimeBackfill struct {
+	DB *sql.DB
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (l *LocalTimeBackfill) Name() string {
+	return "local-time-backfill"
+}
+
+func (l *LocalTimeBackfill) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(l.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	goquDB := goqu.New("postgres", l.DB)
+	bucket := storageClient.Bucket(l.GoogleBucketName)
+
+	go func() {
+		query := goquDB.Select("id", "data_digest", "original_format").
+			From("activities.activities").
+			Where(goqu.C("start_date_local").IsNull()).
+			Order(goqu.C("id").Asc())
+
+		var rows []struct {
+			ID             string `db:"id"`
+			DataDigest     string `db:"data_digest"`
+			OriginalFormat string `db:"original_format"`
+		}
+
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			var localTime goqu.Record
+
+			if row.DataDigest != "" {
+				activity, err := utils.ReadActivityData(ctx, bucket, row.ID)
+				if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+					errCh <- err
+					return
+				}
+				if err == nil {
+					localTime = utils.LocalTimeFromData(activity)
+				}
+			}
+
+			if localTime == nil && row.OriginalFormat == format.FIT {
+				data, err := utils.ReadOriginal(ctx, bucket, row.ID, row.OriginalFormat)
+				if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+					errCh <- err
+					return
+				}
+				if err == nil {
+					localTime, _ = utils.LocalTimeFromOriginal(data, row.OriginalFormat)
+				}
+			}
+
+			if localTime == nil {
+				fmt.Println(row.ID, "no local time found")
+				continue
+			}
+
+			_, err = goquDB.Update("activities.activities").
+				Where(goqu.C("id").Eq(row.ID)).
+				Set(localTime).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to update activity %s: %w", row.ID, err)
+				return
+			}
+
+			fmt.Println(row.ID, localTime["start_date_local"])
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (l *LocalTimeBackfill) Timeout() time.Duration {
+	return 30 * time.Minute
+}
+
+func (l *LocalTimeBackfill) Schedule() string {
+	return ""
+}
@@ -0,0 +1,161 @@
+package manual
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// OriginalFormatBackfill is a job that re-classifies originals stored with
+// an unknown format. Each original is inspected by content and, when the
+// format can be determined, the object is rewritten under the correct name
+// and the activity row is updated.
+type OriginalFormatBackfill struct {
+	DB *sql.DB
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (o *OriginalFormatBackfill) Name() string {
+	return "original-format-backfill"
+}
+
+func (o *OriginalFormatBackfill) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(o.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	goquDB := goqu.New("postgres", o.DB)
+	bucket := storageClient.Bucket(o.GoogleBucketName)
+
+	go func() {
+		query := goquDB.Select("id").
+			From("activities.activities").
+			Where(goqu.C("original_format").Eq(format.Unknown)).
+			Order(goqu.C("id").Asc())
+
+		var rows []struct {
+			ID string `db:"id"`
+		}
+
+		err := query.Executor().ScanStructs(&rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			oldObj := bucket.Object(utils.OriginalObjectName(row.ID, format.Unknown))
+
+			r, err := oldObj.NewReader(ctx)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(row.ID, "original not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- fmt.Errorf("failed to create reader to read from google storage: %w", err)
+				return
+			}
+
+			compressedBytes, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				errCh <- fmt.Errorf("failed to read from google storage: %w", err)
+				return
+			}
+
+			rawBytes, err := utils.Gunzip(compressedBytes)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decompress original %s: %w", row.ID, err)
+				return
+			}
+
+			originalFormat := format.Detect(rawBytes)
+			if originalFormat == format.Unknown {
+				fmt.Println(row.ID, "format still unknown")
+				continue
+			}
+
+			compressedBytes, err = utils.Gzip(fmt.Sprintf("%s.%s", row.ID, originalFormat), rawBytes)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			obj := bucket.Object(utils.OriginalObjectName(row.ID, originalFormat))
+			w := obj.NewWriter(ctx)
+			_, err = io.Copy(w, bytes.NewReader(compressedBytes))
+			if err != nil {
+				errCh <- fmt.Errorf("failed to write to google storage: %w", err)
+				return
+			}
+			err = w.Close()
+			if err != nil {
+				errCh <- fmt.Errorf("failed to close google storage writer: %w", err)
+				return
+			}
+
+			query := goquDB.Update("activities.activities").
+				Where(goqu.C("id").Eq(row.ID)).
+				Set(goqu.Record{
+					"original_digest": utils.CRC32Hash(compressedBytes),
+					"original_format": originalFormat,
+				})
+			_, err = query.Executor().Exec()
+			if err != nil {
+				errCh <- fmt.Errorf("failed to update activity %s: %w", row.ID, err)
+				return
+			}
+
+			// the old object is only removed once the row points at the new one
+			err = oldObj.Delete(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to delete old original %s: %w", row.ID, err)
+				return
+			}
+
+			fmt.Println(row.ID, originalFormat)
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (o *OriginalFormatBackfill) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (o *OriginalFormatBackfill) Schedule() string {
+	return "0 0 6 * * *"
+}
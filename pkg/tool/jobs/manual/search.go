@@ -0,0 +1,77 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// ActivitySearch is a job that lists the activities matching a full text
+// query over their names, descriptions, tags and notes. The arguments are
+// joined to form the query, such as: puncture wales -commute
+type ActivitySearch struct {
+	DB *sql.DB
+}
+
+func (a *ActivitySearch) Name() string {
+	return "activity-search"
+}
+
+func (a *ActivitySearch) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		query := strings.TrimSpace(strings.Join(os.Args[2:], " "))
+		if query == "" {
+			errCh <- fmt.Errorf("expected a search query")
+			return
+		}
+
+		activities, err := queries.ListActivities(ctx, a.DB, queries.ActivityFilter{
+			Query: query,
+			From:  time.Unix(0, 0),
+			To:    time.Now().AddDate(0, 0, 1),
+			Limit: 50,
+		})
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		for _, activity := range activities {
+			fmt.Printf(
+				"%s\t%s\t%s\t%s\t%s\n",
+				activity.ID,
+				activity.Timestamp.Format(time.RFC3339),
+				activity.Type,
+				activity.Name,
+				strings.Join(activity.Tags, ","),
+			)
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (a *ActivitySearch) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (a *ActivitySearch) Schedule() string {
+	return ""
+}
@@ -0,0 +1,110 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// SearchBackfill is a job that sets the name and description of activities
+// synced before they were stored, from the archived Strava data, so they
+// can be found with full text search
+type SearchBackfill struct {
+	DB *sql.DB
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (s *SearchBackfill) Name() string {
+	return "search-backfill"
+}
+
+func (s *SearchBackfill) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(s.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	goquDB := goqu.New("postgres", s.DB)
+	bucket := storageClient.Bucket(s.GoogleBucketName)
+
+	go func() {
+		query := goquDB.Select("id").
+			From("activities.activities").
+			Where(
+				goqu.C("data_digest").Neq(""),
+				goqu.C("name").Eq(""),
+			).
+			Order(goqu.C("id").Asc())
+
+		var ids []string
+		err := query.Executor().ScanValsContext(ctx, &ids)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(ids))
+
+		for _, id := range ids {
+			activity, err := utils.ReadActivityData(ctx, bucket, id)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(id, "data not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			_, err = goquDB.Update("activities.activities").
+				Where(goqu.C("id").Eq(id)).
+				Set(goqu.Record{
+					"name":        activity.Name,
+					"description": activity.Description,
+				}).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to update activity %s: %w", id, err)
+				return
+			}
+
+			fmt.Println(id, activity.Name)
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (s *SearchBackfill) Timeout() time.Duration {
+	return 30 * time.Minute
+}
+
+func (s *SearchBackfill) Schedule() string {
+	return ""
+}
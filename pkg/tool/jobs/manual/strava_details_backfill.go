@@ -0,0 +1,118 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// StravaDetailsBackfill is a job that stores the segment efforts, splits
+// and best efforts of activities from their archived Strava data, for
+// activities synced before these were stored or whose data has changed
+// since. Laps are not in the archived data and are left to ActivitySync.
+type StravaDetailsBackfill struct {
+	DB *sql.DB
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (s *StravaDetailsBackfill) Name() string {
+	return "strava-details-backfill"
+}
+
+func (s *StravaDetailsBackfill) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(s.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	goquDB := goqu.New("postgres", s.DB)
+	bucket := storageClient.Bucket(s.GoogleBucketName)
+
+	go func() {
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.data_digest"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			LeftJoin(
+				goqu.T("strava_details").Schema("activities").As("d"),
+				goqu.On(goqu.I("d.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(
+				goqu.I("a.data_digest").Neq(""),
+				goqu.Or(
+					goqu.I("d.data_digest").IsNull(),
+					goqu.I("d.data_digest").Neq(goqu.I("a.data_digest")),
+				),
+			).
+			Order(goqu.I("a.id").Asc())
+
+		var rows []struct {
+			ID         string `db:"id"`
+			DataDigest string `db:"data_digest"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			activity, err := utils.ReadActivityData(ctx, bucket, row.ID)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(row.ID, "data not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			err = utils.StoreStravaDetails(ctx, goquDB, row.ID, row.DataDigest, activity)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			fmt.Println(row.ID, len(activity.SegmentEfforts), "segment efforts")
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (s *StravaDetailsBackfill) Timeout() time.Duration {
+	return 30 * time.Minute
+}
+
+func (s *StravaDetailsBackfill) Schedule() string {
+	return ""
+}
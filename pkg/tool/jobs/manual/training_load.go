@@ -0,0 +1,78 @@
+package manual
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// TrainingLoadReport is a job that prints the daily fitness, fatigue and
+// form. It accepts optional from and to dates as arguments and defaults to
+// the last six weeks.
+type TrainingLoadReport struct {
+	DB *sql.DB
+}
+
+func (t *TrainingLoadReport) Name() string {
+	return "training-load-report"
+}
+
+func (t *TrainingLoadReport) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		to := time.Now().AddDate(0, 0, 1)
+		from := to.AddDate(0, 0, -42)
+
+		var err error
+		if len(os.Args) > 2 {
+			from, err = time.Parse("2006-01-02", os.Args[2])
+			if err != nil {
+				errCh <- fmt.Errorf("from must be a date such as 2023-01-31")
+				return
+			}
+		}
+		if len(os.Args) > 3 {
+			to, err = time.Parse("2006-01-02", os.Args[3])
+			if err != nil {
+				errCh <- fmt.Errorf("to must be a date such as 2023-01-31")
+				return
+			}
+		}
+
+		days, err := queries.TrainingLoadDays(ctx, t.DB, from, to)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		fmt.Println("date\tstress\tfitness\tfatigue\tform")
+		for _, d := range days {
+			fmt.Printf("%s\t%.0f\t%.1f\t%.1f\t%.1f\n", d.Date.Format("2006-01-02"), d.Stress, d.Fitness, d.Fatigue, d.Form)
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (t *TrainingLoadReport) Timeout() time.Duration {
+	return time.Minute
+}
+
+func (t *TrainingLoadReport) Schedule() string {
+	return ""
+}
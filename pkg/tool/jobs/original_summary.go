@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/fit"
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// OriginalSummary is a job that decodes archived FIT originals and stores
+// the summary recorded by the device, rather than Strava's interpretation
+// of the activity
+type OriginalSummary struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (o *OriginalSummary) Name() string {
+	return "original-summary"
+}
+
+func (o *OriginalSummary) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(o.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(o.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", o.DB)
+
+		// select FIT originals which have no summary or where the original
+		// has changed since the summary was generated
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.original_digest"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			LeftJoin(
+				goqu.T("original_summaries").Schema("activities").As("s"),
+				goqu.On(goqu.I("s.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(
+				goqu.I("a.original_format").Eq(format.FIT),
+				goqu.Or(
+					goqu.I("s.original_digest").IsNull(),
+					goqu.I("s.original_digest").Neq(goqu.I("a.original_digest")),
+				),
+			).
+			Order(goqu.I("a.id").Asc())
+
+		var rows []struct {
+			ID             string `db:"id"`
+			OriginalDigest string `db:"original_digest"`
+		}
+
+		err := query.Executor().ScanStructs(&rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			data, err := utils.ReadOriginal(ctx, bucket, row.ID, format.FIT)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(row.ID, "original not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			// a broken original should not block the remaining activities,
+			// the error is stored so it's not decoded again until it changes
+			var record goqu.Record
+			fitFile, err := fit.Decode(data)
+			if err != nil {
+				fmt.Println(row.ID, "failed to decode:", err)
+				record = emptySummaryRecord(err)
+			} else {
+				record = summaryRecord(fitFile)
+				record["decode_error"] = ""
+			}
+			record["activity_id"] = row.ID
+			record["original_digest"] = row.OriginalDigest
+			record["updated_at"] = time.Now()
+
+			query := goquDB.Insert("activities.original_summaries").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id")))
+			_, err = query.Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to upsert summary for %s: %v", row.ID, err)
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// summaryRecord totals the sessions in a FIT file and describes the device
+// which recorded it
+func summaryRecord(f *fit.File) goqu.Record {
+	var sport, subSport string
+	var timerTime, elapsedTime, distance float64
+	var ascent, descent, calories int
+
+	for i, s := range f.Sessions {
+		if i == 0 {
+			sport = fit.SportName(s.Sport)
+			subSport = fit.SubSportName(s.SubSport)
+		} else if fit.SportName(s.Sport) != sport {
+			sport = fit.SportName(fit.SportMultisport)
+			subSport = fit.SubSportName(fit.SubSportGeneric)
+		}
+
+		timerTime += s.TotalTimerTime
+		elapsedTime += s.TotalElapsedTime
+		distance += s.TotalDistance
+		ascent += int(s.TotalAscent)
+		descent += int(s.TotalDescent)
+		calories += int(s.TotalCalories)
+	}
+
+	manufacturer := fit.ManufacturerName(f.FileID.Manufacturer)
+	product := f.FileID.ProductName
+	if product == "" {
+		product = strconv.Itoa(int(f.FileID.Product))
+	}
+	var firmware string
+
+	if creator := f.Creator(); creator != nil {
+		if creator.Manufacturer != 0 {
+			manufacturer = fit.ManufacturerName(creator.Manufacturer)
+		}
+		if creator.ProductName != "" {
+			product = creator.ProductName
+		} else if creator.Product != 0 {
+			product = strconv.Itoa(int(creator.Product))
+		}
+		if creator.SoftwareVersion != 0 {
+			firmware = strconv.FormatFloat(creator.SoftwareVersion, 'f', 2, 64)
+		}
+	}
+
+	return goqu.Record{
+		"sport":               sport,
+		"sub_sport":           subSport,
+		"total_timer_time":    timerTime,
+		"total_elapsed_time":  elapsedTime,
+		"total_distance":      distance,
+		"total_ascent":        ascent,
+		"total_descent":       descent,
+		"total_calories":      calories,
+		"device_manufacturer": manufacturer,
+		"device_product":      product,
+		"device_firmware":     firmware,
+	}
+}
+
+// emptySummaryRecord replaces any earlier summary of an activity whose
+// original could not be decoded
+func emptySummaryRecord(decodeErr error) goqu.Record {
+	return goqu.Record{
+		"sport":               "",
+		"sub_sport":           "",
+		"total_timer_time":    0,
+		"total_elapsed_time":  0,
+		"total_distance":      0,
+		"total_ascent":        0,
+		"total_descent":       0,
+		"total_calories":      0,
+		"device_manufacturer": "",
+		"device_product":      "",
+		"device_firmware":     "",
+		"decode_error":        decodeErr.Error(),
+	}
+}
+
+func (o *OriginalSummary) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (o *OriginalSummary) Schedule() string {
+	if o.ScheduleOverride != "" {
+		return o.ScheduleOverride
+	}
+	return "0 45 * * * *"
+}
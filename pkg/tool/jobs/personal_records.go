@@ -0,0 +1,492 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/records"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// EventPersonalRecord is the kind of event emitted for a new personal
+// record
+const EventPersonalRecord = "personal_record"
+
+// recordEventPeriod is how recently an activity must have been imported for
+// its records to be announced, it matches the period ActivitySync refreshes
+const recordEventPeriod = 10 * 24 * time.Hour
+
+// PersonalRecords is a job that compares the best efforts, distance, climb
+// and average speed of new activities against all earlier activities of the
+// same sport, recording any personal records and emitting an event for
+// each. Events are only emitted for recently imported activities so
+// records found in the archive are not announced. Duplicate activities are
+// not compared. When an activity changes, later activities of the same
+// sport are checked again as the records they hold may have changed.
+type PersonalRecords struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (p *PersonalRecords) Name() string {
+	return "personal-records"
+}
+
+func (p *PersonalRecords) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", p.DB)
+
+		history, err := loadRecordHistory(ctx, goquDB)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		// select tracks which are new or have changed, once their best
+		// efforts are up to date, or which have been marked or unmarked as
+		// a duplicate. Activities are compared in the order they happened
+		// so records in the backfill build on each other.
+		query := goquDB.Select(
+			goqu.I("tr.activity_id"),
+			goqu.I("tr.digest"),
+			goqu.I("tr.sport"),
+			goqu.I("a.timestamp"),
+			goqu.I("a.created_at"),
+			goqu.L("a.duplicate_of IS NULL").As("counted"),
+		).
+			From(goqu.T("tracks").Schema("activities").As("tr")).
+			Join(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("tr.activity_id"))),
+			).
+			Join(
+				goqu.T("effort_activities").Schema("activities").As("e"),
+				goqu.On(
+					goqu.I("e.activity_id").Eq(goqu.I("tr.activity_id")),
+					goqu.I("e.track_digest").Eq(goqu.I("tr.digest")),
+				),
+			).
+			LeftJoin(
+				goqu.T("record_activities").Schema("activities").As("r"),
+				goqu.On(goqu.I("r.activity_id").Eq(goqu.I("tr.activity_id"))),
+			).
+			Where(goqu.Or(
+				goqu.I("r.activity_id").IsNull(),
+				goqu.I("r.track_digest").Neq(goqu.I("tr.digest")),
+				goqu.L("r.counted <> (a.duplicate_of IS NULL)"),
+			)).
+			Order(goqu.I("a.timestamp").Asc())
+
+		var rows []recordActivity
+		err = query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks: %v", err)
+			return
+		}
+
+		later, err := laterRecordActivities(ctx, goquDB, rows)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		rows = append(rows, later...)
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Timestamp.Before(rows[j].Timestamp)
+		})
+
+		fmt.Println("processing", len(rows), "with", len(later), "rechecked")
+
+		for _, row := range rows {
+			// activities imported before the event period are part of the
+			// archive, which may take several runs to check
+			backfill := time.Since(row.CreatedAt) > recordEventPeriod
+
+			// duplicates have no values so they hold no records, and the
+			// values of rechecked activities are unchanged
+			var values []records.Value
+			if row.Recheck {
+				values, err = storedRecordValues(ctx, goquDB, row.ID)
+			} else if row.Counted {
+				values, err = activityRecordValues(ctx, goquDB, row.ID, row.Sport)
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			history.Remove(row.ID)
+			found := history.Compare(row.Timestamp, values)
+			for _, v := range values {
+				history.Add(row.ID, row.Timestamp, v)
+			}
+
+			var valueRows, recordRows, eventRows []goqu.Record
+			for _, v := range values {
+				valueRows = append(valueRows, goqu.Record{
+					"activity_id": row.ID,
+					"sport":       v.Sport,
+					"category":    v.Category,
+					"key":         v.Key,
+					"value":       v.Value,
+				})
+			}
+			for _, r := range found {
+				record := goqu.Record{
+					"activity_id":          row.ID,
+					"sport":                r.Sport,
+					"category":             r.Category,
+					"key":                  r.Key,
+					"value":                r.Value.Value,
+					"previous_value":       r.PreviousValue,
+					"previous_activity_id": nil,
+					"achieved_at":          row.Timestamp,
+				}
+				if r.PreviousActivityID != "" {
+					record["previous_activity_id"] = r.PreviousActivityID
+				}
+				recordRows = append(recordRows, record)
+
+				// the first value of a sport is not worth telling anyone
+				// about, and rechecked records were announced when found
+				if backfill || row.Recheck || r.PreviousValue == nil {
+					continue
+				}
+
+				payload, err := json.Marshal(map[string]any{
+					"sport":                r.Sport,
+					"category":             r.Category,
+					"key":                  r.Key,
+					"value":                r.Value.Value,
+					"previous_value":       *r.PreviousValue,
+					"previous_activity_id": r.PreviousActivityID,
+					"achieved_at":          row.Timestamp,
+				})
+				if err != nil {
+					errCh <- fmt.Errorf("failed to marshal event payload: %v", err)
+					return
+				}
+				eventRows = append(eventRows, goqu.Record{
+					"kind":        EventPersonalRecord,
+					"activity_id": row.ID,
+					"payload":     string(payload),
+				})
+			}
+
+			tx, err := goquDB.BeginTx(ctx, nil)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to begin transaction: %v", err)
+				return
+			}
+			err = tx.Wrap(func() error {
+				record := goqu.Record{
+					"activity_id":  row.ID,
+					"track_digest": row.Digest,
+					"counted":      row.Counted,
+					"updated_at":   time.Now(),
+				}
+				_, err := tx.Insert("activities.record_activities").
+					Rows(record).
+					OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to upsert record activity %s: %v", row.ID, err)
+				}
+
+				for _, table := range []string{"activities.activity_record_values", "activities.personal_records"} {
+					_, err = tx.Delete(table).
+						Where(goqu.C("activity_id").Eq(row.ID)).
+						Executor().ExecContext(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to delete from %s for %s: %v", table, row.ID, err)
+					}
+				}
+
+				for table, tableRows := range map[string][]goqu.Record{
+					"activities.activity_record_values": valueRows,
+					"activities.personal_records":       recordRows,
+					"activities.events":                 eventRows,
+				} {
+					if len(tableRows) == 0 {
+						continue
+					}
+
+					_, err = tx.Insert(table).
+						Rows(tableRows).
+						Executor().ExecContext(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to insert into %s for %s: %v", table, row.ID, err)
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			if len(found) > 0 {
+				fmt.Println(row.ID, "has", len(found), "records")
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// recordActivity is an activity to check for records
+type recordActivity struct {
+	ID        string    `db:"activity_id"`
+	Digest    string    `db:"digest"`
+	Sport     string    `db:"sport"`
+	Timestamp time.Time `db:"timestamp"`
+	CreatedAt time.Time `db:"created_at"`
+	Counted   bool      `db:"counted"`
+	// Recheck is set when the activity is unchanged but follows one which
+	// has changed
+	Recheck bool `db:"-"`
+}
+
+// laterRecordActivities returns the unchanged counted activities after the
+// earliest changed activity of the same sport, either the sport of its
+// track or that of the values it was last checked with
+func laterRecordActivities(ctx context.Context, goquDB *goqu.Database, changed []recordActivity) ([]recordActivity, error) {
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	since := make(map[string]time.Time)
+	setSince := func(sport string, timestamp time.Time) {
+		if t, ok := since[sport]; !ok || timestamp.Before(t) {
+			since[sport] = timestamp
+		}
+	}
+
+	var ids []string
+	timestamps := make(map[string]time.Time)
+	for _, row := range changed {
+		ids = append(ids, row.ID)
+		timestamps[row.ID] = row.Timestamp
+		setSince(row.Sport, row.Timestamp)
+	}
+
+	var previous []struct {
+		ActivityID string `db:"activity_id"`
+		Sport      string `db:"sport"`
+	}
+	err := goquDB.Select("activity_id", "sport").
+		Distinct().
+		From("activities.activity_record_values").
+		Where(goqu.C("activity_id").In(ids)).
+		ScanStructsContext(ctx, &previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous record sports: %v", err)
+	}
+	for _, p := range previous {
+		setSince(p.Sport, timestamps[p.ActivityID])
+	}
+
+	var after []goqu.Expression
+	for sport, t := range since {
+		after = append(after, goqu.And(
+			goqu.I("tr.sport").Eq(sport),
+			goqu.I("a.timestamp").Gt(t),
+		))
+	}
+
+	var rows []recordActivity
+	err = goquDB.Select(
+		goqu.I("tr.activity_id"),
+		goqu.I("tr.digest"),
+		goqu.I("tr.sport"),
+		goqu.I("a.timestamp"),
+		goqu.I("a.created_at"),
+		goqu.L("a.duplicate_of IS NULL").As("counted"),
+	).
+		From(goqu.T("tracks").Schema("activities").As("tr")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("tr.activity_id"))),
+		).
+		Join(
+			goqu.T("record_activities").Schema("activities").As("r"),
+			goqu.On(
+				goqu.I("r.activity_id").Eq(goqu.I("tr.activity_id")),
+				goqu.I("r.track_digest").Eq(goqu.I("tr.digest")),
+			),
+		).
+		Where(
+			goqu.I("r.counted").IsTrue(),
+			goqu.I("a.duplicate_of").IsNull(),
+			goqu.I("tr.activity_id").NotIn(ids),
+			goqu.Or(after...),
+		).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get later activities: %v", err)
+	}
+
+	for i := range rows {
+		rows[i].Recheck = true
+	}
+
+	return rows, nil
+}
+
+// storedRecordValues returns the values an activity was last checked with
+func storedRecordValues(ctx context.Context, goquDB *goqu.Database, id string) ([]records.Value, error) {
+	var rows []struct {
+		Sport    string  `db:"sport"`
+		Category string  `db:"category"`
+		Key      string  `db:"key"`
+		Value    float64 `db:"value"`
+	}
+	err := goquDB.Select("sport", "category", "key", "value").
+		From("activities.activity_record_values").
+		Where(goqu.C("activity_id").Eq(id)).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record values for %s: %v", id, err)
+	}
+
+	var values []records.Value
+	for _, r := range rows {
+		values = append(values, records.Value{
+			Sport:    r.Sport,
+			Category: r.Category,
+			Key:      r.Key,
+			Value:    r.Value,
+		})
+	}
+
+	return values, nil
+}
+
+// loadRecordHistory loads the values of all activities already checked
+// which are not duplicates
+func loadRecordHistory(ctx context.Context, goquDB *goqu.Database) (*records.History, error) {
+	var rows []struct {
+		ActivityID string    `db:"activity_id"`
+		Timestamp  time.Time `db:"timestamp"`
+		Sport      string    `db:"sport"`
+		Category   string    `db:"category"`
+		Key        string    `db:"key"`
+		Value      float64   `db:"value"`
+	}
+	err := goquDB.Select(
+		goqu.I("v.activity_id"),
+		goqu.I("a.timestamp"),
+		goqu.I("v.sport"),
+		goqu.I("v.category"),
+		goqu.I("v.key"),
+		goqu.I("v.value"),
+	).
+		From(goqu.T("activity_record_values").Schema("activities").As("v")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("v.activity_id"))),
+		).
+		Where(goqu.I("a.duplicate_of").IsNull()).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record values: %v", err)
+	}
+
+	history := records.NewHistory()
+	for _, r := range rows {
+		history.Add(r.ActivityID, r.Timestamp, records.Value{
+			Sport:    r.Sport,
+			Category: r.Category,
+			Key:      r.Key,
+			Value:    r.Value,
+		})
+	}
+
+	return history, nil
+}
+
+// activityRecordValues returns the values of an activity from its track and
+// the best efforts and power curve already calculated for it
+func activityRecordValues(ctx context.Context, goquDB *goqu.Database, id, sport string) ([]records.Value, error) {
+	points, err := utils.LoadTrackPoints(ctx, goquDB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	values := records.TrackValues(sport, points)
+
+	var bestEfforts []struct {
+		Name    string  `db:"name"`
+		Seconds float64 `db:"seconds"`
+	}
+	err = goquDB.Select("name", "seconds").
+		From("activities.best_efforts").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("distance").Asc()).
+		ScanStructsContext(ctx, &bestEfforts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get best efforts for %s: %v", id, err)
+	}
+	for _, e := range bestEfforts {
+		values = append(values, records.Value{
+			Sport:    sport,
+			Category: records.BestEffort,
+			Key:      e.Name,
+			Value:    e.Seconds,
+		})
+	}
+
+	var curve []struct {
+		Duration int     `db:"duration"`
+		Watts    float64 `db:"watts"`
+	}
+	err = goquDB.Select("duration", "watts").
+		From("activities.power_curves").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("duration").Asc()).
+		ScanStructsContext(ctx, &curve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get power curve for %s: %v", id, err)
+	}
+	for _, c := range curve {
+		values = append(values, records.Value{
+			Sport:    sport,
+			Category: records.Power,
+			Key:      strconv.Itoa(c.Duration),
+			Value:    c.Watts,
+		})
+	}
+
+	return values, nil
+}
+
+func (p *PersonalRecords) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (p *PersonalRecords) Schedule() string {
+	if p.ScheduleOverride != "" {
+		return p.ScheduleOverride
+	}
+	return "0 45 * * * *"
+}
@@ -0,0 +1,248 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/routecluster"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// RouteClustering is a job that assigns each route to a cluster of
+// activities of the same type following substantially the same path, so
+// repeated routes can be compared. Routes are compared with the route of
+// the first activity in each cluster, and start a new cluster when none
+// match.
+type RouteClustering struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (r *RouteClustering) Name() string {
+	return "route-clustering"
+}
+
+func (r *RouteClustering) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", r.DB)
+
+		// select routes which have not been clustered or have changed
+		// since, oldest first so the first activity on a route forms the
+		// cluster
+		query := goquDB.Select(
+			goqu.I("r.activity_id"),
+			goqu.I("r.data_digest"),
+			goqu.I("r.geometry"),
+			goqu.I("a.type"),
+		).
+			From(goqu.T("routes").Schema("activities").As("r")).
+			InnerJoin(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("r.activity_id"))),
+			).
+			LeftJoin(
+				goqu.T("route_cluster_activities").Schema("activities").As("c"),
+				goqu.On(goqu.I("c.activity_id").Eq(goqu.I("r.activity_id"))),
+			).
+			Where(
+				goqu.Or(
+					goqu.I("c.route_digest").IsNull(),
+					goqu.I("c.route_digest").Neq(goqu.I("r.data_digest")),
+				),
+			).
+			Order(goqu.I("a.timestamp").Asc(), goqu.I("r.activity_id").Asc())
+
+		var rows []struct {
+			ActivityID string `db:"activity_id"`
+			DataDigest string `db:"data_digest"`
+			Geometry   []byte `db:"geometry"`
+			Type       string `db:"type"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get routes: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			record := goqu.Record{
+				"activity_id":  row.ActivityID,
+				"route_id":     nil,
+				"route_digest": row.DataDigest,
+				"distance":     nil,
+				"updated_at":   time.Now(),
+			}
+
+			var route routecluster.Route
+			ok := false
+			if row.Geometry != nil {
+				line, err := queries.ParseLineString(row.Geometry)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to parse geometry for %s: %v", row.ActivityID, err)
+					return
+				}
+				route, ok = routecluster.NewRoute(line)
+			}
+
+			if ok {
+				routeID, distance, err := matchRouteCluster(ctx, goquDB, row.Type, route)
+				if err != nil {
+					errCh <- err
+					return
+				}
+
+				if routeID == 0 {
+					routeID, err = createRouteCluster(ctx, goquDB, row.Type, route)
+					if err != nil {
+						errCh <- err
+						return
+					}
+				}
+
+				record["route_id"] = routeID
+				record["distance"] = distance
+			}
+
+			_, err = goquDB.Insert("activities.route_cluster_activities").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to upsert route cluster of %s: %v", row.ActivityID, err)
+				return
+			}
+		}
+
+		// clusters are removed once no activities follow them, such as when
+		// the first activity's route changed
+		members := goquDB.Select("route_id").
+			From("activities.route_cluster_activities").
+			Where(goqu.C("route_id").IsNotNull())
+		_, err = goquDB.Delete("activities.route_clusters").
+			Where(goqu.C("id").NotIn(members)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to delete empty route clusters: %v", err)
+			return
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// matchRouteCluster returns the ID of the cluster of the activity type
+// closest to the route and the distance from it, zero is returned when no
+// cluster matches
+func matchRouteCluster(ctx context.Context, goquDB *goqu.Database, activityType string, route routecluster.Route) (int64, float64, error) {
+	near := geo.BBoxAround(route.Start(), routecluster.EndpointRadius)
+
+	var clusters []struct {
+		ID       int64   `db:"id"`
+		Geometry []byte  `db:"geometry"`
+		Length   float64 `db:"length"`
+	}
+	err := goquDB.Select("id", "geometry", "length").
+		From("activities.route_clusters").
+		Where(
+			goqu.C("type").Eq(activityType),
+			goqu.C("start_lat").Between(goqu.Range(near.MinLat, near.MaxLat)),
+			goqu.C("start_lon").Between(goqu.Range(near.MinLon, near.MaxLon)),
+		).
+		Order(goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &clusters)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get route clusters: %v", err)
+	}
+
+	var bestID int64
+	var bestDistance float64
+	for _, c := range clusters {
+		points, err := queries.ParseLineString(c.Geometry)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse geometry for route %d: %v", c.ID, err)
+		}
+		if len(points) < 2 {
+			continue
+		}
+		bounds, _ := geo.Bounds(points)
+
+		same, distance := routecluster.Same(routecluster.Route{
+			Points: points,
+			Length: c.Length,
+			Bounds: bounds,
+		}, route)
+		if same && (bestID == 0 || distance < bestDistance) {
+			bestID, bestDistance = c.ID, distance
+		}
+	}
+
+	return bestID, bestDistance, nil
+}
+
+// createRouteCluster stores the route as a new cluster and returns its ID
+func createRouteCluster(ctx context.Context, goquDB *goqu.Database, activityType string, route routecluster.Route) (int64, error) {
+	coordinates := make([][2]float64, len(route.Points))
+	for i, p := range route.Points {
+		coordinates[i] = [2]float64{p.Lon, p.Lat}
+	}
+	geometry, err := json.Marshal(map[string]any{
+		"type":        "LineString",
+		"coordinates": coordinates,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal route geometry: %v", err)
+	}
+
+	start, end := route.Start(), route.End()
+
+	var id int64
+	_, err = goquDB.Insert("activities.route_clusters").
+		Rows(goqu.Record{
+			"type":      activityType,
+			"geometry":  string(geometry),
+			"length":    route.Length,
+			"start_lat": start.Lat,
+			"start_lon": start.Lon,
+			"end_lat":   end.Lat,
+			"end_lon":   end.Lon,
+		}).
+		Returning("id").
+		Executor().ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create route cluster: %v", err)
+	}
+
+	return id, nil
+}
+
+func (r *RouteClustering) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (r *RouteClustering) Schedule() string {
+	if r.ScheduleOverride != "" {
+		return r.ScheduleOverride
+	}
+	return "0 45 * * * *"
+}
@@ -0,0 +1,232 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geohash"
+	"github.com/charlieegan3/tool-activities/internal/pkg/polyline"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// RouteGeometry is a job that decodes the polylines in the archived Strava
+// activity data and stores the route geometry, bounding box and the geohash
+// cells the route passes through
+type RouteGeometry struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (r *RouteGeometry) Name() string {
+	return "route-geometry"
+}
+
+func (r *RouteGeometry) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(r.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(r.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", r.DB)
+
+		// select synced activities without a route or where the activity
+		// data has changed since the route was decoded
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.data_digest"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			LeftJoin(
+				goqu.T("routes").Schema("activities").As("r"),
+				goqu.On(goqu.I("r.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Where(
+				goqu.I("a.data_digest").Neq(""),
+				goqu.Or(
+					goqu.I("r.data_digest").IsNull(),
+					goqu.I("r.data_digest").Neq(goqu.I("a.data_digest")),
+				),
+			).
+			Order(goqu.I("a.id").Asc())
+
+		var rows []struct {
+			ID         string `db:"id"`
+			DataDigest string `db:"data_digest"`
+		}
+
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			activity, err := utils.ReadActivityData(ctx, bucket, row.ID)
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				fmt.Println(row.ID, "data not found, skipping")
+				continue
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			record := goqu.Record{
+				"activity_id":      row.ID,
+				"data_digest":      row.DataDigest,
+				"polyline":         string(activity.Map.Polyline),
+				"summary_polyline": string(activity.Map.SummaryPolyline),
+				"geometry":         nil,
+				"point_count":      0,
+				"start_lat":        nil,
+				"start_lon":        nil,
+				"end_lat":          nil,
+				"end_lon":          nil,
+				"min_lat":          nil,
+				"min_lon":          nil,
+				"max_lat":          nil,
+				"max_lon":          nil,
+				"updated_at":       time.Now(),
+			}
+
+			encoded := string(activity.Map.Polyline)
+			if encoded == "" {
+				encoded = string(activity.Map.SummaryPolyline)
+			}
+
+			points, err := polyline.Decode(encoded)
+			if err != nil {
+				fmt.Println(row.ID, "failed to decode polyline:", err)
+				points = nil
+			}
+
+			if len(points) > 0 {
+				coordinates := make([][2]float64, len(points))
+				for i, p := range points {
+					coordinates[i] = [2]float64{p.Lon, p.Lat}
+				}
+				geometry, err := json.Marshal(map[string]any{
+					"type":        "LineString",
+					"coordinates": coordinates,
+				})
+				if err != nil {
+					errCh <- fmt.Errorf("failed to marshal geometry for %s: %v", row.ID, err)
+					return
+				}
+
+				start, end := points[0], points[len(points)-1]
+				bounds, _ := geo.Bounds(points)
+
+				record["geometry"] = string(geometry)
+				record["point_count"] = len(points)
+				record["start_lat"] = start.Lat
+				record["start_lon"] = start.Lon
+				record["end_lat"] = end.Lat
+				record["end_lon"] = end.Lon
+				record["min_lat"] = bounds.MinLat
+				record["min_lon"] = bounds.MinLon
+				record["max_lat"] = bounds.MaxLat
+				record["max_lon"] = bounds.MaxLon
+			}
+
+			err = storeRoute(ctx, goquDB, record, geohash.CoverLine(points, queries.RouteCellPrecision))
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// storeRoute upserts the route and replaces the geohash cells it passes
+// through, which are used to find activities in an area
+func storeRoute(ctx context.Context, goquDB *goqu.Database, record goqu.Record, cells []string) error {
+	id := record["activity_id"]
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	return tx.Wrap(func() error {
+		_, err := tx.Insert("activities.routes").
+			Rows(record).
+			OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert route for %s: %v", id, err)
+		}
+
+		_, err = tx.Delete("activities.route_cells").
+			Where(goqu.C("activity_id").Eq(id)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete cells for %s: %v", id, err)
+		}
+
+		if len(cells) == 0 {
+			return nil
+		}
+
+		var rows []goqu.Record
+		for _, cell := range cells {
+			rows = append(rows, goqu.Record{"cell": cell, "activity_id": id})
+		}
+		_, err = tx.Insert("activities.route_cells").
+			Rows(rows).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert cells for %s: %v", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (r *RouteGeometry) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (r *RouteGeometry) Schedule() string {
+	if r.ScheduleOverride != "" {
+		return r.ScheduleOverride
+	}
+	return "0 40 * * * *"
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// RouteMaps is a job that renders an SVG and PNG map of each activity's
+// route and stores them in the bucket
+type RouteMaps struct {
+	DB *sql.DB
+
+	Privacy *privacy.Policy
+
+	ScheduleOverride string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (r *RouteMaps) Name() string {
+	return "route-maps"
+}
+
+func (r *RouteMaps) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(r.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(r.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", r.DB)
+
+		// select routes without maps or where the route or privacy policy
+		// has changed since the maps were rendered
+		query := goquDB.Select(goqu.I("r.activity_id")).
+			From(goqu.T("routes").Schema("activities").As("r")).
+			LeftJoin(
+				goqu.T("route_maps").Schema("activities").As("m"),
+				goqu.On(goqu.I("m.activity_id").Eq(goqu.I("r.activity_id"))),
+			).
+			Where(
+				goqu.I("r.geometry").IsNotNull(),
+				goqu.Or(
+					goqu.I("m.data_digest").IsNull(),
+					goqu.I("m.data_digest").Neq(goqu.I("r.data_digest")),
+					goqu.I("m.privacy_digest").Neq(r.Privacy.Digest()),
+				),
+			).
+			Order(goqu.I("r.activity_id").Asc())
+
+		var ids []string
+		err := query.Executor().ScanValsContext(ctx, &ids)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activity IDs: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(ids))
+
+		for _, id := range ids {
+			_, err = utils.StoreRouteMaps(ctx, r.DB, bucket, r.Privacy, id)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (r *RouteMaps) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+func (r *RouteMaps) Schedule() string {
+	if r.ScheduleOverride != "" {
+		return r.ScheduleOverride
+	}
+	return "0 55 * * * *"
+}
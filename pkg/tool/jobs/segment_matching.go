@@ -0,0 +1,230 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/segments"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// SegmentMatching is a job that matches tracks against the user defined
+// segments and records each effort along them. New segments are matched
+// against every track in the archive and new or changed tracks against
+// every segment. Only tracks with a route passing through the segment's
+// bounding box are matched.
+type SegmentMatching struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+}
+
+func (s *SegmentMatching) Name() string {
+	return "segment-matching"
+}
+
+func (s *SegmentMatching) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", s.DB)
+
+		query := goquDB.Select(
+			goqu.I("s.id").As("segment_id"),
+			goqu.I("tr.activity_id"),
+			goqu.I("tr.digest"),
+		).
+			From(goqu.T("segments").Schema("activities").As("s")).
+			CrossJoin(goqu.T("tracks").Schema("activities").As("tr")).
+			InnerJoin(
+				goqu.T("routes").Schema("activities").As("r"),
+				goqu.On(
+					goqu.I("r.activity_id").Eq(goqu.I("tr.activity_id")),
+					goqu.I("r.min_lat").Lte(goqu.I("s.max_lat")),
+					goqu.I("r.max_lat").Gte(goqu.I("s.min_lat")),
+					goqu.I("r.min_lon").Lte(goqu.I("s.max_lon")),
+					goqu.I("r.max_lon").Gte(goqu.I("s.min_lon")),
+				),
+			).
+			LeftJoin(
+				goqu.T("segment_activities").Schema("activities").As("sa"),
+				goqu.On(
+					goqu.I("sa.segment_id").Eq(goqu.I("s.id")),
+					goqu.I("sa.activity_id").Eq(goqu.I("tr.activity_id")),
+				),
+			).
+			Where(goqu.Or(
+				goqu.I("sa.activity_id").IsNull(),
+				goqu.I("sa.track_digest").Neq(goqu.I("tr.digest")),
+			)).
+			Order(goqu.I("tr.activity_id").Asc(), goqu.I("s.id").Asc())
+
+		var rows []struct {
+			SegmentID  int64  `db:"segment_id"`
+			ActivityID string `db:"activity_id"`
+			Digest     string `db:"digest"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks to match: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		loaded := make(map[int64]segments.Segment)
+		var points []track.Point
+		pointsID := ""
+
+		for _, row := range rows {
+			segment, ok := loaded[row.SegmentID]
+			if !ok {
+				segment, err = loadSegment(ctx, goquDB, row.SegmentID)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				loaded[row.SegmentID] = segment
+			}
+
+			// rows are ordered by activity so each track is loaded once
+			if pointsID != row.ActivityID {
+				points, err = utils.LoadTrackPoints(ctx, goquDB, row.ActivityID)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				pointsID = row.ActivityID
+			}
+
+			var efforts []goqu.Record
+			for _, e := range segments.Match(segment, points) {
+				efforts = append(efforts, goqu.Record{
+					"segment_id":         row.SegmentID,
+					"activity_id":        row.ActivityID,
+					"start_offset":       e.StartOffset,
+					"end_offset":         e.EndOffset,
+					"start_time":         points[e.StartOffset].Time,
+					"elapsed_time":       e.ElapsedTime,
+					"distance":           e.Distance,
+					"average_power":      e.AveragePower,
+					"average_heart_rate": e.AverageHeartRate,
+				})
+			}
+
+			err = storeSegmentEfforts(ctx, goquDB, row.SegmentID, row.ActivityID, row.Digest, efforts)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			if len(efforts) > 0 {
+				fmt.Println(row.ActivityID, "has", len(efforts), "efforts on segment", row.SegmentID)
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// loadSegment reads the line of a segment
+func loadSegment(ctx context.Context, goquDB *goqu.Database, id int64) (segments.Segment, error) {
+	var row struct {
+		Geometry []byte  `db:"geometry"`
+		Length   float64 `db:"length"`
+	}
+	_, err := goquDB.Select("geometry", "length").
+		From("activities.segments").
+		Where(goqu.C("id").Eq(id)).
+		ScanStructContext(ctx, &row)
+	if err != nil {
+		return segments.Segment{}, fmt.Errorf("failed to get segment %d: %v", id, err)
+	}
+
+	points, err := queries.ParseLineString(row.Geometry)
+	if err != nil {
+		return segments.Segment{}, fmt.Errorf("failed to parse geometry of segment %d: %v", id, err)
+	}
+
+	return segments.Segment{Points: points, Length: row.Length}, nil
+}
+
+// storeSegmentEfforts records that the version of the track was matched
+// against the segment and replaces its efforts
+func storeSegmentEfforts(ctx context.Context, goquDB *goqu.Database, segmentID int64, activityID, digest string, efforts []goqu.Record) error {
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	return tx.Wrap(func() error {
+		record := goqu.Record{
+			"segment_id":   segmentID,
+			"activity_id":  activityID,
+			"track_digest": digest,
+			"updated_at":   time.Now(),
+		}
+		_, err := tx.Insert("activities.segment_activities").
+			Rows(record).
+			OnConflict(goqu.DoUpdate(
+				"segment_id, activity_id",
+				excludedUpdates(record, "segment_id", "activity_id"),
+			)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert segment activity %s: %v", activityID, err)
+		}
+
+		_, err = tx.Delete("activities.segment_efforts").
+			Where(
+				goqu.C("segment_id").Eq(segmentID),
+				goqu.C("activity_id").Eq(activityID),
+			).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete segment efforts for %s: %v", activityID, err)
+		}
+
+		if len(efforts) == 0 {
+			return nil
+		}
+
+		_, err = tx.Insert("activities.segment_efforts").
+			Rows(efforts).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert segment efforts for %s: %v", activityID, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *SegmentMatching) Timeout() time.Duration {
+	return 30 * time.Minute
+}
+
+func (s *SegmentMatching) Schedule() string {
+	if s.ScheduleOverride != "" {
+		return s.ScheduleOverride
+	}
+	// frequent so segments are matched over the archive soon after they
+	// are created, runs with nothing to match are cheap
+	return "0 */10 * * * *"
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/zones"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// TimeInZones is a job that calculates the time each activity spent in
+// heart rate and power zones, using the zones configured for the date of
+// the activity
+type TimeInZones struct {
+	DB *sql.DB
+
+	Zones *zones.Schedule
+
+	ScheduleOverride string
+}
+
+func (t *TimeInZones) Name() string {
+	return "time-in-zones"
+}
+
+func (t *TimeInZones) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		if t.Zones == nil {
+			fmt.Println("no zones configured")
+			doneCh <- true
+			return
+		}
+
+		goquDB := goqu.New("postgres", t.DB)
+		zonesDigest := t.Zones.Digest()
+
+		// select tracks which are new or have changed, or all tracks when the
+		// zones have changed
+		query := goquDB.Select(
+			goqu.I("tr.activity_id"),
+			goqu.I("tr.digest"),
+			goqu.I("a.timestamp"),
+		).
+			From(goqu.T("tracks").Schema("activities").As("tr")).
+			Join(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("tr.activity_id"))),
+			).
+			LeftJoin(
+				goqu.T("zone_activities").Schema("activities").As("z"),
+				goqu.On(goqu.I("z.activity_id").Eq(goqu.I("tr.activity_id"))),
+			).
+			Where(goqu.Or(
+				goqu.I("z.activity_id").IsNull(),
+				goqu.I("z.track_digest").Neq(goqu.I("tr.digest")),
+				goqu.I("z.zones_digest").Neq(zonesDigest),
+			)).
+			Order(goqu.I("tr.activity_id").Asc())
+
+		var rows []struct {
+			ID        string    `db:"activity_id"`
+			Digest    string    `db:"digest"`
+			Timestamp time.Time `db:"timestamp"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			points, err := utils.LoadTrackPoints(ctx, goquDB, row.ID)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			var zoneRows []goqu.Record
+			for _, kind := range []string{zones.HeartRate, zones.Power} {
+				definition, ok := t.Zones.At(kind, row.Timestamp)
+				if !ok {
+					continue
+				}
+
+				seconds, ok := zones.TimeInZones(points, definition, zones.Values(kind))
+				if !ok {
+					continue
+				}
+
+				for i, s := range seconds {
+					record := goqu.Record{
+						"activity_id": row.ID,
+						"kind":        kind,
+						"zone":        i + 1,
+						"min_value":   definition.Boundaries[i],
+						"max_value":   nil,
+						"seconds":     s,
+					}
+					if i < len(definition.Boundaries)-1 {
+						record["max_value"] = definition.Boundaries[i+1]
+					}
+					zoneRows = append(zoneRows, record)
+				}
+			}
+
+			tx, err := goquDB.BeginTx(ctx, nil)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to begin transaction: %v", err)
+				return
+			}
+			err = tx.Wrap(func() error {
+				record := goqu.Record{
+					"activity_id":  row.ID,
+					"track_digest": row.Digest,
+					"zones_digest": zonesDigest,
+					"updated_at":   time.Now(),
+				}
+				_, err := tx.Insert("activities.zone_activities").
+					Rows(record).
+					OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to upsert zone activity %s: %v", row.ID, err)
+				}
+
+				_, err = tx.Delete("activities.activity_zones").
+					Where(goqu.C("activity_id").Eq(row.ID)).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to delete zones for %s: %v", row.ID, err)
+				}
+
+				if len(zoneRows) == 0 {
+					return nil
+				}
+
+				_, err = tx.Insert("activities.activity_zones").
+					Rows(zoneRows).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert zones for %s: %v", row.ID, err)
+				}
+
+				return nil
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+func (t *TimeInZones) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (t *TimeInZones) Schedule() string {
+	if t.ScheduleOverride != "" {
+		return t.ScheduleOverride
+	}
+	return "0 30 * * * *"
+}
@@ -0,0 +1,273 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+	strava "github.com/strava/go.strava"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	internalStrava "github.com/charlieegan3/tool-activities/internal/pkg/strava"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// trackPointsBatchSize is the number of points inserted in each statement
+const trackPointsBatchSize = 1000
+
+// TrackPoints is a job that decodes each activity's original, or its
+// Strava streams when there is no original, and stores the points in the
+// database
+type TrackPoints struct {
+	DB *sql.DB
+
+	ScheduleOverride string
+
+	StravaClientID     string
+	StravaClientSecret string
+	StravaRefreshToken string
+
+	GoogleCredentialsJSON string
+	GoogleBucketName      string
+}
+
+func (t *TrackPoints) Name() string {
+	return "track-points"
+}
+
+func (t *TrackPoints) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	storageClient, err := storage.NewClient(
+		ctx,
+		option.WithCredentialsJSON([]byte(t.GoogleCredentialsJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	defer storageClient.Close()
+
+	bucket := storageClient.Bucket(t.GoogleBucketName)
+
+	go func() {
+		goquDB := goqu.New("postgres", t.DB)
+
+		query := goquDB.Select(
+			goqu.I("a.id"),
+			goqu.I("a.timestamp"),
+			goqu.I("a.type"),
+			goqu.I("a.original_format"),
+			goqu.I("a.original_digest"),
+			goqu.I("a.data_digest"),
+			goqu.COALESCE(goqu.I("t.digest"), "").As("track_digest"),
+		).
+			From(goqu.T("activities").Schema("activities").As("a")).
+			LeftJoin(
+				goqu.T("tracks").Schema("activities").As("t"),
+				goqu.On(goqu.I("t.activity_id").Eq(goqu.I("a.id"))),
+			).
+			Order(goqu.I("a.id").Asc())
+
+		var rows []struct {
+			ID             string    `db:"id"`
+			Timestamp      time.Time `db:"timestamp"`
+			Type           string    `db:"type"`
+			OriginalFormat string    `db:"original_format"`
+			OriginalDigest string    `db:"original_digest"`
+			DataDigest     string    `db:"data_digest"`
+			TrackDigest    string    `db:"track_digest"`
+		}
+
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get activities: %v", err)
+			return
+		}
+
+		var streamsService *strava.ActivityStreamsService
+
+		for _, row := range rows {
+			hasOriginal := row.OriginalFormat == format.FIT ||
+				row.OriginalFormat == format.GPX ||
+				row.OriginalFormat == format.TCX
+
+			source, digest := "original", row.OriginalDigest
+			if !hasOriginal {
+				source, digest = "streams", row.DataDigest
+			}
+
+			// skip activities which have not been synced yet or where the
+			// stored points are up to date
+			if digest == "" || digest == row.TrackDigest {
+				continue
+			}
+
+			var trk *track.Track
+			if hasOriginal {
+				data, err := utils.ReadOriginal(ctx, bucket, row.ID, row.OriginalFormat)
+				if errors.Is(err, storage.ErrObjectNotExist) {
+					fmt.Println(row.ID, "original not found, skipping")
+					continue
+				}
+				if err != nil {
+					errCh <- err
+					return
+				}
+
+				trk, err = track.Decode(data, row.OriginalFormat)
+				if err != nil {
+					// a broken original should not block the remaining
+					// activities, it is stored with no points so it is not
+					// decoded again until the original changes
+					fmt.Println(row.ID, "failed to decode:", err)
+					trk = &track.Track{Sport: internalStrava.SportName(row.Type)}
+				}
+			} else {
+				if streamsService == nil {
+					accessToken, err := internalStrava.GetAccessToken(
+						t.StravaClientID,
+						t.StravaClientSecret,
+						t.StravaRefreshToken,
+					)
+					if err != nil {
+						errCh <- fmt.Errorf("failed to get access token: %w", err)
+						return
+					}
+					streamsService = strava.NewActivityStreamsService(strava.NewClient(accessToken))
+				}
+
+				id, err := strconv.ParseInt(row.ID, 10, 64)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to parse activity ID %s: %v", row.ID, err)
+					return
+				}
+
+				streams, err := streamsService.Get(id, internalStrava.AllStreamTypes).
+					Resolution("high").
+					Do()
+				if err, ok := err.(strava.Error); ok && err.Message == "Record Not Found" {
+					fmt.Println(row.ID, "streams not found, skipping")
+					continue
+				}
+				if err != nil {
+					errCh <- fmt.Errorf("failed to get streams for %s: %w", row.ID, err)
+					return
+				}
+
+				trk = internalStrava.StreamsToTrack(row.Timestamp, streams)
+				trk.Sport = internalStrava.SportName(row.Type)
+			}
+
+			err = storeTrack(ctx, goquDB, row.ID, source, digest, trk)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			fmt.Println(row.ID, source, len(trk.Points))
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// storeTrack replaces the stored points for an activity
+func storeTrack(ctx context.Context, goquDB *goqu.Database, id, source, digest string, trk *track.Track) error {
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	return tx.Wrap(func() error {
+		_, err := tx.Insert("activities.tracks").
+			Rows(goqu.Record{
+				"activity_id": id,
+				"source":      source,
+				"digest":      digest,
+				"sport":       trk.Sport,
+				"point_count": len(trk.Points),
+				"updated_at":  time.Now(),
+			}).
+			OnConflict(goqu.DoUpdate("activity_id", goqu.Record{
+				"source":      goqu.L("EXCLUDED.source"),
+				"digest":      goqu.L("EXCLUDED.digest"),
+				"sport":       goqu.L("EXCLUDED.sport"),
+				"point_count": goqu.L("EXCLUDED.point_count"),
+				"updated_at":  goqu.L("EXCLUDED.updated_at"),
+			})).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert track for %s: %v", id, err)
+		}
+
+		_, err = tx.Delete("activities.track_points").
+			Where(goqu.C("activity_id").Eq(id)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete points for %s: %v", id, err)
+		}
+
+		var rows []goqu.Record
+		for i, p := range trk.Points {
+			record := goqu.Record{
+				"activity_id":  id,
+				"point_offset": i,
+				"time":         p.Time,
+				"lat":          nil,
+				"lon":          nil,
+				"elevation":    p.Elevation,
+				"heart_rate":   p.HeartRate,
+				"cadence":      p.Cadence,
+				"power":        p.Power,
+				"speed":        p.Speed,
+				"distance":     p.Distance,
+				"temperature":  p.Temperature,
+			}
+			if p.Position != nil {
+				record["lat"] = p.Position.Lat
+				record["lon"] = p.Position.Lon
+			}
+			rows = append(rows, record)
+
+			if len(rows) == trackPointsBatchSize || i == len(trk.Points)-1 {
+				_, err = tx.Insert("activities.track_points").
+					Rows(rows).
+					Executor().ExecContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to insert points for %s: %v", id, err)
+				}
+				rows = nil
+			}
+		}
+
+		return nil
+	})
+}
+
+func (t *TrackPoints) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (t *TrackPoints) Schedule() string {
+	if t.ScheduleOverride != "" {
+		return t.ScheduleOverride
+	}
+	return "0 50 * * * *"
+}
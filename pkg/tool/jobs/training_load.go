@@ -0,0 +1,310 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/load"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+	"github.com/charlieegan3/tool-activities/internal/pkg/zones"
+	"github.com/charlieegan3/tool-activities/pkg/tool/utils"
+)
+
+// TrainingLoad is a job that calculates the training stress of each
+// activity, using power and FTP where possible and heart rate otherwise,
+// and the daily fitness, fatigue and form series from the stress. Duplicate
+// activities are not counted.
+type TrainingLoad struct {
+	DB *sql.DB
+
+	Zones *zones.Schedule
+
+	ScheduleOverride string
+}
+
+func (t *TrainingLoad) Name() string {
+	return "training-load"
+}
+
+func (t *TrainingLoad) Run(ctx context.Context) error {
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+
+	go func() {
+		goquDB := goqu.New("postgres", t.DB)
+		zonesDigest := t.Zones.Digest()
+
+		// select tracks of activities which are not duplicates and are new
+		// or where the track, the activity or the zones have changed since
+		// the stress was calculated
+		query := goquDB.Select(
+			goqu.I("tr.activity_id"),
+			goqu.I("tr.digest"),
+			goqu.I("a.data_digest"),
+			goqu.I("a.timestamp"),
+		).
+			From(goqu.T("tracks").Schema("activities").As("tr")).
+			Join(
+				goqu.T("activities").Schema("activities").As("a"),
+				goqu.On(goqu.I("a.id").Eq(goqu.I("tr.activity_id"))),
+			).
+			LeftJoin(
+				goqu.T("activity_loads").Schema("activities").As("l"),
+				goqu.On(goqu.I("l.activity_id").Eq(goqu.I("tr.activity_id"))),
+			).
+			Where(
+				goqu.I("a.duplicate_of").IsNull(),
+				goqu.Or(
+					goqu.I("l.activity_id").IsNull(),
+					goqu.I("l.track_digest").Neq(goqu.I("tr.digest")),
+					goqu.I("l.data_digest").Neq(goqu.I("a.data_digest")),
+					goqu.I("l.zones_digest").Neq(zonesDigest),
+				),
+			).
+			Order(goqu.I("tr.activity_id").Asc())
+
+		var rows []struct {
+			ID         string    `db:"activity_id"`
+			Digest     string    `db:"digest"`
+			DataDigest string    `db:"data_digest"`
+			Timestamp  time.Time `db:"timestamp"`
+		}
+		err := query.Executor().ScanStructsContext(ctx, &rows)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to get tracks: %v", err)
+			return
+		}
+
+		fmt.Println("processing", len(rows))
+
+		for _, row := range rows {
+			points, err := utils.LoadTrackPoints(ctx, goquDB, row.ID)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			record := goqu.Record{
+				"activity_id":      row.ID,
+				"track_digest":     row.Digest,
+				"data_digest":      row.DataDigest,
+				"zones_digest":     zonesDigest,
+				"date":             load.Date(row.Timestamp).Format("2006-01-02"),
+				"method":           "",
+				"stress":           0,
+				"normalized_power": nil,
+				"intensity":        nil,
+				"updated_at":       time.Now(),
+			}
+
+			if stress, ok := t.stress(points, row.Timestamp); ok {
+				record["method"] = stress.Method
+				record["stress"] = stress.Score
+				if stress.Method == load.MethodPower {
+					record["normalized_power"] = stress.NormalizedPower
+					record["intensity"] = stress.Intensity
+				}
+			}
+
+			_, err = goquDB.Insert("activities.activity_loads").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("activity_id", excludedUpdates(record, "activity_id"))).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to upsert activity load %s: %v", row.ID, err)
+				return
+			}
+		}
+
+		err = updateTrainingLoadDays(ctx, goquDB, load.Date(time.Now()))
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		doneCh <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-errCh:
+		return fmt.Errorf("job failed with error: %s", e)
+	case <-doneCh:
+		return nil
+	}
+}
+
+// stress uses power when the activity has power and an FTP is configured
+// for the date, and heart rate reserve when max and resting heart rates are
+func (t *TrainingLoad) stress(points []track.Point, timestamp time.Time) (load.Stress, bool) {
+	if d, ok := t.Zones.At(zones.Power, timestamp); ok {
+		if stress, ok := load.PowerStress(points, d.Threshold); ok {
+			return stress, true
+		}
+	}
+
+	if d, ok := t.Zones.At(zones.HeartRate, timestamp); ok {
+		if stress, ok := load.TRIMPStress(points, d.Threshold, d.Resting); ok {
+			return stress, true
+		}
+	}
+
+	return load.Stress{}, false
+}
+
+// updateTrainingLoadDays recalculates the daily series from the first date
+// where the stored stress no longer matches the activities, or continues
+// it from the last stored day when nothing has changed, until today.
+// Marking an activity as a duplicate changes the stress of its day.
+func updateTrainingLoadDays(ctx context.Context, goquDB *goqu.Database, today time.Time) error {
+	activityStress := goquDB.Select(
+		goqu.I("l.date"),
+		goqu.SUM("l.stress").As("stress"),
+	).
+		From(goqu.T("activity_loads").Schema("activities").As("l")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("l.activity_id"))),
+		).
+		Where(goqu.I("a.duplicate_of").IsNull()).
+		GroupBy(goqu.I("l.date"))
+
+	var changed sql.NullTime
+	_, err := goquDB.Select(goqu.MIN(goqu.COALESCE(goqu.I("d.date"), goqu.I("s.date")))).
+		From(goqu.T("training_load_days").Schema("activities").As("d")).
+		FullOuterJoin(
+			activityStress.As("s"),
+			goqu.On(goqu.I("s.date").Eq(goqu.I("d.date"))),
+		).
+		Where(goqu.L(
+			"ABS(COALESCE(?, 0) - COALESCE(?, 0)) > 0.001",
+			goqu.I("d.stress"),
+			goqu.I("s.stress"),
+		)).
+		ScanValContext(ctx, &changed)
+	if err != nil {
+		return fmt.Errorf("failed to find changed training load days: %v", err)
+	}
+
+	var last sql.NullTime
+	_, err = goquDB.Select(goqu.MAX("date")).
+		From("activities.training_load_days").
+		ScanValContext(ctx, &last)
+	if err != nil {
+		return fmt.Errorf("failed to find last training load day: %v", err)
+	}
+
+	// the series is recalculated from the first changed date, or continued
+	// after the last stored day when there are no changes
+	var from time.Time
+	switch {
+	case changed.Valid:
+		from = load.Date(changed.Time)
+	case last.Valid:
+		from = load.Date(last.Time).AddDate(0, 0, 1)
+	default:
+		// with no days stored, the changed date covers all activities with
+		// stress so there are none
+		return nil
+	}
+	if from.After(today) {
+		return nil
+	}
+
+	previous := load.Day{Date: from.AddDate(0, 0, -1)}
+	var seed []struct {
+		Fitness float64 `db:"fitness"`
+		Fatigue float64 `db:"fatigue"`
+	}
+	err = goquDB.Select("fitness", "fatigue").
+		From("activities.training_load_days").
+		Where(goqu.C("date").Eq(previous.Date.Format("2006-01-02"))).
+		ScanStructsContext(ctx, &seed)
+	if err != nil {
+		return fmt.Errorf("failed to get training load before %s: %v", from.Format("2006-01-02"), err)
+	}
+	if len(seed) > 0 {
+		previous.Fitness = seed[0].Fitness
+		previous.Fatigue = seed[0].Fatigue
+	}
+
+	var stressRows []struct {
+		Date   time.Time `db:"date"`
+		Stress float64   `db:"stress"`
+	}
+	err = activityStress.
+		Where(goqu.I("l.date").Gte(from.Format("2006-01-02"))).
+		ScanStructsContext(ctx, &stressRows)
+	if err != nil {
+		return fmt.Errorf("failed to get activity stress: %v", err)
+	}
+
+	stress := make(map[time.Time]float64)
+	for _, r := range stressRows {
+		stress[load.Date(r.Date)] = r.Stress
+	}
+
+	// days before the first activity with stress are not stored
+	if len(seed) == 0 {
+		for !previous.Date.After(today) && stress[previous.Date.AddDate(0, 0, 1)] == 0 {
+			previous.Date = previous.Date.AddDate(0, 0, 1)
+		}
+	}
+
+	days := load.Series(previous, stress, today)
+
+	var dayRows []goqu.Record
+	for _, d := range days {
+		dayRows = append(dayRows, goqu.Record{
+			"date":    d.Date.Format("2006-01-02"),
+			"stress":  d.Stress,
+			"fitness": d.Fitness,
+			"fatigue": d.Fatigue,
+			"form":    d.Form,
+		})
+	}
+
+	fmt.Println("updating training load from", from.Format("2006-01-02"))
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	return tx.Wrap(func() error {
+		_, err := tx.Delete("activities.training_load_days").
+			Where(goqu.C("date").Gte(from.Format("2006-01-02"))).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete training load days: %v", err)
+		}
+
+		if len(dayRows) == 0 {
+			return nil
+		}
+
+		_, err = tx.Insert("activities.training_load_days").
+			Rows(dayRows).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert training load days: %v", err)
+		}
+
+		return nil
+	})
+}
+
+func (t *TrainingLoad) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (t *TrainingLoad) Schedule() string {
+	if t.ScheduleOverride != "" {
+		return t.ScheduleOverride
+	}
+	return "0 40 * * * *"
+}
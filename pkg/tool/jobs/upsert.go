@@ -0,0 +1,24 @@
+package jobs
+
+import "github.com/doug-martin/goqu/v9"
+
+// excludedUpdates builds the update for an upsert of record, setting every
+// column other than the conflict keys to the value from the insert
+func excludedUpdates(record goqu.Record, keys ...string) goqu.Record {
+	updates := goqu.Record{}
+	for k := range record {
+		if !contains(keys, k) {
+			updates[k] = goqu.L("EXCLUDED." + k)
+		}
+	}
+	return updates
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
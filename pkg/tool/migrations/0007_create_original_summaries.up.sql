@@ -0,0 +1,24 @@
+SET search_path TO activities, public;
+
+CREATE TABLE IF NOT EXISTS original_summaries(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    -- the original_digest of the activity when the summary was generated
+    original_digest TEXT NOT NULL,
+
+    sport TEXT NOT NULL DEFAULT '',
+    sub_sport TEXT NOT NULL DEFAULT '',
+
+    total_timer_time DOUBLE PRECISION NOT NULL DEFAULT 0,
+    total_elapsed_time DOUBLE PRECISION NOT NULL DEFAULT 0,
+    total_distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    total_ascent INTEGER NOT NULL DEFAULT 0,
+    total_descent INTEGER NOT NULL DEFAULT 0,
+    total_calories INTEGER NOT NULL DEFAULT 0,
+
+    device_manufacturer TEXT NOT NULL DEFAULT '',
+    device_product TEXT NOT NULL DEFAULT '',
+    device_firmware TEXT NOT NULL DEFAULT '',
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
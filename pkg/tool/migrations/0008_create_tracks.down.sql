@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS track_points;
+DROP TABLE IF EXISTS tracks;
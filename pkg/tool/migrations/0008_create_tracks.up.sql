@@ -0,0 +1,37 @@
+SET search_path TO activities, public;
+
+CREATE TABLE IF NOT EXISTS tracks(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    -- source is either original or streams, digest is the original_digest or
+    -- data_digest of the activity the points were built from
+    source TEXT NOT NULL,
+    digest TEXT NOT NULL,
+
+    sport TEXT NOT NULL DEFAULT '',
+    point_count INTEGER NOT NULL DEFAULT 0,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS track_points(
+    activity_id TEXT NOT NULL REFERENCES tracks(activity_id) ON DELETE CASCADE,
+    -- point_offset is the index of the point within the track
+    point_offset INTEGER NOT NULL,
+
+    time TIMESTAMPTZ NOT NULL,
+
+    lat DOUBLE PRECISION,
+    lon DOUBLE PRECISION,
+    elevation DOUBLE PRECISION,
+    heart_rate DOUBLE PRECISION,
+    cadence DOUBLE PRECISION,
+    power DOUBLE PRECISION,
+    speed DOUBLE PRECISION,
+    distance DOUBLE PRECISION,
+    temperature DOUBLE PRECISION,
+
+    PRIMARY KEY (activity_id, point_offset)
+);
+
+CREATE INDEX IF NOT EXISTS track_points_time_idx ON track_points(time);
@@ -0,0 +1,3 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS routes;
@@ -0,0 +1,30 @@
+SET search_path TO activities, public;
+
+CREATE TABLE IF NOT EXISTS routes(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    -- data_digest of the activity the route was decoded from
+    data_digest TEXT NOT NULL,
+
+    polyline TEXT NOT NULL DEFAULT '',
+    summary_polyline TEXT NOT NULL DEFAULT '',
+
+    -- geometry is a GeoJSON LineString decoded from the polyline, or the
+    -- summary polyline when the full polyline is not present
+    geometry JSONB,
+    point_count INTEGER NOT NULL DEFAULT 0,
+
+    start_lat DOUBLE PRECISION,
+    start_lon DOUBLE PRECISION,
+    end_lat DOUBLE PRECISION,
+    end_lon DOUBLE PRECISION,
+
+    min_lat DOUBLE PRECISION,
+    min_lon DOUBLE PRECISION,
+    max_lat DOUBLE PRECISION,
+    max_lon DOUBLE PRECISION,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS routes_bbox_idx ON routes(min_lat, max_lat, min_lon, max_lon);
@@ -0,0 +1,18 @@
+SET search_path TO activities, public;
+
+-- route_cells holds the precision 7 geohash cells, around 150m square,
+-- which each activity's route passes through
+CREATE TABLE IF NOT EXISTS route_cells(
+    cell TEXT NOT NULL,
+    activity_id TEXT NOT NULL REFERENCES routes(activity_id) ON DELETE CASCADE,
+
+    PRIMARY KEY (cell, activity_id)
+);
+
+-- searches match cells by prefix when using coarser cells
+CREATE INDEX IF NOT EXISTS route_cells_prefix_idx ON route_cells(cell text_pattern_ops);
+CREATE INDEX IF NOT EXISTS route_cells_activity_id_idx ON route_cells(activity_id);
+
+-- clear the route digests so existing routes are indexed by the next run of
+-- the route geometry job
+UPDATE routes SET data_digest = '';
@@ -0,0 +1,12 @@
+SET search_path TO activities, public;
+
+-- route_maps records the route map images rendered and stored in the bucket
+-- for each activity
+CREATE TABLE IF NOT EXISTS route_maps(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES routes(activity_id) ON DELETE CASCADE,
+
+    -- data_digest of the route the maps were rendered from
+    data_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+SET search_path TO activities, public;
+
+ALTER TABLE route_maps DROP COLUMN IF EXISTS privacy_digest;
@@ -0,0 +1,5 @@
+SET search_path TO activities, public;
+
+-- privacy_digest identifies the privacy zones the maps were rendered with,
+-- maps are rendered again when the zones change
+ALTER TABLE route_maps ADD COLUMN IF NOT EXISTS privacy_digest TEXT NOT NULL DEFAULT '';
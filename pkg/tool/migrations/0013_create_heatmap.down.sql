@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS heatmap_tiles;
+DROP TABLE IF EXISTS heatmap_activities;
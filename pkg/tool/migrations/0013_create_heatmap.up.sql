@@ -0,0 +1,33 @@
+SET search_path TO activities, public;
+
+-- heatmap_activities records the version of each track drawn into the
+-- heatmap tiles, and the layers it was drawn into
+CREATE TABLE IF NOT EXISTS heatmap_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    -- digest of the track and the privacy zones the activity was indexed with
+    track_digest TEXT NOT NULL,
+    privacy_digest TEXT NOT NULL DEFAULT '',
+
+    type TEXT NOT NULL,
+    year INTEGER NOT NULL,
+
+    -- the range of point offsets left after trimming the start and end for
+    -- privacy, points in privacy zones inside the range are still hidden
+    visible_from INTEGER NOT NULL,
+    visible_to INTEGER NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- heatmap_tiles holds the zoom 14 tiles each activity passes through, tiles
+-- at lower zooms are found from the ranges of tiles they contain
+CREATE TABLE IF NOT EXISTS heatmap_tiles(
+    x INTEGER NOT NULL,
+    y INTEGER NOT NULL,
+    activity_id TEXT NOT NULL REFERENCES heatmap_activities(activity_id) ON DELETE CASCADE,
+
+    PRIMARY KEY (x, y, activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS heatmap_tiles_activity_id_idx ON heatmap_tiles(activity_id);
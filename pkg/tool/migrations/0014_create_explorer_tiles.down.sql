@@ -0,0 +1,5 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS explorer_tiles;
+DROP TABLE IF EXISTS explorer_activity_tiles;
+DROP TABLE IF EXISTS explorer_activities;
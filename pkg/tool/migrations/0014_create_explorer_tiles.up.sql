@@ -0,0 +1,35 @@
+SET search_path TO activities, public;
+
+-- explorer_activities records the version of each track the explorer tiles
+-- were found from
+CREATE TABLE IF NOT EXISTS explorer_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- explorer_activity_tiles holds the zoom 14 tiles visited by each activity
+CREATE TABLE IF NOT EXISTS explorer_activity_tiles(
+    x INTEGER NOT NULL,
+    y INTEGER NOT NULL,
+    activity_id TEXT NOT NULL REFERENCES explorer_activities(activity_id) ON DELETE CASCADE,
+
+    PRIMARY KEY (x, y, activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS explorer_activity_tiles_activity_id_idx ON explorer_activity_tiles(activity_id);
+
+-- explorer_tiles holds every zoom 14 tile visited and when it was first
+-- visited, it's rebuilt from explorer_activity_tiles on each run
+CREATE TABLE IF NOT EXISTS explorer_tiles(
+    x INTEGER NOT NULL,
+    y INTEGER NOT NULL,
+
+    first_visited_at TIMESTAMPTZ NOT NULL,
+    first_activity_id TEXT NOT NULL,
+    visit_count INTEGER NOT NULL,
+
+    PRIMARY KEY (x, y)
+);
@@ -0,0 +1,30 @@
+SET search_path TO activities, public;
+
+-- zone_activities records the version of each track and the zone config
+-- the times in zones were calculated from
+CREATE TABLE IF NOT EXISTS zone_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+    zones_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- activity_zones holds the time spent in each heart rate or power zone,
+-- the bounds are those which applied on the date of the activity
+CREATE TABLE IF NOT EXISTS activity_zones(
+    activity_id TEXT NOT NULL REFERENCES zone_activities(activity_id) ON DELETE CASCADE,
+    -- kind is heart_rate or power
+    kind TEXT NOT NULL,
+    -- zone starts at 1 for the lowest zone
+    zone INTEGER NOT NULL,
+
+    min_value DOUBLE PRECISION NOT NULL,
+    -- max_value is null for the highest zone
+    max_value DOUBLE PRECISION,
+
+    seconds DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (activity_id, kind, zone)
+);
@@ -0,0 +1,5 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS best_efforts;
+DROP TABLE IF EXISTS power_curves;
+DROP TABLE IF EXISTS effort_activities;
@@ -0,0 +1,37 @@
+SET search_path TO activities, public;
+
+-- effort_activities records the version of each track the power curve and
+-- best efforts were calculated from
+CREATE TABLE IF NOT EXISTS effort_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- power_curves holds the mean maximal power of each activity for a set of
+-- durations, durations longer than the activity are not included
+CREATE TABLE IF NOT EXISTS power_curves(
+    activity_id TEXT NOT NULL REFERENCES effort_activities(activity_id) ON DELETE CASCADE,
+    -- duration is in seconds
+    duration INTEGER NOT NULL,
+
+    watts DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (activity_id, duration)
+);
+
+-- best_efforts holds the fastest time over each standard distance in
+-- running activities
+CREATE TABLE IF NOT EXISTS best_efforts(
+    activity_id TEXT NOT NULL REFERENCES effort_activities(activity_id) ON DELETE CASCADE,
+    -- distance is in metres
+    distance DOUBLE PRECISION NOT NULL,
+    name TEXT NOT NULL,
+
+    start_time TIMESTAMPTZ NOT NULL,
+    seconds DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (activity_id, distance)
+);
@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS training_load_days;
+DROP TABLE IF EXISTS activity_loads;
@@ -0,0 +1,36 @@
+SET search_path TO activities, public;
+
+-- activity_loads holds the training stress of each activity and the
+-- versions of the track, activity and zone config it was calculated from
+CREATE TABLE IF NOT EXISTS activity_loads(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+    data_digest TEXT NOT NULL,
+    zones_digest TEXT NOT NULL,
+
+    -- date is the UTC date of the activity which the stress counts towards
+    date DATE NOT NULL,
+    -- method is power or trimp, or empty when neither could be used
+    method TEXT NOT NULL DEFAULT '',
+    stress DOUBLE PRECISION NOT NULL DEFAULT 0,
+
+    -- normalized_power and intensity are set for the power method
+    normalized_power DOUBLE PRECISION,
+    intensity DOUBLE PRECISION,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS activity_loads_date ON activity_loads(date);
+
+-- training_load_days holds the daily fitness (CTL), fatigue (ATL) and form
+-- (TSB) series from the first activity with stress until the last update
+CREATE TABLE IF NOT EXISTS training_load_days(
+    date DATE NOT NULL PRIMARY KEY,
+
+    stress DOUBLE PRECISION NOT NULL,
+    fitness DOUBLE PRECISION NOT NULL,
+    fatigue DOUBLE PRECISION NOT NULL,
+    form DOUBLE PRECISION NOT NULL
+);
@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS personal_records;
+DROP TABLE IF EXISTS activity_record_values;
+DROP TABLE IF EXISTS record_activities;
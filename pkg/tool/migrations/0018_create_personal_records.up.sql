@@ -0,0 +1,64 @@
+SET search_path TO activities, public;
+
+-- record_activities records the version of each track the record values
+-- were found from
+CREATE TABLE IF NOT EXISTS record_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- activity_record_values holds the values of each activity which are
+-- compared against later activities of the same sport
+CREATE TABLE IF NOT EXISTS activity_record_values(
+    activity_id TEXT NOT NULL REFERENCES record_activities(activity_id) ON DELETE CASCADE,
+
+    sport TEXT NOT NULL,
+    -- category is best_effort, power, distance, climb or average_speed
+    category TEXT NOT NULL,
+    -- key is the best effort name or power duration, empty otherwise
+    key TEXT NOT NULL DEFAULT '',
+
+    value DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (activity_id, sport, category, key)
+);
+
+-- personal_records holds the values which beat all earlier activities of
+-- the same sport at the time of the activity
+CREATE TABLE IF NOT EXISTS personal_records(
+    activity_id TEXT NOT NULL REFERENCES record_activities(activity_id) ON DELETE CASCADE,
+
+    sport TEXT NOT NULL,
+    category TEXT NOT NULL,
+    key TEXT NOT NULL DEFAULT '',
+
+    value DOUBLE PRECISION NOT NULL,
+    -- previous_value and previous_activity_id are null for the first value
+    previous_value DOUBLE PRECISION,
+    previous_activity_id TEXT,
+
+    achieved_at TIMESTAMPTZ NOT NULL,
+
+    PRIMARY KEY (activity_id, sport, category, key)
+);
+
+CREATE INDEX IF NOT EXISTS personal_records_achieved_at ON personal_records(achieved_at);
+
+-- events holds things which happened for notifiers to pick up, events are
+-- kept after delivery and when the activity is deleted
+CREATE TABLE IF NOT EXISTS events(
+    id BIGSERIAL PRIMARY KEY,
+
+    kind TEXT NOT NULL,
+    activity_id TEXT,
+    payload JSONB NOT NULL DEFAULT '{}',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- delivered_at is set once a notifier has sent the event
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS events_undelivered ON events(id) WHERE delivered_at IS NULL;
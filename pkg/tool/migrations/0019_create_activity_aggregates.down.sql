@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS activity_aggregates;
+DROP TABLE IF EXISTS activity_stats;
@@ -0,0 +1,45 @@
+SET search_path TO activities, public;
+
+-- activity_stats holds the totals of each activity from the Strava data
+-- archived by ActivitySync, data_digest is the version they were read from
+CREATE TABLE IF NOT EXISTS activity_stats(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    data_digest TEXT NOT NULL,
+
+    type TEXT NOT NULL DEFAULT '',
+    gear_id TEXT NOT NULL DEFAULT '',
+    -- start_date_local is the wall clock time where the activity started
+    start_date_local TIMESTAMP NOT NULL,
+
+    -- distance and elevation_gain are in metres, moving_time in seconds
+    distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    moving_time INTEGER NOT NULL DEFAULT 0,
+    elevation_gain DOUBLE PRECISION NOT NULL DEFAULT 0,
+    kilojoules DOUBLE PRECISION NOT NULL DEFAULT 0,
+    calories DOUBLE PRECISION NOT NULL DEFAULT 0,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS activity_stats_start_date_local ON activity_stats(start_date_local);
+
+-- activity_aggregates holds the totals of activity_stats for each week,
+-- month and year by the local start time, per type and gear
+CREATE TABLE IF NOT EXISTS activity_aggregates(
+    -- period is week, month or year, weeks start on Monday
+    period TEXT NOT NULL,
+    period_start DATE NOT NULL,
+
+    type TEXT NOT NULL,
+    gear_id TEXT NOT NULL,
+
+    count INTEGER NOT NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    moving_time BIGINT NOT NULL,
+    elevation_gain DOUBLE PRECISION NOT NULL,
+    kilojoules DOUBLE PRECISION NOT NULL,
+    calories DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (period, period_start, type, gear_id)
+);
@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+ALTER TABLE activities
+    DROP COLUMN start_date_local,
+    DROP COLUMN timezone,
+    DROP COLUMN utc_offset;
@@ -0,0 +1,9 @@
+SET search_path TO activities, public;
+
+-- start_date_local is the wall clock time where the activity started and
+-- timezone is the IANA name of the time zone, which is empty when derived
+-- from an original as only the offset from UTC is known
+ALTER TABLE activities
+    ADD COLUMN start_date_local TIMESTAMP,
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT '',
+    ADD COLUMN utc_offset INTEGER;
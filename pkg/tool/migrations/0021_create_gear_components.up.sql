@@ -0,0 +1,31 @@
+SET search_path TO activities, public;
+
+-- gear_components holds the use of each maintenance component in the gear
+-- config since it was installed, components are identified by gear, name
+-- and installation date so a replacement starts a new row
+CREATE TABLE IF NOT EXISTS gear_components(
+    gear_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    installed_on DATE NOT NULL,
+
+    -- intervals are null when not configured, distance is in metres and
+    -- time in seconds of moving time
+    interval_distance DOUBLE PRECISION,
+    interval_time DOUBLE PRECISION,
+    interval_days INTEGER,
+
+    activities INTEGER NOT NULL DEFAULT 0,
+    distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    moving_time DOUBLE PRECISION NOT NULL DEFAULT 0,
+
+    -- due_reasons is empty when the component is not due
+    due BOOLEAN NOT NULL DEFAULT FALSE,
+    due_reasons TEXT NOT NULL DEFAULT '',
+    -- notified_at is set when an event is emitted for the component being
+    -- due, and cleared if it is no longer due
+    notified_at TIMESTAMPTZ,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (gear_id, name, installed_on)
+);
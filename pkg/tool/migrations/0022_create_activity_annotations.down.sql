@@ -0,0 +1,5 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS activity_annotations;
+DROP TABLE IF EXISTS activity_notes;
+DROP TABLE IF EXISTS activity_tags;
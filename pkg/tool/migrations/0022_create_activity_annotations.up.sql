@@ -0,0 +1,41 @@
+SET search_path TO activities, public;
+
+-- activity_tags, activity_notes and activity_annotations are added by hand
+-- and are not touched when activities are synced from Strava
+
+-- activity_tags holds short labels such as race or injury, tags are lower
+-- case
+CREATE TABLE IF NOT EXISTS activity_tags(
+    activity_id TEXT NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (activity_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS activity_tags_tag ON activity_tags(tag);
+
+-- activity_notes holds free text notes, an activity can have many
+CREATE TABLE IF NOT EXISTS activity_notes(
+    id BIGSERIAL PRIMARY KEY,
+    activity_id TEXT NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+
+    body TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS activity_notes_activity_id ON activity_notes(activity_id);
+
+-- activity_annotations holds key value pairs such as saddle: new
+CREATE TABLE IF NOT EXISTS activity_annotations(
+    activity_id TEXT NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+
+    value TEXT NOT NULL DEFAULT '',
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (activity_id, key)
+);
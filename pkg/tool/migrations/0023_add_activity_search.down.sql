@@ -0,0 +1,16 @@
+SET search_path TO activities, public;
+
+DROP TRIGGER IF EXISTS activity_notes_search_vector ON activity_notes;
+DROP TRIGGER IF EXISTS activity_tags_search_vector ON activity_tags;
+DROP TRIGGER IF EXISTS activities_search_vector ON activities;
+
+DROP FUNCTION IF EXISTS activity_annotations_search_vector_trigger();
+DROP FUNCTION IF EXISTS activities_search_vector_trigger();
+DROP FUNCTION IF EXISTS activity_search_vector(TEXT, TEXT, TEXT);
+
+DROP INDEX IF EXISTS activities_search_vector;
+
+ALTER TABLE activities
+    DROP COLUMN search_vector,
+    DROP COLUMN description,
+    DROP COLUMN name;
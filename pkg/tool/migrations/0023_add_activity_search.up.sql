@@ -0,0 +1,60 @@
+SET search_path TO activities, public;
+
+-- name and description are copied from the Strava data when activities are
+-- synced so they can be searched
+ALTER TABLE activities
+    ADD COLUMN name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN search_vector TSVECTOR NOT NULL DEFAULT ''::TSVECTOR;
+
+CREATE INDEX IF NOT EXISTS activities_search_vector ON activities USING GIN(search_vector);
+
+-- activity_search_vector combines the name and tags, which are weighted
+-- highest, with the description and notes of an activity
+CREATE OR REPLACE FUNCTION activity_search_vector(activity_id TEXT, name TEXT, description TEXT)
+RETURNS TSVECTOR AS $$
+    SELECT
+        setweight(to_tsvector('english', name), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(tag, ' ') FROM activities.activity_tags t WHERE t.activity_id = $1),
+            ''
+        )), 'A') ||
+        setweight(to_tsvector('english', description), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(body, ' ') FROM activities.activity_notes n WHERE n.activity_id = $1),
+            ''
+        )), 'C')
+$$ LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE FUNCTION activities_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := activities.activity_search_vector(NEW.id, NEW.name, NEW.description);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER activities_search_vector
+    BEFORE INSERT OR UPDATE OF name, description ON activities
+    FOR EACH ROW EXECUTE FUNCTION activities_search_vector_trigger();
+
+-- tags and notes are changed independently of the activity so the vector
+-- is updated when they change
+CREATE OR REPLACE FUNCTION activity_annotations_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE activities.activities
+    SET search_vector = activities.activity_search_vector(id, name, description)
+    WHERE id = COALESCE(NEW.activity_id, OLD.activity_id);
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER activity_tags_search_vector
+    AFTER INSERT OR UPDATE OR DELETE ON activity_tags
+    FOR EACH ROW EXECUTE FUNCTION activity_annotations_search_vector_trigger();
+
+CREATE TRIGGER activity_notes_search_vector
+    AFTER INSERT OR UPDATE OR DELETE ON activity_notes
+    FOR EACH ROW EXECUTE FUNCTION activity_annotations_search_vector_trigger();
+
+-- existing activities have tags and notes but no name until backfilled
+UPDATE activities SET search_vector = activity_search_vector(id, name, description);
@@ -0,0 +1,11 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS duplicate_pairs;
+DROP TABLE IF EXISTS duplicate_candidates;
+
+ALTER TABLE activity_stats
+    DROP COLUMN counted;
+
+ALTER TABLE activities
+    DROP COLUMN not_duplicate,
+    DROP COLUMN duplicate_of;
@@ -0,0 +1,41 @@
+SET search_path TO activities, public;
+
+-- duplicate_of is set on activities which record the same workout as the
+-- canonical activity it references, they are not counted in totals.
+-- not_duplicate is set on activities which were wrongly detected as
+-- duplicates so they are left out of detection.
+ALTER TABLE activities
+    ADD COLUMN duplicate_of TEXT REFERENCES activities(id) ON DELETE SET NULL,
+    ADD COLUMN not_duplicate BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- counted is false when the stats were read from a duplicate activity
+ALTER TABLE activity_stats
+    ADD COLUMN counted BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- duplicate_candidates holds the activities found to record the same
+-- workout as another, group_id is the first activity ID in the group
+CREATE TABLE IF NOT EXISTS duplicate_candidates(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+    group_id TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS duplicate_candidates_group_id ON duplicate_candidates(group_id);
+
+-- duplicate_pairs holds the comparisons which placed activities in a group,
+-- overlap is the fraction of the longer activity's time shared with the
+-- other and similarity the fraction of route cells shared, which is null
+-- when either has no route
+CREATE TABLE IF NOT EXISTS duplicate_pairs(
+    activity_id TEXT NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    other_activity_id TEXT NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    group_id TEXT NOT NULL,
+
+    overlap DOUBLE PRECISION NOT NULL,
+    similarity DOUBLE PRECISION,
+
+    PRIMARY KEY (activity_id, other_activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS duplicate_pairs_group_id ON duplicate_pairs(group_id);
@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS route_cluster_activities;
+DROP TABLE IF EXISTS route_clusters;
@@ -0,0 +1,39 @@
+SET search_path TO activities, public;
+
+-- route_clusters holds each distinct route followed by activities, the
+-- geometry is the simplified route of the first activity in the cluster
+-- which later activities are compared with
+CREATE TABLE IF NOT EXISTS route_clusters(
+    id BIGSERIAL PRIMARY KEY,
+
+    type TEXT NOT NULL,
+
+    geometry JSONB NOT NULL,
+    -- length is in metres
+    length DOUBLE PRECISION NOT NULL,
+
+    start_lat DOUBLE PRECISION NOT NULL,
+    start_lon DOUBLE PRECISION NOT NULL,
+    end_lat DOUBLE PRECISION NOT NULL,
+    end_lon DOUBLE PRECISION NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS route_clusters_start ON route_clusters(type, start_lat, start_lon);
+
+-- route_cluster_activities records the cluster of each route, route_id is
+-- null when the route is too short to cluster. route_digest is the
+-- data_digest of the route which was clustered and distance is the
+-- Hausdorff distance in metres from the cluster's route.
+CREATE TABLE IF NOT EXISTS route_cluster_activities(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES routes(activity_id) ON DELETE CASCADE,
+    route_id BIGINT REFERENCES route_clusters(id) ON DELETE CASCADE,
+
+    route_digest TEXT NOT NULL,
+    distance DOUBLE PRECISION,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS route_cluster_activities_route_id ON route_cluster_activities(route_id);
@@ -0,0 +1,5 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS segment_efforts;
+DROP TABLE IF EXISTS segment_activities;
+DROP TABLE IF EXISTS segments;
@@ -0,0 +1,60 @@
+SET search_path TO activities, public;
+
+-- segments holds user defined sections of road or trail, the geometry is
+-- the simplified line tracks are matched against. activity_id is the
+-- activity the segment was taken from, if any.
+CREATE TABLE IF NOT EXISTS segments(
+    id BIGSERIAL PRIMARY KEY,
+
+    name TEXT NOT NULL,
+    activity_id TEXT REFERENCES activities(id) ON DELETE SET NULL,
+
+    geometry JSONB NOT NULL,
+    -- length is in metres
+    length DOUBLE PRECISION NOT NULL,
+
+    min_lat DOUBLE PRECISION NOT NULL,
+    min_lon DOUBLE PRECISION NOT NULL,
+    max_lat DOUBLE PRECISION NOT NULL,
+    max_lon DOUBLE PRECISION NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- segment_activities records the version of each track which was matched
+-- against each segment, so only new segments and changed tracks are matched
+CREATE TABLE IF NOT EXISTS segment_activities(
+    segment_id BIGINT NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    activity_id TEXT NOT NULL REFERENCES tracks(activity_id) ON DELETE CASCADE,
+
+    track_digest TEXT NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (segment_id, activity_id)
+);
+
+-- segment_efforts holds each pass along a segment, offsets are the
+-- point_offset of the first and last track points. elapsed_time is in
+-- seconds and distance in metres.
+CREATE TABLE IF NOT EXISTS segment_efforts(
+    id BIGSERIAL PRIMARY KEY,
+
+    segment_id BIGINT NOT NULL,
+    activity_id TEXT NOT NULL,
+
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+
+    elapsed_time DOUBLE PRECISION NOT NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    average_power DOUBLE PRECISION,
+    average_heart_rate DOUBLE PRECISION,
+
+    FOREIGN KEY (segment_id, activity_id)
+        REFERENCES segment_activities(segment_id, activity_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS segment_efforts_segment_id ON segment_efforts(segment_id, elapsed_time);
+CREATE INDEX IF NOT EXISTS segment_efforts_activity_id ON segment_efforts(activity_id);
@@ -0,0 +1,8 @@
+SET search_path TO activities, public;
+
+DROP TABLE IF EXISTS strava_best_efforts;
+DROP TABLE IF EXISTS strava_splits;
+DROP TABLE IF EXISTS strava_laps;
+DROP TABLE IF EXISTS strava_segment_efforts;
+DROP TABLE IF EXISTS strava_segments;
+DROP TABLE IF EXISTS strava_details;
@@ -0,0 +1,137 @@
+SET search_path TO activities, public;
+
+-- strava_details records the version of each activity's Strava data the
+-- rows below were taken from. Laps are not part of the archived data and
+-- are requested separately, laps_digest is the data version they were
+-- requested for.
+CREATE TABLE IF NOT EXISTS strava_details(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    data_digest TEXT NOT NULL,
+    laps_digest TEXT,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- strava_segments holds the Strava segments which efforts were made on, as
+-- last seen in an activity
+CREATE TABLE IF NOT EXISTS strava_segments(
+    id BIGINT PRIMARY KEY,
+
+    name TEXT NOT NULL,
+    activity_type TEXT NOT NULL,
+    -- distance and elevations are in metres, grades are percentages
+    distance DOUBLE PRECISION NOT NULL,
+    average_grade DOUBLE PRECISION NOT NULL,
+    maximum_grade DOUBLE PRECISION NOT NULL,
+    elevation_high DOUBLE PRECISION NOT NULL,
+    elevation_low DOUBLE PRECISION NOT NULL,
+    climb_category INTEGER NOT NULL,
+
+    start_lat DOUBLE PRECISION,
+    start_lon DOUBLE PRECISION,
+    end_lat DOUBLE PRECISION,
+    end_lon DOUBLE PRECISION,
+
+    city TEXT NOT NULL,
+    state TEXT NOT NULL,
+    country TEXT NOT NULL,
+    private BOOLEAN NOT NULL,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- strava_segment_efforts holds the segment efforts in each activity. Times
+-- are in seconds and distances in metres, indexes are into the activity's
+-- Strava streams. Values Strava did not return are NULL.
+CREATE TABLE IF NOT EXISTS strava_segment_efforts(
+    id BIGINT PRIMARY KEY,
+
+    activity_id TEXT NOT NULL REFERENCES strava_details(activity_id) ON DELETE CASCADE,
+    segment_id BIGINT NOT NULL REFERENCES strava_segments(id),
+
+    name TEXT NOT NULL,
+    start_date TIMESTAMPTZ NOT NULL,
+    start_date_local TIMESTAMP NOT NULL,
+    elapsed_time INTEGER NOT NULL,
+    moving_time INTEGER NOT NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    start_index INTEGER NOT NULL,
+    end_index INTEGER NOT NULL,
+
+    average_cadence DOUBLE PRECISION,
+    average_power DOUBLE PRECISION,
+    average_heart_rate DOUBLE PRECISION,
+    max_heart_rate DOUBLE PRECISION,
+
+    kom_rank INTEGER,
+    pr_rank INTEGER,
+    hidden BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS strava_segment_efforts_segment_id ON strava_segment_efforts(segment_id, elapsed_time);
+CREATE INDEX IF NOT EXISTS strava_segment_efforts_activity_id ON strava_segment_efforts(activity_id);
+
+-- strava_laps holds the laps of each activity, speeds are in metres per
+-- second
+CREATE TABLE IF NOT EXISTS strava_laps(
+    id BIGINT PRIMARY KEY,
+
+    activity_id TEXT NOT NULL REFERENCES strava_details(activity_id) ON DELETE CASCADE,
+    lap_index INTEGER NOT NULL,
+
+    name TEXT NOT NULL,
+    start_date TIMESTAMPTZ NOT NULL,
+    start_date_local TIMESTAMP NOT NULL,
+    elapsed_time INTEGER NOT NULL,
+    moving_time INTEGER NOT NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    start_index INTEGER NOT NULL,
+    end_index INTEGER NOT NULL,
+    total_elevation_gain DOUBLE PRECISION NOT NULL,
+
+    average_speed DOUBLE PRECISION,
+    max_speed DOUBLE PRECISION,
+    average_cadence DOUBLE PRECISION,
+    average_power DOUBLE PRECISION,
+    average_heart_rate DOUBLE PRECISION,
+    max_heart_rate DOUBLE PRECISION
+);
+
+CREATE INDEX IF NOT EXISTS strava_laps_activity_id ON strava_laps(activity_id, lap_index);
+
+-- strava_splits holds the per kilometre (metric) and per mile (standard)
+-- splits of each activity
+CREATE TABLE IF NOT EXISTS strava_splits(
+    activity_id TEXT NOT NULL REFERENCES strava_details(activity_id) ON DELETE CASCADE,
+    units TEXT NOT NULL,
+    split INTEGER NOT NULL,
+
+    distance DOUBLE PRECISION NOT NULL,
+    elapsed_time INTEGER NOT NULL,
+    moving_time INTEGER NOT NULL,
+    elevation_difference DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (activity_id, units, split)
+);
+
+-- strava_best_efforts holds Strava's fastest times over standard distances
+-- in running activities, best_efforts holds those calculated from tracks
+CREATE TABLE IF NOT EXISTS strava_best_efforts(
+    id BIGINT PRIMARY KEY,
+
+    activity_id TEXT NOT NULL REFERENCES strava_details(activity_id) ON DELETE CASCADE,
+
+    name TEXT NOT NULL,
+    start_date TIMESTAMPTZ NOT NULL,
+    start_date_local TIMESTAMP NOT NULL,
+    elapsed_time INTEGER NOT NULL,
+    moving_time INTEGER NOT NULL,
+    distance DOUBLE PRECISION NOT NULL,
+    start_index INTEGER NOT NULL,
+    end_index INTEGER NOT NULL,
+
+    pr_rank INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS strava_best_efforts_activity_id ON strava_best_efforts(activity_id);
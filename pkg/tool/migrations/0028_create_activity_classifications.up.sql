@@ -0,0 +1,22 @@
+SET search_path TO activities, public;
+
+-- activity_classifications holds whether each activity is a commute, indoor
+-- or virtual under the configured rules. input_digest identifies the route,
+-- original and rules the activity was classified from, so it is classified
+-- again when any change. reason explains the rule which matched.
+CREATE TABLE IF NOT EXISTS activity_classifications(
+    activity_id TEXT NOT NULL PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+
+    input_digest TEXT NOT NULL,
+
+    commute BOOLEAN NOT NULL,
+    indoor BOOLEAN NOT NULL,
+    virtual BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+
+    -- strava_commute_set is true once the commute flag has been set on
+    -- Strava, so it is only written once
+    strava_commute_set BOOLEAN NOT NULL DEFAULT FALSE,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+ALTER TABLE record_activities
+    DROP COLUMN counted;
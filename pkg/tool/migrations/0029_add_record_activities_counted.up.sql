@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+-- counted is false when the activity is a duplicate, its values are not
+-- compared for records
+ALTER TABLE record_activities
+    ADD COLUMN counted BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,13 @@
+SET search_path TO activities, public;
+
+-- heatmap_dirty_tiles holds the tiles and layers which must be drawn again
+-- after the index changed, rows are removed once the tile is drawn so tiles
+-- left over from a failed run are drawn on the next
+CREATE TABLE IF NOT EXISTS heatmap_dirty_tiles(
+    z INTEGER NOT NULL,
+    x INTEGER NOT NULL,
+    y INTEGER NOT NULL,
+    layer TEXT NOT NULL,
+
+    PRIMARY KEY (z, x, y, layer)
+);
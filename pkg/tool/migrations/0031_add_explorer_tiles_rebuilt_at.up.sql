@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+-- rebuilt_at is when explorer_tiles was last rebuilt, it's rebuilt again
+-- when any activity's tiles were stored after then
+ALTER TABLE explorer_tiles
+    ADD COLUMN rebuilt_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
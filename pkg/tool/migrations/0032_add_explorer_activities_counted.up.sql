@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+-- counted is false when the activity is a duplicate, its tiles are not
+-- counted as visits
+ALTER TABLE explorer_activities
+    ADD COLUMN counted BOOLEAN NOT NULL DEFAULT TRUE;
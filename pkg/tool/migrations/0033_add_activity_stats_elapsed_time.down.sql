@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+ALTER TABLE activity_stats
+    DROP COLUMN elapsed_time;
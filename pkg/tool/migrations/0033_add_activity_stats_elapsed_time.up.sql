@@ -0,0 +1,8 @@
+SET search_path TO activities, public;
+
+-- elapsed_time is in seconds, including time stopped. The data_digest is
+-- cleared so the stats are read again with it.
+ALTER TABLE activity_stats
+    ADD COLUMN elapsed_time INTEGER NOT NULL DEFAULT 0;
+
+UPDATE activity_stats SET data_digest = '';
@@ -0,0 +1,4 @@
+SET search_path TO activities, public;
+
+ALTER TABLE original_summaries
+    DROP COLUMN decode_error;
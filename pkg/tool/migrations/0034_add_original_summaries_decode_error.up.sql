@@ -0,0 +1,6 @@
+SET search_path TO activities, public;
+
+-- decode_error is set when the original could not be decoded, the summary
+-- is left empty
+ALTER TABLE original_summaries
+    ADD COLUMN decode_error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,106 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Groupings of aggregates, the totals for each period are split by type,
+// by gear, by both or not at all
+const (
+	GroupByType     = "type"
+	GroupByGear     = "gear"
+	GroupByTypeGear = "type_gear"
+	GroupByTotal    = "total"
+)
+
+// Aggregate is the total of the activities in a period
+type Aggregate struct {
+	PeriodStart   time.Time `db:"period_start" json:"period_start"`
+	Type          string    `db:"type" json:"type,omitempty"`
+	GearID        string    `db:"gear_id" json:"gear_id,omitempty"`
+	Count         int       `db:"count" json:"count"`
+	Distance      float64   `db:"distance" json:"distance"`
+	MovingTime    int64     `db:"moving_time" json:"moving_time"`
+	ElevationGain float64   `db:"elevation_gain" json:"elevation_gain"`
+	Kilojoules    float64   `db:"kilojoules" json:"kilojoules"`
+	Calories      float64   `db:"calories" json:"calories"`
+}
+
+// AggregateFilter selects the aggregates to return
+type AggregateFilter struct {
+	Period string
+	// From and To limit the period starts, To is exclusive
+	From time.Time
+	To   time.Time
+	// Type and GearID limit the aggregates to one type or gear when set
+	Type   string
+	GearID string
+	// GroupBy is one of the groupings
+	GroupBy string
+}
+
+// ValidAggregateGrouping returns true for the known groupings
+func ValidAggregateGrouping(groupBy string) bool {
+	switch groupBy {
+	case GroupByType, GroupByGear, GroupByTypeGear, GroupByTotal:
+		return true
+	}
+	return false
+}
+
+// Aggregates returns the totals for each period in the filter, ordered by
+// period start
+func Aggregates(ctx context.Context, db *sql.DB, filter AggregateFilter) ([]Aggregate, error) {
+	groups := []any{goqu.C("period_start")}
+	cols := []any{goqu.C("period_start")}
+
+	if filter.GroupBy == GroupByType || filter.GroupBy == GroupByTypeGear {
+		groups = append(groups, goqu.C("type"))
+		cols = append(cols, goqu.C("type"))
+	}
+	if filter.GroupBy == GroupByGear || filter.GroupBy == GroupByTypeGear {
+		groups = append(groups, goqu.C("gear_id"))
+		cols = append(cols, goqu.C("gear_id"))
+	}
+
+	cols = append(
+		cols,
+		goqu.SUM("count").As("count"),
+		goqu.SUM("distance").As("distance"),
+		goqu.SUM("moving_time").As("moving_time"),
+		goqu.SUM("elevation_gain").As("elevation_gain"),
+		goqu.SUM("kilojoules").As("kilojoules"),
+		goqu.SUM("calories").As("calories"),
+	)
+
+	query := goqu.New("postgres", db).
+		Select(cols...).
+		From("activities.activity_aggregates").
+		Where(
+			goqu.C("period").Eq(filter.Period),
+			goqu.C("period_start").Gte(filter.From.Format("2006-01-02")),
+			goqu.C("period_start").Lt(filter.To.Format("2006-01-02")),
+		).
+		GroupBy(groups...).
+		Order(goqu.C("period_start").Asc())
+
+	if filter.Type != "" {
+		query = query.Where(goqu.C("type").Eq(filter.Type))
+	}
+	if filter.GearID != "" {
+		query = query.Where(goqu.C("gear_id").Eq(filter.GearID))
+	}
+
+	aggregates := []Aggregate{}
+	err := query.ScanStructsContext(ctx, &aggregates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select %s aggregates: %w", filter.Period, err)
+	}
+
+	return aggregates, nil
+}
@@ -0,0 +1,234 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// Note is a free text note on an activity
+type Note struct {
+	ID        int64     `db:"id" json:"id"`
+	Body      string    `db:"body" json:"body"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Annotations are the tags, notes and key value annotations of an activity
+type Annotations struct {
+	Tags        []string          `json:"tags"`
+	Notes       []Note            `json:"notes"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// TagCount is the number of activities with a tag
+type TagCount struct {
+	Tag   string `db:"tag" json:"tag"`
+	Count int    `db:"count" json:"count"`
+}
+
+// ActivitySummary is an activity in a list of activities
+type ActivitySummary struct {
+	ID             string     `db:"id" json:"id"`
+	Name           string     `db:"name" json:"name"`
+	Type           string     `db:"type" json:"type"`
+	GearID         string     `db:"gear_id" json:"gear_id,omitempty"`
+	Timestamp      time.Time  `db:"timestamp" json:"timestamp"`
+	StartDateLocal *time.Time `db:"start_date_local" json:"start_date_local,omitempty"`
+	Timezone       string     `db:"timezone" json:"timezone,omitempty"`
+	RawTags        string     `db:"tags" json:"-"`
+	Tags           []string   `db:"-" json:"tags"`
+	Commute        bool       `db:"commute" json:"commute"`
+	Indoor         bool       `db:"indoor" json:"indoor"`
+	Virtual        bool       `db:"virtual" json:"virtual"`
+}
+
+// ActivityClasses are the values of ActivityFilter.Class, training is any
+// activity which is not a commute
+var ActivityClasses = []string{"commute", "training", "indoor", "virtual"}
+
+// ActivityFilter selects activities to list
+type ActivityFilter struct {
+	// Query is a web search style full text query over the name,
+	// description, tags and notes, such as "puncture wales -commute"
+	Query string
+	// Tags limits the list to activities with all the tags
+	Tags []string
+	Type string
+	// From and To limit the start time, To is exclusive
+	From time.Time
+	To   time.Time
+	// AnnotationKey limits the list to activities with the annotation, and
+	// AnnotationValue to those where it has the value when set
+	AnnotationKey   string
+	AnnotationValue string
+	// Class limits the list to one of ActivityClasses
+	Class string
+
+	Limit  uint
+	Offset uint
+}
+
+// ActivityAnnotations returns the tags, notes and annotations of an
+// activity
+func ActivityAnnotations(ctx context.Context, db *sql.DB, id string) (Annotations, error) {
+	goquDB := goqu.New("postgres", db)
+
+	annotations := Annotations{
+		Tags:        []string{},
+		Notes:       []Note{},
+		Annotations: map[string]string{},
+	}
+
+	err := goquDB.Select("tag").
+		From("activities.activity_tags").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("tag").Asc()).
+		ScanValsContext(ctx, &annotations.Tags)
+	if err != nil {
+		return Annotations{}, fmt.Errorf("failed to select tags for %s: %w", id, err)
+	}
+
+	err = goquDB.Select("id", "body", "created_at").
+		From("activities.activity_notes").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("created_at").Asc(), goqu.C("id").Asc()).
+		ScanStructsContext(ctx, &annotations.Notes)
+	if err != nil {
+		return Annotations{}, fmt.Errorf("failed to select notes for %s: %w", id, err)
+	}
+
+	var rows []struct {
+		Key   string `db:"key"`
+		Value string `db:"value"`
+	}
+	err = goquDB.Select("key", "value").
+		From("activities.activity_annotations").
+		Where(goqu.C("activity_id").Eq(id)).
+		ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return Annotations{}, fmt.Errorf("failed to select annotations for %s: %w", id, err)
+	}
+	for _, r := range rows {
+		annotations.Annotations[r.Key] = r.Value
+	}
+
+	return annotations, nil
+}
+
+// TagCounts returns each tag in use with the number of activities it is on
+func TagCounts(ctx context.Context, db *sql.DB) ([]TagCount, error) {
+	counts := []TagCount{}
+	err := goqu.New("postgres", db).
+		Select("tag", goqu.COUNT("*").As("count")).
+		From("activities.activity_tags").
+		GroupBy("tag").
+		Order(goqu.C("tag").Asc()).
+		ScanStructsContext(ctx, &counts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tag counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// ListActivities returns the activities matching the filter with their
+// tags, most recent first or best match first when there is a query
+func ListActivities(ctx context.Context, db *sql.DB, filter ActivityFilter) ([]ActivitySummary, error) {
+	tags := goqu.From(goqu.T("activity_tags").Schema("activities").As("t")).
+		Select(goqu.L("string_agg(t.tag, ',' ORDER BY t.tag)")).
+		Where(goqu.I("t.activity_id").Eq(goqu.I("a.id")))
+
+	query := goqu.New("postgres", db).
+		Select(
+			goqu.I("a.id"),
+			goqu.I("a.name"),
+			goqu.I("a.type"),
+			goqu.I("a.gear_id"),
+			goqu.I("a.timestamp"),
+			goqu.I("a.start_date_local"),
+			goqu.I("a.timezone"),
+			goqu.COALESCE(tags, "").As("tags"),
+			goqu.COALESCE(goqu.I("cl.commute"), false).As("commute"),
+			goqu.COALESCE(goqu.I("cl.indoor"), false).As("indoor"),
+			goqu.COALESCE(goqu.I("cl.virtual"), false).As("virtual"),
+		).
+		From(goqu.T("activities").Schema("activities").As("a")).
+		LeftJoin(
+			goqu.T("activity_classifications").Schema("activities").As("cl"),
+			goqu.On(goqu.I("cl.activity_id").Eq(goqu.I("a.id"))),
+		).
+		Where(
+			goqu.I("a.timestamp").Gte(filter.From),
+			goqu.I("a.timestamp").Lt(filter.To),
+		).
+		Limit(filter.Limit).
+		Offset(filter.Offset)
+
+	if filter.Query != "" {
+		tsQuery := goqu.L("websearch_to_tsquery('english', ?)", filter.Query)
+		query = query.
+			Where(goqu.L("a.search_vector @@ ?", tsQuery)).
+			Order(goqu.L("ts_rank(a.search_vector, ?)", tsQuery).Desc())
+	}
+	query = query.OrderAppend(goqu.I("a.timestamp").Desc(), goqu.I("a.id").Desc())
+
+	if filter.Type != "" {
+		query = query.Where(goqu.I("a.type").Eq(filter.Type))
+	}
+
+	for _, tag := range filter.Tags {
+		query = query.Where(goqu.L("EXISTS ?", goqu.From("activities.activity_tags").
+			Select(goqu.L("1")).
+			Where(
+				goqu.C("activity_id").Eq(goqu.I("a.id")),
+				goqu.C("tag").Eq(tag),
+			),
+		))
+	}
+
+	if filter.AnnotationKey != "" {
+		conditions := []exp.Expression{
+			goqu.C("activity_id").Eq(goqu.I("a.id")),
+			goqu.C("key").Eq(filter.AnnotationKey),
+		}
+		if filter.AnnotationValue != "" {
+			conditions = append(conditions, goqu.C("value").Eq(filter.AnnotationValue))
+		}
+
+		query = query.Where(goqu.L("EXISTS ?", goqu.From("activities.activity_annotations").
+			Select(goqu.L("1")).
+			Where(conditions...),
+		))
+	}
+
+	switch filter.Class {
+	case "commute":
+		query = query.Where(goqu.I("cl.commute").IsTrue())
+	case "training":
+		query = query.Where(goqu.I("cl.commute").IsNotTrue())
+	case "indoor":
+		query = query.Where(goqu.I("cl.indoor").IsTrue())
+	case "virtual":
+		query = query.Where(goqu.I("cl.virtual").IsTrue())
+	}
+
+	activities := []ActivitySummary{}
+	err := query.ScanStructsContext(ctx, &activities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activities: %w", err)
+	}
+
+	for i := range activities {
+		activities[i].Tags = []string{}
+		if activities[i].RawTags != "" {
+			activities[i].Tags = strings.Split(activities[i].RawTags, ",")
+		}
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,219 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geohash"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+)
+
+// RouteCellPrecision is the geohash precision of the cells stored in
+// route_cells
+const RouteCellPrecision = 7
+
+// maxSearchCells is the most cell prefixes used in a single search, larger
+// areas are searched with coarser cells
+const maxSearchCells = 64
+
+// AreaMatch is an activity found by an area search
+type AreaMatch struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// Distance is the closest the route came to the search point in metres,
+	// it's zero for bounding box searches
+	Distance float64 `json:"distance"`
+}
+
+// ActivitiesNear returns the activities which passed within radius metres of
+// the point, most recent first. Routes are measured with the parts hidden
+// by the privacy policy removed, so searches do not reveal them.
+func ActivitiesNear(ctx context.Context, db *sql.DB, point geo.Point, radius float64, policy *privacy.Policy) ([]AreaMatch, error) {
+	box := geo.BBoxAround(point, radius)
+
+	candidates, err := routesInCells(ctx, db, box)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []AreaMatch
+	for _, c := range candidates {
+		distance := math.Inf(1)
+		for _, segment := range policy.Line(c.line, c.match.ID) {
+			distance = math.Min(distance, geo.DistanceToLine(point, segment))
+		}
+		if distance > radius {
+			continue
+		}
+		c.match.Distance = distance
+		matches = append(matches, c.match)
+	}
+
+	sortMatches(matches)
+
+	return matches, nil
+}
+
+// ActivitiesInBBox returns the activities whose route intersects the
+// bounding box, most recent first. As with ActivitiesNear the parts of
+// routes hidden by the privacy policy are not searched.
+func ActivitiesInBBox(ctx context.Context, db *sql.DB, box geo.BBox, policy *privacy.Policy) ([]AreaMatch, error) {
+	candidates, err := routesInCells(ctx, db, box)
+	if err != nil {
+		return nil, err
+	}
+
+	cellLat, _ := geohash.CellSize(RouteCellPrecision)
+	step := cellLat / 2 * geo.EarthRadius * (math.Pi / 180)
+
+	var matches []AreaMatch
+	for _, c := range candidates {
+		for _, segment := range policy.Line(c.line, c.match.ID) {
+			if lineIntersects(segment, box, step) {
+				matches = append(matches, c.match)
+				break
+			}
+		}
+	}
+
+	sortMatches(matches)
+
+	return matches, nil
+}
+
+type routeCandidate struct {
+	match AreaMatch
+	line  []geo.Point
+}
+
+// routesInCells loads the routes passing through the cells covering the
+// bounding box. Cells are matched by prefix so the precision is lowered
+// until the box is covered by a reasonable number of cells.
+func routesInCells(ctx context.Context, db *sql.DB, box geo.BBox) ([]routeCandidate, error) {
+	precision := RouteCellPrecision
+	for precision > 1 && geohash.CoverCount(box, precision) > maxSearchCells {
+		precision--
+	}
+
+	var cellMatches []exp.Expression
+	for _, cell := range geohash.CoverBBox(box, precision) {
+		if precision == RouteCellPrecision {
+			cellMatches = append(cellMatches, goqu.C("cell").Eq(cell))
+		} else {
+			cellMatches = append(cellMatches, goqu.C("cell").Like(cell+"%"))
+		}
+	}
+
+	goquDB := goqu.New("postgres", db)
+
+	activityIDs := goquDB.From("activities.route_cells").
+		Select("activity_id").
+		Distinct().
+		Where(goqu.Or(cellMatches...))
+
+	query := goquDB.Select(
+		goqu.I("a.id"),
+		goqu.I("a.type"),
+		goqu.I("a.timestamp"),
+		goqu.I("r.geometry"),
+	).
+		From(goqu.T("routes").Schema("activities").As("r")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("r.activity_id"))),
+		).
+		Where(
+			goqu.I("r.activity_id").In(activityIDs),
+			// the bounding box excludes most routes passing near the area
+			goqu.I("r.min_lat").Lte(box.MaxLat),
+			goqu.I("r.max_lat").Gte(box.MinLat),
+			goqu.I("r.min_lon").Lte(box.MaxLon),
+			goqu.I("r.max_lon").Gte(box.MinLon),
+		)
+
+	var rows []struct {
+		ID        string    `db:"id"`
+		Type      string    `db:"type"`
+		Timestamp time.Time `db:"timestamp"`
+		Geometry  []byte    `db:"geometry"`
+	}
+	err := query.Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select routes: %w", err)
+	}
+
+	var candidates []routeCandidate
+	for _, row := range rows {
+		line, err := ParseLineString(row.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse geometry for %s: %w", row.ID, err)
+		}
+
+		candidates = append(candidates, routeCandidate{
+			match: AreaMatch{
+				ID:        row.ID,
+				Type:      row.Type,
+				Timestamp: row.Timestamp,
+			},
+			line: line,
+		})
+	}
+
+	return candidates, nil
+}
+
+// ParseLineString parses a GeoJSON LineString geometry as stored in routes
+func ParseLineString(data []byte) ([]geo.Point, error) {
+	var geometry struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	err := json.Unmarshal(data, &geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var line []geo.Point
+	for _, c := range geometry.Coordinates {
+		if len(c) < 2 {
+			continue
+		}
+		line = append(line, geo.Point{Lat: c[1], Lon: c[0]})
+	}
+
+	return line, nil
+}
+
+// lineIntersects checks if any part of the line falls in the box, segments
+// are interpolated at step metres so crossings between points are found
+func lineIntersects(line []geo.Point, box geo.BBox, step float64) bool {
+	for i, p := range line {
+		if box.Contains(p) {
+			return true
+		}
+		if i == 0 {
+			continue
+		}
+		for _, q := range geo.Interpolate(line[i-1], p, step) {
+			if box.Contains(q) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortMatches(matches []AreaMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+}
@@ -0,0 +1,115 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// DuplicateActivity is an activity in a group of duplicates
+type DuplicateActivity struct {
+	ID          string    `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Type        string    `db:"type" json:"type"`
+	Timestamp   time.Time `db:"timestamp" json:"timestamp"`
+	MovingTime  int64     `db:"moving_time" json:"moving_time"`
+	Distance    float64   `db:"distance" json:"distance"`
+	DuplicateOf *string   `db:"duplicate_of" json:"duplicate_of"`
+	GroupID     string    `db:"group_id" json:"-"`
+}
+
+// DuplicatePair is the comparison of two activities in a group
+type DuplicatePair struct {
+	ActivityID      string   `db:"activity_id" json:"activity_id"`
+	OtherActivityID string   `db:"other_activity_id" json:"other_activity_id"`
+	Overlap         float64  `db:"overlap" json:"overlap"`
+	Similarity      *float64 `db:"similarity" json:"similarity"`
+	GroupID         string   `db:"group_id" json:"-"`
+}
+
+// DuplicateGroup is a group of activities recording the same workout,
+// Canonical is the activity the others are marked as duplicates of, if one
+// has been chosen
+type DuplicateGroup struct {
+	ID         string              `json:"id"`
+	Canonical  *string             `json:"canonical"`
+	Activities []DuplicateActivity `json:"activities"`
+	Pairs      []DuplicatePair     `json:"pairs"`
+}
+
+// DuplicateGroups returns the groups of duplicate activities, most recent
+// first
+func DuplicateGroups(ctx context.Context, db *sql.DB) ([]DuplicateGroup, error) {
+	goquDB := goqu.New("postgres", db)
+
+	var activities []DuplicateActivity
+	err := goquDB.Select(
+		goqu.I("a.id"),
+		goqu.I("a.name"),
+		goqu.I("a.type"),
+		goqu.I("a.timestamp"),
+		goqu.I("s.moving_time"),
+		goqu.I("s.distance"),
+		goqu.I("a.duplicate_of"),
+		goqu.I("c.group_id"),
+	).
+		From(goqu.T("duplicate_candidates").Schema("activities").As("c")).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("c.activity_id"))),
+		).
+		InnerJoin(
+			goqu.T("activity_stats").Schema("activities").As("s"),
+			goqu.On(goqu.I("s.activity_id").Eq(goqu.I("c.activity_id"))),
+		).
+		Order(goqu.I("a.timestamp").Desc(), goqu.I("a.id").Asc()).
+		ScanStructsContext(ctx, &activities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select duplicate activities: %w", err)
+	}
+
+	var pairs []DuplicatePair
+	err = goquDB.Select("activity_id", "other_activity_id", "overlap", "similarity", "group_id").
+		From("activities.duplicate_pairs").
+		Order(goqu.C("activity_id").Asc(), goqu.C("other_activity_id").Asc()).
+		ScanStructsContext(ctx, &pairs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select duplicate pairs: %w", err)
+	}
+
+	groups := []DuplicateGroup{}
+	index := make(map[string]int)
+	for _, a := range activities {
+		i, ok := index[a.GroupID]
+		if !ok {
+			i = len(groups)
+			index[a.GroupID] = i
+			groups = append(groups, DuplicateGroup{ID: a.GroupID})
+		}
+
+		groups[i].Activities = append(groups[i].Activities, a)
+	}
+	for _, p := range pairs {
+		if i, ok := index[p.GroupID]; ok {
+			groups[i].Pairs = append(groups[i].Pairs, p)
+		}
+	}
+
+	for i, g := range groups {
+		members := make(map[string]bool)
+		for _, a := range g.Activities {
+			members[a.ID] = true
+		}
+		for _, a := range g.Activities {
+			if a.DuplicateOf != nil && members[*a.DuplicateOf] {
+				groups[i].Canonical = a.DuplicateOf
+				break
+			}
+		}
+	}
+
+	return groups, nil
+}
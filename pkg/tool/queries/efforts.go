@@ -0,0 +1,180 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// PowerCurvePoint is the mean maximal power over a duration
+type PowerCurvePoint struct {
+	Season     int       `db:"season" json:"-"`
+	Duration   int       `db:"duration" json:"duration"`
+	Watts      float64   `db:"watts" json:"watts"`
+	ActivityID string    `db:"activity_id" json:"activity_id"`
+	Timestamp  time.Time `db:"timestamp" json:"timestamp"`
+}
+
+// BestEffort is the fastest time over a distance
+type BestEffort struct {
+	Season     int       `db:"season" json:"-"`
+	Distance   float64   `db:"distance" json:"distance"`
+	Name       string    `db:"name" json:"name"`
+	Seconds    float64   `db:"seconds" json:"seconds"`
+	StartTime  time.Time `db:"start_time" json:"start_time"`
+	ActivityID string    `db:"activity_id" json:"activity_id"`
+	Timestamp  time.Time `db:"timestamp" json:"timestamp"`
+}
+
+// ActivityPowerCurve returns the power curve of an activity, ordered by
+// duration
+func ActivityPowerCurve(ctx context.Context, db *sql.DB, id string) ([]PowerCurvePoint, error) {
+	curve := []PowerCurvePoint{}
+	err := powerCurveQuery(db, goqu.L("0")).
+		Where(goqu.I("p.activity_id").Eq(id)).
+		Order(goqu.I("p.duration").Asc()).
+		Executor().ScanStructsContext(ctx, &curve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select power curve for %s: %w", id, err)
+	}
+
+	return curve, nil
+}
+
+// PowerCurveBests returns the highest power for each duration over the
+// counted activities between from and to, ordered by duration
+func PowerCurveBests(ctx context.Context, db *sql.DB, from, to time.Time) ([]PowerCurvePoint, error) {
+	curve := []PowerCurvePoint{}
+	err := powerCurveQuery(db, goqu.L("0")).
+		Distinct(goqu.I("p.duration")).
+		Where(
+			goqu.I("a.timestamp").Gte(from),
+			goqu.I("a.timestamp").Lt(to),
+			goqu.I("a.duplicate_of").IsNull(),
+		).
+		Order(goqu.I("p.duration").Asc(), goqu.I("p.watts").Desc()).
+		Executor().ScanStructsContext(ctx, &curve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select power curve bests: %w", err)
+	}
+
+	return curve, nil
+}
+
+// PowerCurveSeasonBests returns the highest power for each duration in each
+// calendar year from counted activities, keyed by year
+func PowerCurveSeasonBests(ctx context.Context, db *sql.DB) (map[int][]PowerCurvePoint, error) {
+	var rows []PowerCurvePoint
+	err := powerCurveQuery(db, seasonExpression).
+		Distinct(seasonExpression, goqu.I("p.duration")).
+		Where(goqu.I("a.duplicate_of").IsNull()).
+		Order(seasonExpression.Asc(), goqu.I("p.duration").Asc(), goqu.I("p.watts").Desc()).
+		Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select power curve season bests: %w", err)
+	}
+
+	seasons := make(map[int][]PowerCurvePoint)
+	for _, r := range rows {
+		seasons[r.Season] = append(seasons[r.Season], r)
+	}
+
+	return seasons, nil
+}
+
+// ActivityBestEfforts returns the best efforts in an activity, ordered by
+// distance
+func ActivityBestEfforts(ctx context.Context, db *sql.DB, id string) ([]BestEffort, error) {
+	bestEfforts := []BestEffort{}
+	err := bestEffortsQuery(db, goqu.L("0")).
+		Where(goqu.I("b.activity_id").Eq(id)).
+		Order(goqu.I("b.distance").Asc()).
+		Executor().ScanStructsContext(ctx, &bestEfforts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select best efforts for %s: %w", id, err)
+	}
+
+	return bestEfforts, nil
+}
+
+// BestEffortBests returns the fastest time for each distance over the
+// counted activities between from and to, ordered by distance
+func BestEffortBests(ctx context.Context, db *sql.DB, from, to time.Time) ([]BestEffort, error) {
+	bestEfforts := []BestEffort{}
+	err := bestEffortsQuery(db, goqu.L("0")).
+		Distinct(goqu.I("b.distance")).
+		Where(
+			goqu.I("a.timestamp").Gte(from),
+			goqu.I("a.timestamp").Lt(to),
+			goqu.I("a.duplicate_of").IsNull(),
+		).
+		Order(goqu.I("b.distance").Asc(), goqu.I("b.seconds").Asc()).
+		Executor().ScanStructsContext(ctx, &bestEfforts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select best effort bests: %w", err)
+	}
+
+	return bestEfforts, nil
+}
+
+// BestEffortSeasonBests returns the fastest time for each distance in each
+// calendar year from counted activities, keyed by year
+func BestEffortSeasonBests(ctx context.Context, db *sql.DB) (map[int][]BestEffort, error) {
+	var rows []BestEffort
+	err := bestEffortsQuery(db, seasonExpression).
+		Distinct(seasonExpression, goqu.I("b.distance")).
+		Where(goqu.I("a.duplicate_of").IsNull()).
+		Order(seasonExpression.Asc(), goqu.I("b.distance").Asc(), goqu.I("b.seconds").Asc()).
+		Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select best effort season bests: %w", err)
+	}
+
+	seasons := make(map[int][]BestEffort)
+	for _, r := range rows {
+		seasons[r.Season] = append(seasons[r.Season], r)
+	}
+
+	return seasons, nil
+}
+
+// seasonExpression is the calendar year of the activity
+var seasonExpression = goqu.L("EXTRACT(YEAR FROM a.timestamp)::INTEGER")
+
+func powerCurveQuery(db *sql.DB, season exp.LiteralExpression) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			season.As("season"),
+			goqu.I("p.duration"),
+			goqu.I("p.watts"),
+			goqu.I("p.activity_id"),
+			goqu.I("a.timestamp"),
+		).
+		From(goqu.T("power_curves").Schema("activities").As("p")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("p.activity_id"))),
+		)
+}
+
+func bestEffortsQuery(db *sql.DB, season exp.LiteralExpression) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			season.As("season"),
+			goqu.I("b.distance"),
+			goqu.I("b.name"),
+			goqu.I("b.seconds"),
+			goqu.I("b.start_time"),
+			goqu.I("b.activity_id"),
+			goqu.I("a.timestamp"),
+		).
+		From(goqu.T("best_efforts").Schema("activities").As("b")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("b.activity_id"))),
+		)
+}
@@ -0,0 +1,84 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/explorer"
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+)
+
+// ExplorerTile is a visited explorer tile
+type ExplorerTile struct {
+	X               int       `db:"x"`
+	Y               int       `db:"y"`
+	FirstVisitedAt  time.Time `db:"first_visited_at"`
+	FirstActivityID string    `db:"first_activity_id"`
+	VisitCount      int       `db:"visit_count"`
+}
+
+// Tile returns the map tile
+func (e ExplorerTile) Tile() tiles.Tile {
+	return tiles.Tile{Z: explorer.Zoom, X: e.X, Y: e.Y}
+}
+
+// ExplorerTiles returns all the visited explorer tiles, when before is set
+// only tiles first visited before then are returned
+func ExplorerTiles(ctx context.Context, db *sql.DB, before time.Time) ([]ExplorerTile, error) {
+	query := goqu.New("postgres", db).
+		Select("x", "y", "first_visited_at", "first_activity_id", "visit_count").
+		From("activities.explorer_tiles").
+		Order(goqu.C("first_visited_at").Asc())
+
+	if !before.IsZero() {
+		query = query.Where(goqu.C("first_visited_at").Lt(before))
+	}
+
+	var visited []ExplorerTile
+	err := query.Executor().ScanStructsContext(ctx, &visited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select explorer tiles: %w", err)
+	}
+
+	return visited, nil
+}
+
+// ActivityExplorerTile is a tile visited by an activity
+type ActivityExplorerTile struct {
+	X int `db:"x" json:"x"`
+	Y int `db:"y" json:"y"`
+	// New is true if the activity was the first to visit the tile
+	New bool `db:"new" json:"new"`
+}
+
+// ActivityExplorerTiles returns the explorer tiles visited by an activity
+func ActivityExplorerTiles(ctx context.Context, db *sql.DB, id string) ([]ActivityExplorerTile, error) {
+	query := goqu.New("postgres", db).
+		Select(
+			goqu.I("a.x"),
+			goqu.I("a.y"),
+			goqu.COALESCE(goqu.I("e.first_activity_id").Eq(goqu.I("a.activity_id")), false).As("new"),
+		).
+		From(goqu.T("explorer_activity_tiles").Schema("activities").As("a")).
+		LeftJoin(
+			goqu.T("explorer_tiles").Schema("activities").As("e"),
+			goqu.On(
+				goqu.I("e.x").Eq(goqu.I("a.x")),
+				goqu.I("e.y").Eq(goqu.I("a.y")),
+			),
+		).
+		Where(goqu.I("a.activity_id").Eq(id)).
+		Order(goqu.I("a.y").Asc(), goqu.I("a.x").Asc())
+
+	var visited []ActivityExplorerTile
+	err := query.Executor().ScanStructsContext(ctx, &visited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select explorer tiles for %s: %w", id, err)
+	}
+
+	return visited, nil
+}
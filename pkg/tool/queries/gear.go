@@ -0,0 +1,103 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// GearTotal is the cumulative use of a piece of gear over the archive
+type GearTotal struct {
+	GearID        string    `db:"gear_id" json:"gear_id"`
+	Activities    int       `db:"activities" json:"activities"`
+	Distance      float64   `db:"distance" json:"distance"`
+	MovingTime    int64     `db:"moving_time" json:"moving_time"`
+	ElevationGain float64   `db:"elevation_gain" json:"elevation_gain"`
+	FirstUsed     time.Time `db:"first_used" json:"first_used"`
+	LastUsed      time.Time `db:"last_used" json:"last_used"`
+}
+
+// GearComponent is the use of a maintenance component since it was
+// installed
+type GearComponent struct {
+	GearID           string     `db:"gear_id" json:"gear_id"`
+	Name             string     `db:"name" json:"name"`
+	InstalledOn      time.Time  `db:"installed_on" json:"installed_on"`
+	IntervalDistance *float64   `db:"interval_distance" json:"interval_distance,omitempty"`
+	IntervalTime     *float64   `db:"interval_time" json:"interval_time,omitempty"`
+	IntervalDays     *int       `db:"interval_days" json:"interval_days,omitempty"`
+	Activities       int        `db:"activities" json:"activities"`
+	Distance         float64    `db:"distance" json:"distance"`
+	MovingTime       float64    `db:"moving_time" json:"moving_time"`
+	Due              bool       `db:"due" json:"due"`
+	DueReasons       string     `db:"due_reasons" json:"due_reasons,omitempty"`
+	NotifiedAt       *time.Time `db:"notified_at" json:"notified_at,omitempty"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// GearTotals returns the cumulative use of each piece of gear, ordered by
+// when it was last used
+func GearTotals(ctx context.Context, db *sql.DB) ([]GearTotal, error) {
+	totals := []GearTotal{}
+	err := goqu.New("postgres", db).
+		Select(
+			goqu.C("gear_id"),
+			goqu.COUNT("*").As("activities"),
+			goqu.SUM("distance").As("distance"),
+			goqu.SUM("moving_time").As("moving_time"),
+			goqu.SUM("elevation_gain").As("elevation_gain"),
+			goqu.MIN("start_date_local").As("first_used"),
+			goqu.MAX("start_date_local").As("last_used"),
+		).
+		From("activities.activity_stats").
+		Where(
+			goqu.C("gear_id").Neq(""),
+			goqu.C("counted").IsTrue(),
+		).
+		GroupBy("gear_id").
+		Order(goqu.I("last_used").Desc()).
+		ScanStructsContext(ctx, &totals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select gear totals: %w", err)
+	}
+
+	return totals, nil
+}
+
+// GearComponents returns the configured components with their use, due
+// components first
+func GearComponents(ctx context.Context, db *sql.DB) ([]GearComponent, error) {
+	components := []GearComponent{}
+	err := goqu.New("postgres", db).
+		Select(
+			"gear_id",
+			"name",
+			"installed_on",
+			"interval_distance",
+			"interval_time",
+			"interval_days",
+			"activities",
+			"distance",
+			"moving_time",
+			"due",
+			"due_reasons",
+			"notified_at",
+			"updated_at",
+		).
+		From("activities.gear_components").
+		Order(
+			goqu.C("due").Desc(),
+			goqu.C("gear_id").Asc(),
+			goqu.C("name").Asc(),
+			goqu.C("installed_on").Desc(),
+		).
+		ScanStructsContext(ctx, &components)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select gear components: %w", err)
+	}
+
+	return components, nil
+}
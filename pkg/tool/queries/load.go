@@ -0,0 +1,64 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// ActivityLoad is the training stress of an activity
+type ActivityLoad struct {
+	ActivityID      string   `db:"activity_id" json:"activity_id"`
+	Method          string   `db:"method" json:"method"`
+	Stress          float64  `db:"stress" json:"stress"`
+	NormalizedPower *float64 `db:"normalized_power" json:"normalized_power,omitempty"`
+	Intensity       *float64 `db:"intensity" json:"intensity,omitempty"`
+}
+
+// TrainingLoadDay is the stress, fitness, fatigue and form on a day
+type TrainingLoadDay struct {
+	Date    time.Time `db:"date" json:"date"`
+	Stress  float64   `db:"stress" json:"stress"`
+	Fitness float64   `db:"fitness" json:"fitness"`
+	Fatigue float64   `db:"fatigue" json:"fatigue"`
+	Form    float64   `db:"form" json:"form"`
+}
+
+// ActivityTrainingLoad returns the training stress of an activity, false
+// is returned when it has not been calculated
+func ActivityTrainingLoad(ctx context.Context, db *sql.DB, id string) (ActivityLoad, bool, error) {
+	var activityLoad ActivityLoad
+	found, err := goqu.New("postgres", db).
+		Select("activity_id", "method", "stress", "normalized_power", "intensity").
+		From("activities.activity_loads").
+		Where(goqu.C("activity_id").Eq(id)).
+		ScanStructContext(ctx, &activityLoad)
+	if err != nil {
+		return ActivityLoad{}, false, fmt.Errorf("failed to select training load for %s: %w", id, err)
+	}
+
+	return activityLoad, found, nil
+}
+
+// TrainingLoadDays returns the daily training load from the from date up
+// to but not including the to date
+func TrainingLoadDays(ctx context.Context, db *sql.DB, from, to time.Time) ([]TrainingLoadDay, error) {
+	days := []TrainingLoadDay{}
+	err := goqu.New("postgres", db).
+		Select("date", "stress", "fitness", "fatigue", "form").
+		From("activities.training_load_days").
+		Where(
+			goqu.C("date").Gte(from.Format("2006-01-02")),
+			goqu.C("date").Lt(to.Format("2006-01-02")),
+		).
+		Order(goqu.C("date").Asc()).
+		ScanStructsContext(ctx, &days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select training load days: %w", err)
+	}
+
+	return days, nil
+}
@@ -0,0 +1,111 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/records"
+)
+
+// PersonalRecord is a value which beat all earlier activities of the sport
+type PersonalRecord struct {
+	ActivityID         string    `db:"activity_id" json:"activity_id"`
+	Sport              string    `db:"sport" json:"sport"`
+	Category           string    `db:"category" json:"category"`
+	Key                string    `db:"key" json:"key,omitempty"`
+	Value              float64   `db:"value" json:"value"`
+	PreviousValue      *float64  `db:"previous_value" json:"previous_value,omitempty"`
+	PreviousActivityID *string   `db:"previous_activity_id" json:"previous_activity_id,omitempty"`
+	AchievedAt         time.Time `db:"achieved_at" json:"achieved_at"`
+}
+
+// Event is something which happened for a notifier to pick up
+type Event struct {
+	ID          int64           `db:"id" json:"id"`
+	Kind        string          `db:"kind" json:"kind"`
+	ActivityID  *string         `db:"activity_id" json:"activity_id,omitempty"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
+// CurrentPersonalRecords returns the best record for each sport, category
+// and key, the earliest is returned when values are equal
+func CurrentPersonalRecords(ctx context.Context, db *sql.DB) ([]PersonalRecord, error) {
+	current := []PersonalRecord{}
+	err := personalRecordsQuery(db).
+		Distinct("sport", "category", "key").
+		Order(
+			goqu.C("sport").Asc(),
+			goqu.C("category").Asc(),
+			goqu.C("key").Asc(),
+			// records can be set out of order when older activities are
+			// imported later, so the best value is chosen rather than the
+			// latest. Best efforts are times where lower is better.
+			goqu.L("CASE WHEN category = ? THEN value ELSE -value END", records.BestEffort).Asc(),
+			goqu.C("achieved_at").Asc(),
+		).
+		ScanStructsContext(ctx, &current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select current personal records: %w", err)
+	}
+
+	return current, nil
+}
+
+// ActivityPersonalRecords returns the records set by an activity
+func ActivityPersonalRecords(ctx context.Context, db *sql.DB, id string) ([]PersonalRecord, error) {
+	activityRecords := []PersonalRecord{}
+	err := personalRecordsQuery(db).
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("category").Asc(), goqu.C("key").Asc()).
+		ScanStructsContext(ctx, &activityRecords)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select personal records for %s: %w", id, err)
+	}
+
+	return activityRecords, nil
+}
+
+// Events returns up to limit events after the event ID, oldest first. Only
+// events which have not been delivered are returned when undelivered is
+// set.
+func Events(ctx context.Context, db *sql.DB, after int64, limit uint, undelivered bool) ([]Event, error) {
+	query := goqu.New("postgres", db).
+		Select("id", "kind", "activity_id", "payload", "created_at", "delivered_at").
+		From("activities.events").
+		Where(goqu.C("id").Gt(after)).
+		Order(goqu.C("id").Asc()).
+		Limit(limit)
+	if undelivered {
+		query = query.Where(goqu.C("delivered_at").IsNull())
+	}
+
+	events := []Event{}
+	err := query.ScanStructsContext(ctx, &events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select events: %w", err)
+	}
+
+	return events, nil
+}
+
+func personalRecordsQuery(db *sql.DB) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			"activity_id",
+			"sport",
+			"category",
+			"key",
+			"value",
+			"previous_value",
+			"previous_activity_id",
+			"achieved_at",
+		).
+		From("activities.personal_records")
+}
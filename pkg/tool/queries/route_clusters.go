@@ -0,0 +1,213 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// RouteSummary is a repeated route with the number of activities on it
+type RouteSummary struct {
+	ID             int64     `db:"id" json:"id"`
+	Type           string    `db:"type" json:"type"`
+	Length         float64   `db:"length" json:"length"`
+	Activities     int       `db:"activities" json:"activities"`
+	FirstActivity  time.Time `db:"first_activity" json:"first_activity"`
+	LastActivity   time.Time `db:"last_activity" json:"last_activity"`
+	BestMovingTime *int64    `db:"best_moving_time" json:"best_moving_time"`
+}
+
+// RouteEffort is an activity on a route, Rank is its position among all
+// the efforts on the route by moving time
+type RouteEffort struct {
+	ActivityID     string     `db:"activity_id" json:"activity_id"`
+	Name           string     `db:"name" json:"name"`
+	Timestamp      time.Time  `db:"timestamp" json:"timestamp"`
+	StartDateLocal *time.Time `db:"start_date_local" json:"start_date_local,omitempty"`
+	MovingTime     *int64     `db:"moving_time" json:"moving_time"`
+	Distance       *float64   `db:"distance" json:"distance"`
+	AverageSpeed   *float64   `db:"average_speed" json:"average_speed"`
+	Rank           *int       `db:"rank" json:"rank"`
+}
+
+// RouteYear summarises the efforts on a route in a year to show trends,
+// times are moving times in seconds
+type RouteYear struct {
+	Year         int     `json:"year"`
+	Efforts      int     `json:"efforts"`
+	BestTime     int64   `json:"best_time"`
+	MedianTime   float64 `json:"median_time"`
+	AverageSpeed float64 `json:"average_speed"`
+}
+
+// RouteHistory is a route with every effort on it, most recent first, and
+// a summary of each year
+type RouteHistory struct {
+	ID       int64           `json:"id"`
+	Type     string          `json:"type"`
+	Length   float64         `json:"length"`
+	Geometry json.RawMessage `json:"geometry"`
+	Efforts  []RouteEffort   `json:"efforts"`
+	Years    []RouteYear     `json:"years"`
+}
+
+// RouteSummaries returns the routes of the activity type, or all types when
+// empty, followed by at least minActivities activities, most followed first
+func RouteSummaries(ctx context.Context, db *sql.DB, activityType string, minActivities int) ([]RouteSummary, error) {
+	activities := goqu.COUNT("*")
+
+	query := goqu.New("postgres", db).
+		Select(
+			goqu.I("r.id"),
+			goqu.I("r.type"),
+			goqu.I("r.length"),
+			activities.As("activities"),
+			goqu.MIN("a.timestamp").As("first_activity"),
+			goqu.MAX("a.timestamp").As("last_activity"),
+			goqu.L("MIN(s.moving_time) FILTER (WHERE s.counted AND s.moving_time > 0)").As("best_moving_time"),
+		).
+		From(goqu.T("route_clusters").Schema("activities").As("r")).
+		InnerJoin(
+			goqu.T("route_cluster_activities").Schema("activities").As("c"),
+			goqu.On(goqu.I("c.route_id").Eq(goqu.I("r.id"))),
+		).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("c.activity_id"))),
+		).
+		LeftJoin(
+			goqu.T("activity_stats").Schema("activities").As("s"),
+			goqu.On(goqu.I("s.activity_id").Eq(goqu.I("c.activity_id"))),
+		).
+		GroupBy(goqu.I("r.id")).
+		Having(activities.Gte(minActivities)).
+		Order(goqu.I("activities").Desc(), goqu.I("r.id").Asc())
+
+	if activityType != "" {
+		query = query.Where(goqu.I("r.type").Eq(activityType))
+	}
+
+	summaries := []RouteSummary{}
+	err := query.ScanStructsContext(ctx, &summaries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select routes: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// RouteClusterHistory returns the efforts on a route and a summary of each
+// year. Duplicate activities are listed but not ranked or summarised. The
+// returned bool is false when there is no such route.
+func RouteClusterHistory(ctx context.Context, db *sql.DB, id int64) (RouteHistory, bool, error) {
+	goquDB := goqu.New("postgres", db)
+
+	var route struct {
+		Type     string  `db:"type"`
+		Length   float64 `db:"length"`
+		Geometry []byte  `db:"geometry"`
+	}
+	found, err := goquDB.Select("type", "length", "geometry").
+		From("activities.route_clusters").
+		Where(goqu.C("id").Eq(id)).
+		ScanStructContext(ctx, &route)
+	if err != nil {
+		return RouteHistory{}, false, fmt.Errorf("failed to select route %d: %w", id, err)
+	}
+	if !found {
+		return RouteHistory{}, false, nil
+	}
+
+	history := RouteHistory{
+		ID:       id,
+		Type:     route.Type,
+		Length:   route.Length,
+		Geometry: route.Geometry,
+		Efforts:  []RouteEffort{},
+		Years:    []RouteYear{},
+	}
+
+	err = goquDB.Select(
+		goqu.I("a.id").As("activity_id"),
+		goqu.I("a.name"),
+		goqu.I("a.timestamp"),
+		goqu.I("a.start_date_local"),
+		goqu.I("s.moving_time"),
+		goqu.I("s.distance"),
+		goqu.L("s.distance / NULLIF(s.moving_time, 0)").As("average_speed"),
+		goqu.L(
+			"CASE WHEN s.counted AND s.moving_time > 0 THEN RANK() OVER (PARTITION BY s.counted AND s.moving_time > 0 ORDER BY s.moving_time) END",
+		).As("rank"),
+	).
+		From(goqu.T("route_cluster_activities").Schema("activities").As("c")).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("c.activity_id"))),
+		).
+		LeftJoin(
+			goqu.T("activity_stats").Schema("activities").As("s"),
+			goqu.On(goqu.I("s.activity_id").Eq(goqu.I("c.activity_id"))),
+		).
+		Where(goqu.I("c.route_id").Eq(id)).
+		Order(goqu.I("a.timestamp").Desc()).
+		ScanStructsContext(ctx, &history.Efforts)
+	if err != nil {
+		return RouteHistory{}, false, fmt.Errorf("failed to select efforts on route %d: %w", id, err)
+	}
+
+	history.Years = routeYears(history.Efforts)
+
+	return history, true, nil
+}
+
+// routeYears summarises the ranked efforts by the year they started in,
+// oldest first
+func routeYears(efforts []RouteEffort) []RouteYear {
+	times := make(map[int][]int64)
+	distances := make(map[int]float64)
+	for _, e := range efforts {
+		if e.Rank == nil {
+			continue
+		}
+
+		start := e.Timestamp
+		if e.StartDateLocal != nil {
+			start = *e.StartDateLocal
+		}
+
+		times[start.Year()] = append(times[start.Year()], *e.MovingTime)
+		distances[start.Year()] += *e.Distance
+	}
+
+	years := []RouteYear{}
+	for year, yearTimes := range times {
+		sort.Slice(yearTimes, func(i, j int) bool { return yearTimes[i] < yearTimes[j] })
+
+		total := int64(0)
+		for _, t := range yearTimes {
+			total += t
+		}
+
+		n := len(yearTimes)
+		median := float64(yearTimes[n/2])
+		if n%2 == 0 {
+			median = float64(yearTimes[n/2-1]+yearTimes[n/2]) / 2
+		}
+
+		years = append(years, RouteYear{
+			Year:         year,
+			Efforts:      n,
+			BestTime:     yearTimes[0],
+			MedianTime:   median,
+			AverageSpeed: distances[year] / float64(total),
+		})
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i].Year < years[j].Year })
+
+	return years
+}
@@ -0,0 +1,163 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// Segment is a user defined segment with the number of efforts on it
+type Segment struct {
+	ID              int64     `db:"id" json:"id"`
+	Name            string    `db:"name" json:"name"`
+	ActivityID      *string   `db:"activity_id" json:"activity_id,omitempty"`
+	Length          float64   `db:"length" json:"length"`
+	Efforts         int       `db:"efforts" json:"efforts"`
+	BestElapsedTime *float64  `db:"best_elapsed_time" json:"best_elapsed_time"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+}
+
+// SegmentEffort is a pass along a segment, Rank is its position among all
+// the efforts on the segment by elapsed time. Efforts by duplicate
+// activities are not ranked.
+type SegmentEffort struct {
+	ID               int64     `db:"id" json:"id"`
+	SegmentID        int64     `db:"segment_id" json:"segment_id"`
+	SegmentName      string    `db:"segment_name" json:"segment_name"`
+	ActivityID       string    `db:"activity_id" json:"activity_id"`
+	ActivityName     string    `db:"activity_name" json:"activity_name"`
+	StartTime        time.Time `db:"start_time" json:"start_time"`
+	ElapsedTime      float64   `db:"elapsed_time" json:"elapsed_time"`
+	Distance         float64   `db:"distance" json:"distance"`
+	AveragePower     *float64  `db:"average_power" json:"average_power,omitempty"`
+	AverageHeartRate *float64  `db:"average_heart_rate" json:"average_heart_rate,omitempty"`
+	Rank             *int      `db:"rank" json:"rank"`
+}
+
+// SegmentLeaderboard is a segment with its efforts, fastest first
+type SegmentLeaderboard struct {
+	Segment
+	Geometry json.RawMessage `json:"geometry"`
+	Efforts  []SegmentEffort `json:"efforts"`
+}
+
+// Segments returns the segments with their number of efforts, most recently
+// created first
+func Segments(ctx context.Context, db *sql.DB) ([]Segment, error) {
+	list := []Segment{}
+	err := segmentsQuery(db).
+		Order(goqu.I("s.created_at").Desc()).
+		ScanStructsContext(ctx, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select segments: %w", err)
+	}
+
+	return list, nil
+}
+
+// SegmentEfforts returns a segment and its efforts, the bool is false when
+// there is no such segment
+func SegmentEfforts(ctx context.Context, db *sql.DB, id int64) (SegmentLeaderboard, bool, error) {
+	leaderboard := SegmentLeaderboard{Efforts: []SegmentEffort{}}
+
+	found, err := segmentsQuery(db).
+		Where(goqu.I("s.id").Eq(id)).
+		ScanStructContext(ctx, &leaderboard.Segment)
+	if err != nil {
+		return SegmentLeaderboard{}, false, fmt.Errorf("failed to select segment %d: %w", id, err)
+	}
+	if !found {
+		return SegmentLeaderboard{}, false, nil
+	}
+
+	var geometry []byte
+	_, err = goqu.New("postgres", db).
+		Select("geometry").
+		From("activities.segments").
+		Where(goqu.C("id").Eq(id)).
+		ScanValContext(ctx, &geometry)
+	if err != nil {
+		return SegmentLeaderboard{}, false, fmt.Errorf("failed to select geometry of segment %d: %w", id, err)
+	}
+	leaderboard.Geometry = geometry
+
+	err = goqu.New("postgres", db).
+		From(rankedSegmentEfforts(db).As("e")).
+		Where(goqu.C("segment_id").Eq(id)).
+		Order(goqu.C("elapsed_time").Asc(), goqu.C("start_time").Asc()).
+		ScanStructsContext(ctx, &leaderboard.Efforts)
+	if err != nil {
+		return SegmentLeaderboard{}, false, fmt.Errorf("failed to select efforts on segment %d: %w", id, err)
+	}
+
+	return leaderboard, true, nil
+}
+
+// ActivitySegmentEfforts returns the efforts of an activity on all segments
+// with their rank, in the order they were made
+func ActivitySegmentEfforts(ctx context.Context, db *sql.DB, id string) ([]SegmentEffort, error) {
+	efforts := []SegmentEffort{}
+	err := goqu.New("postgres", db).
+		From(rankedSegmentEfforts(db).As("e")).
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("start_time").Asc()).
+		ScanStructsContext(ctx, &efforts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select segment efforts for %s: %w", id, err)
+	}
+
+	return efforts, nil
+}
+
+func segmentsQuery(db *sql.DB) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			goqu.I("s.id"),
+			goqu.I("s.name"),
+			goqu.I("s.activity_id"),
+			goqu.I("s.length"),
+			goqu.COUNT(goqu.I("e.id")).As("efforts"),
+			goqu.MIN(goqu.I("e.elapsed_time")).As("best_elapsed_time"),
+			goqu.I("s.created_at"),
+		).
+		From(goqu.T("segments").Schema("activities").As("s")).
+		LeftJoin(
+			goqu.T("segment_efforts").Schema("activities").As("e"),
+			goqu.On(goqu.I("e.segment_id").Eq(goqu.I("s.id"))),
+		).
+		GroupBy(goqu.I("s.id"))
+}
+
+// rankedSegmentEfforts selects all efforts with their rank on the segment,
+// efforts by duplicate activities are left unranked
+func rankedSegmentEfforts(db *sql.DB) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			goqu.I("e.id"),
+			goqu.I("e.segment_id"),
+			goqu.I("s.name").As("segment_name"),
+			goqu.I("e.activity_id"),
+			goqu.I("a.name").As("activity_name"),
+			goqu.I("e.start_time"),
+			goqu.I("e.elapsed_time"),
+			goqu.I("e.distance"),
+			goqu.I("e.average_power"),
+			goqu.I("e.average_heart_rate"),
+			goqu.L(
+				"CASE WHEN a.duplicate_of IS NULL THEN RANK() OVER (PARTITION BY e.segment_id, a.duplicate_of IS NULL ORDER BY e.elapsed_time) END",
+			).As("rank"),
+		).
+		From(goqu.T("segment_efforts").Schema("activities").As("e")).
+		InnerJoin(
+			goqu.T("segments").Schema("activities").As("s"),
+			goqu.On(goqu.I("s.id").Eq(goqu.I("e.segment_id"))),
+		).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("e.activity_id"))),
+		)
+}
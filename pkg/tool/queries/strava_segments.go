@@ -0,0 +1,132 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// StravaSegment is a Strava segment with the number of efforts on it from
+// the archived activity data
+type StravaSegment struct {
+	ID              int64     `db:"id" json:"id"`
+	Name            string    `db:"name" json:"name"`
+	ActivityType    string    `db:"activity_type" json:"activity_type"`
+	Distance        float64   `db:"distance" json:"distance"`
+	AverageGrade    float64   `db:"average_grade" json:"average_grade"`
+	City            string    `db:"city" json:"city"`
+	Country         string    `db:"country" json:"country"`
+	Efforts         int       `db:"efforts" json:"efforts"`
+	BestElapsedTime *int      `db:"best_elapsed_time" json:"best_elapsed_time"`
+	LastEffort      time.Time `db:"last_effort" json:"last_effort"`
+}
+
+// StravaSegmentEffort is an effort on a Strava segment, Rank is its
+// position among all the efforts on the segment by elapsed time. Efforts by
+// duplicate activities are not ranked.
+type StravaSegmentEffort struct {
+	ID               int64     `db:"id" json:"id"`
+	ActivityID       string    `db:"activity_id" json:"activity_id"`
+	ActivityName     string    `db:"activity_name" json:"activity_name"`
+	StartDate        time.Time `db:"start_date" json:"start_date"`
+	ElapsedTime      int       `db:"elapsed_time" json:"elapsed_time"`
+	MovingTime       int       `db:"moving_time" json:"moving_time"`
+	AveragePower     *float64  `db:"average_power" json:"average_power,omitempty"`
+	AverageHeartRate *float64  `db:"average_heart_rate" json:"average_heart_rate,omitempty"`
+	PRRank           *int      `db:"pr_rank" json:"pr_rank,omitempty"`
+	KOMRank          *int      `db:"kom_rank" json:"kom_rank,omitempty"`
+	Rank             *int      `db:"rank" json:"rank"`
+}
+
+// StravaSegmentHistory is a Strava segment with its efforts, most recent
+// first
+type StravaSegmentHistory struct {
+	StravaSegment
+	Efforts []StravaSegmentEffort `json:"efforts"`
+}
+
+// StravaSegments returns the Strava segments with at least minEfforts
+// efforts, most ridden or run first
+func StravaSegments(ctx context.Context, db *sql.DB, minEfforts int) ([]StravaSegment, error) {
+	list := []StravaSegment{}
+	err := stravaSegmentsQuery(db).
+		Having(goqu.COUNT("*").Gte(minEfforts)).
+		Order(goqu.I("efforts").Desc(), goqu.I("s.id").Asc()).
+		ScanStructsContext(ctx, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select strava segments: %w", err)
+	}
+
+	return list, nil
+}
+
+// StravaSegmentEfforts returns a Strava segment and its efforts, the bool
+// is false when there are no efforts on such a segment
+func StravaSegmentEfforts(ctx context.Context, db *sql.DB, id int64) (StravaSegmentHistory, bool, error) {
+	history := StravaSegmentHistory{Efforts: []StravaSegmentEffort{}}
+
+	found, err := stravaSegmentsQuery(db).
+		Where(goqu.I("s.id").Eq(id)).
+		ScanStructContext(ctx, &history.StravaSegment)
+	if err != nil {
+		return StravaSegmentHistory{}, false, fmt.Errorf("failed to select strava segment %d: %w", id, err)
+	}
+	if !found {
+		return StravaSegmentHistory{}, false, nil
+	}
+
+	err = goqu.New("postgres", db).
+		Select(
+			goqu.I("e.id"),
+			goqu.I("e.activity_id"),
+			goqu.I("a.name").As("activity_name"),
+			goqu.I("e.start_date"),
+			goqu.I("e.elapsed_time"),
+			goqu.I("e.moving_time"),
+			goqu.I("e.average_power"),
+			goqu.I("e.average_heart_rate"),
+			goqu.I("e.pr_rank"),
+			goqu.I("e.kom_rank"),
+			goqu.L(
+				"CASE WHEN a.duplicate_of IS NULL THEN RANK() OVER (PARTITION BY a.duplicate_of IS NULL ORDER BY e.elapsed_time) END",
+			).As("rank"),
+		).
+		From(goqu.T("strava_segment_efforts").Schema("activities").As("e")).
+		InnerJoin(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("e.activity_id"))),
+		).
+		Where(goqu.I("e.segment_id").Eq(id)).
+		Order(goqu.I("e.start_date").Desc()).
+		ScanStructsContext(ctx, &history.Efforts)
+	if err != nil {
+		return StravaSegmentHistory{}, false, fmt.Errorf("failed to select efforts on strava segment %d: %w", id, err)
+	}
+
+	return history, true, nil
+}
+
+func stravaSegmentsQuery(db *sql.DB) *goqu.SelectDataset {
+	return goqu.New("postgres", db).
+		Select(
+			goqu.I("s.id"),
+			goqu.I("s.name"),
+			goqu.I("s.activity_type"),
+			goqu.I("s.distance"),
+			goqu.I("s.average_grade"),
+			goqu.I("s.city"),
+			goqu.I("s.country"),
+			goqu.COUNT("*").As("efforts"),
+			goqu.MIN(goqu.I("e.elapsed_time")).As("best_elapsed_time"),
+			goqu.MAX(goqu.I("e.start_date")).As("last_effort"),
+		).
+		From(goqu.T("strava_segments").Schema("activities").As("s")).
+		InnerJoin(
+			goqu.T("strava_segment_efforts").Schema("activities").As("e"),
+			goqu.On(goqu.I("e.segment_id").Eq(goqu.I("s.id"))),
+		).
+		GroupBy(goqu.I("s.id"))
+}
@@ -0,0 +1,75 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// ZoneTime is the time spent in a heart rate or power zone
+type ZoneTime struct {
+	Kind     string   `db:"kind" json:"-"`
+	Zone     int      `db:"zone" json:"zone"`
+	MinValue *float64 `db:"min_value" json:"min,omitempty"`
+	MaxValue *float64 `db:"max_value" json:"max,omitempty"`
+	Seconds  float64  `db:"seconds" json:"seconds"`
+}
+
+// ActivityZones returns the time an activity spent in each zone by kind of
+// zone
+func ActivityZones(ctx context.Context, db *sql.DB, id string) (map[string][]ZoneTime, error) {
+	var rows []ZoneTime
+	err := goqu.New("postgres", db).
+		Select("kind", "zone", "min_value", "max_value", "seconds").
+		From("activities.activity_zones").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("kind").Asc(), goqu.C("zone").Asc()).
+		Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select zones for %s: %w", id, err)
+	}
+
+	return groupZoneTimes(rows), nil
+}
+
+// ZoneTotals returns the time spent in each zone by kind of zone over the
+// counted activities between from and to. Zones are totalled by number so the
+// bounds are not included as they may have changed during the period.
+func ZoneTotals(ctx context.Context, db *sql.DB, from, to time.Time) (map[string][]ZoneTime, error) {
+	var rows []ZoneTime
+	err := goqu.New("postgres", db).
+		Select(
+			goqu.I("z.kind"),
+			goqu.I("z.zone"),
+			goqu.SUM(goqu.I("z.seconds")).As("seconds"),
+		).
+		From(goqu.T("activity_zones").Schema("activities").As("z")).
+		Join(
+			goqu.T("activities").Schema("activities").As("a"),
+			goqu.On(goqu.I("a.id").Eq(goqu.I("z.activity_id"))),
+		).
+		Where(
+			goqu.I("a.timestamp").Gte(from),
+			goqu.I("a.timestamp").Lt(to),
+			goqu.I("a.duplicate_of").IsNull(),
+		).
+		GroupBy(goqu.I("z.kind"), goqu.I("z.zone")).
+		Order(goqu.I("z.kind").Asc(), goqu.I("z.zone").Asc()).
+		Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select zone totals: %w", err)
+	}
+
+	return groupZoneTimes(rows), nil
+}
+
+func groupZoneTimes(rows []ZoneTime) map[string][]ZoneTime {
+	grouped := make(map[string][]ZoneTime)
+	for _, r := range rows {
+		grouped[r.Kind] = append(grouped[r.Kind], r)
+	}
+	return grouped
+}
@@ -1,11 +1,22 @@
 package tool
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
+
+	"cloud.google.com/go/storage"
 	"github.com/Jeffail/gabs/v2"
+	"google.golang.org/api/option"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/classify"
+	"github.com/charlieegan3/tool-activities/internal/pkg/gear"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/zones"
+	"github.com/charlieegan3/tool-activities/pkg/tool/handlers"
 	"github.com/charlieegan3/tool-activities/pkg/tool/jobs"
+	"github.com/charlieegan3/tool-activities/pkg/tool/jobs/manual"
 	"github.com/charlieegan3/toolbelt/pkg/apis"
 	"github.com/gorilla/mux"
 )
@@ -32,6 +43,30 @@ type Activities struct {
 	scheduleActivityPoll     string
 	scheduleActivitySync     string
 	scheduleActivityOriginal string
+	scheduleOriginalSummary  string
+	scheduleTrackPoints      string
+	scheduleRouteGeometry    string
+	scheduleRouteMaps        string
+	scheduleHeatmapTiles     string
+	scheduleExplorerTiles    string
+	scheduleTimeInZones      string
+	scheduleBestEfforts      string
+	scheduleTrainingLoad     string
+	schedulePersonalRecords  string
+	scheduleEventNotifier    string
+	scheduleAggregates       string
+	scheduleGearMaintenance  string
+	scheduleDuplicates       string
+	scheduleRouteClustering  string
+	scheduleSegmentMatching  string
+	scheduleClassification   string
+
+	webhookURL string
+
+	privacy        *privacy.Policy
+	zones          *zones.Schedule
+	gear           []gear.Component
+	classification *classify.Rules
 }
 
 func (a *Activities) Name() string {
@@ -40,7 +75,7 @@ func (a *Activities) Name() string {
 
 func (a *Activities) FeatureSet() apis.FeatureSet {
 	return apis.FeatureSet{
-		HTTP:     false,
+		HTTP:     true,
 		Config:   true,
 		Jobs:     true,
 		Database: true,
@@ -58,6 +93,7 @@ func (a *Activities) DatabaseSet(db *sql.DB) {
 func (a *Activities) SetConfig(config map[string]any) error {
 	var path string
 	var ok bool
+	var err error
 
 	a.config = gabs.Wrap(config)
 
@@ -137,6 +173,58 @@ func (a *Activities) SetConfig(config map[string]any) error {
 		return fmt.Errorf("missing required config path: %s", path)
 	}
 
+	// schedules for the analysis jobs are optional, the job's default
+	// schedule is used when unset
+	a.scheduleOriginalSummary, _ = a.config.Path("jobs.original_summary.schedule").Data().(string)
+	a.scheduleTrackPoints, _ = a.config.Path("jobs.track_points.schedule").Data().(string)
+	a.scheduleRouteGeometry, _ = a.config.Path("jobs.route_geometry.schedule").Data().(string)
+	a.scheduleRouteMaps, _ = a.config.Path("jobs.route_maps.schedule").Data().(string)
+	a.scheduleHeatmapTiles, _ = a.config.Path("jobs.heatmap_tiles.schedule").Data().(string)
+	a.scheduleExplorerTiles, _ = a.config.Path("jobs.explorer_tiles.schedule").Data().(string)
+	a.scheduleTimeInZones, _ = a.config.Path("jobs.time_in_zones.schedule").Data().(string)
+	a.scheduleBestEfforts, _ = a.config.Path("jobs.best_efforts.schedule").Data().(string)
+	a.scheduleTrainingLoad, _ = a.config.Path("jobs.training_load.schedule").Data().(string)
+	a.schedulePersonalRecords, _ = a.config.Path("jobs.personal_records.schedule").Data().(string)
+	a.scheduleEventNotifier, _ = a.config.Path("jobs.event_notifier.schedule").Data().(string)
+	a.scheduleAggregates, _ = a.config.Path("jobs.activity_aggregates.schedule").Data().(string)
+	a.scheduleGearMaintenance, _ = a.config.Path("jobs.gear_maintenance.schedule").Data().(string)
+	a.scheduleDuplicates, _ = a.config.Path("jobs.duplicate_detection.schedule").Data().(string)
+	a.scheduleRouteClustering, _ = a.config.Path("jobs.route_clustering.schedule").Data().(string)
+	a.scheduleSegmentMatching, _ = a.config.Path("jobs.segment_matching.schedule").Data().(string)
+	a.scheduleClassification, _ = a.config.Path("jobs.activity_classification.schedule").Data().(string)
+
+	// events such as new personal records are posted to the webhook when
+	// set, otherwise they can be polled from the API
+	a.webhookURL, _ = a.config.Path("notifications.webhook_url").Data().(string)
+
+	// privacy zones are optional and applied to all coordinates served over
+	// HTTP, the archived originals are not modified
+	a.privacy, err = privacy.ParseConfig(a.config.Path("privacy").Data())
+	if err != nil {
+		return fmt.Errorf("invalid privacy config: %w", err)
+	}
+
+	// heart rate and power zones are optional, each has a list of zones and
+	// the date they apply from
+	a.zones, err = zones.ParseConfig(a.config.Path("zones").Data())
+	if err != nil {
+		return fmt.Errorf("invalid zones config: %w", err)
+	}
+
+	// gear components are optional, each is checked against its service
+	// interval from the date it was installed
+	a.gear, err = gear.ParseConfig(a.config.Path("gear").Data())
+	if err != nil {
+		return fmt.Errorf("invalid gear config: %w", err)
+	}
+
+	// commute places and windows are optional, indoor and virtual
+	// activities are classified without them
+	a.classification, err = classify.ParseConfig(a.config.Path("classification").Data())
+	if err != nil {
+		return fmt.Errorf("invalid classification config: %w", err)
+	}
+
 	return nil
 }
 
@@ -170,10 +258,343 @@ func (a *Activities) Jobs() ([]apis.Job, error) {
 			GoogleBucketName:      a.googleBucketName,
 			ScheduleOverride:      a.scheduleActivityOriginal,
 		},
+		&jobs.OriginalSummary{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleOriginalSummary,
+		},
+		&jobs.TrackPoints{
+			DB:                    a.db,
+			StravaClientID:        a.stravaClientID,
+			StravaClientSecret:    a.stravaClientSecret,
+			StravaRefreshToken:    a.stravaRefreshToken,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleTrackPoints,
+		},
+		&jobs.RouteGeometry{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleRouteGeometry,
+		},
+		&jobs.RouteMaps{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleRouteMaps,
+			Privacy:               a.privacy,
+		},
+		&jobs.HeatmapTiles{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleHeatmapTiles,
+			Privacy:               a.privacy,
+		},
+		&jobs.ExplorerTiles{
+			DB:               a.db,
+			ScheduleOverride: a.scheduleExplorerTiles,
+		},
+		&jobs.TimeInZones{
+			DB:               a.db,
+			Zones:            a.zones,
+			ScheduleOverride: a.scheduleTimeInZones,
+		},
+		&jobs.BestEfforts{
+			DB:               a.db,
+			ScheduleOverride: a.scheduleBestEfforts,
+		},
+		&jobs.TrainingLoad{
+			DB:               a.db,
+			Zones:            a.zones,
+			ScheduleOverride: a.scheduleTrainingLoad,
+		},
+		&jobs.PersonalRecords{
+			DB:               a.db,
+			ScheduleOverride: a.schedulePersonalRecords,
+		},
+		&jobs.EventNotifier{
+			DB:               a.db,
+			WebhookURL:       a.webhookURL,
+			ScheduleOverride: a.scheduleEventNotifier,
+		},
+		&jobs.ActivityAggregates{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+			ScheduleOverride:      a.scheduleAggregates,
+		},
+		&jobs.GearMaintenance{
+			DB:               a.db,
+			Components:       a.gear,
+			ScheduleOverride: a.scheduleGearMaintenance,
+		},
+		&jobs.DuplicateDetection{
+			DB:               a.db,
+			ScheduleOverride: a.scheduleDuplicates,
+		},
+		&jobs.RouteClustering{
+			DB:               a.db,
+			ScheduleOverride: a.scheduleRouteClustering,
+		},
+		&jobs.SegmentMatching{
+			DB:               a.db,
+			ScheduleOverride: a.scheduleSegmentMatching,
+		},
+		&jobs.ActivityClassification{
+			DB:                 a.db,
+			Rules:              a.classification,
+			StravaClientID:     a.stravaClientID,
+			StravaClientSecret: a.stravaClientSecret,
+			StravaRefreshToken: a.stravaRefreshToken,
+			ScheduleOverride:   a.scheduleClassification,
+		},
 	}, nil
 }
 
-func (a *Activities) HTTPAttach(router *mux.Router) error                    { return nil }
+// ManualJobs returns the jobs which are not scheduled and are instead
+// intended to be run from the command line.
+func (a *Activities) ManualJobs() []apis.Job {
+	return []apis.Job{
+		&manual.FromExport{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+		&manual.OriginalFormatBackfill{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+		&manual.Convert{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+		&manual.AreaSearch{
+			DB: a.db,
+		},
+		&manual.TrainingLoadReport{
+			DB: a.db,
+		},
+		&manual.LocalTimeBackfill{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+		&manual.ActivitySearch{
+			DB: a.db,
+		},
+		&manual.SearchBackfill{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+		&manual.StravaDetailsBackfill{
+			DB:                    a.db,
+			GoogleCredentialsJSON: a.googleServiceAccountJSON,
+			GoogleBucketName:      a.googleBucketName,
+		},
+	}
+}
+
+func (a *Activities) HTTPAttach(router *mux.Router) error {
+	// the storage client is shared by all handlers for the life of the server
+	storageClient, err := storage.NewClient(
+		context.Background(),
+		option.WithCredentialsJSON([]byte(a.googleServiceAccountJSON)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create google storage client: %v", err)
+	}
+	bucket := storageClient.Bucket(a.googleBucketName)
+
+	router.HandleFunc(
+		"/{id}/convert/{format}",
+		handlers.BuildConvertHandler(a.db, bucket, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/map.{format}",
+		handlers.BuildMapHandler(a.db, bucket, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/heatmap/{layer}/{z}/{x}/{y}.png",
+		handlers.BuildHeatmapHandler(bucket),
+	).Methods("GET")
+	router.HandleFunc(
+		"/explorer",
+		handlers.BuildExplorerHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/explorer.geojson",
+		handlers.BuildExplorerGeoJSONHandler(a.db, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/explorer",
+		handlers.BuildActivityExplorerHandler(a.db, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/zones",
+		handlers.BuildZoneTotalsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/zones",
+		handlers.BuildActivityZonesHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/power-curve",
+		handlers.BuildPowerCurveHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/power-curve/seasons",
+		handlers.BuildPowerCurveSeasonsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/power-curve",
+		handlers.BuildActivityPowerCurveHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/best-efforts",
+		handlers.BuildBestEffortsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/best-efforts/seasons",
+		handlers.BuildBestEffortsSeasonsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/best-efforts",
+		handlers.BuildActivityBestEffortsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/training-load",
+		handlers.BuildTrainingLoadHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/training-load",
+		handlers.BuildActivityTrainingLoadHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/records",
+		handlers.BuildPersonalRecordsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/records",
+		handlers.BuildActivityPersonalRecordsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/events",
+		handlers.BuildEventsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/aggregates/{period}",
+		handlers.BuildAggregatesHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/gear",
+		handlers.BuildGearHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/gear/components",
+		handlers.BuildGearComponentsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/",
+		handlers.BuildActivitiesHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/tags",
+		handlers.BuildTagsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/annotations",
+		handlers.BuildActivityAnnotationsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/tags/{tag}",
+		handlers.BuildAddTagHandler(a.db),
+	).Methods("PUT")
+	router.HandleFunc(
+		"/{id}/tags/{tag}",
+		handlers.BuildRemoveTagHandler(a.db),
+	).Methods("DELETE")
+	router.HandleFunc(
+		"/{id}/notes",
+		handlers.BuildAddNoteHandler(a.db),
+	).Methods("POST")
+	router.HandleFunc(
+		"/{id}/notes/{note_id}",
+		handlers.BuildRemoveNoteHandler(a.db),
+	).Methods("DELETE")
+	router.HandleFunc(
+		"/{id}/annotations/{key}",
+		handlers.BuildSetAnnotationHandler(a.db),
+	).Methods("PUT")
+	router.HandleFunc(
+		"/{id}/annotations/{key}",
+		handlers.BuildRemoveAnnotationHandler(a.db),
+	).Methods("DELETE")
+	router.HandleFunc(
+		"/duplicates",
+		handlers.BuildDuplicatesHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/{id}/canonical",
+		handlers.BuildMarkCanonicalHandler(a.db),
+	).Methods("PUT")
+	router.HandleFunc(
+		"/{id}/not-duplicate",
+		handlers.BuildSetNotDuplicateHandler(a.db),
+	).Methods("PUT", "DELETE")
+	router.HandleFunc(
+		"/routes",
+		handlers.BuildRoutesHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/routes/{route_id}",
+		handlers.BuildRouteHandler(a.db, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/segments",
+		handlers.BuildSegmentsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/segments",
+		handlers.BuildCreateSegmentHandler(a.db),
+	).Methods("POST")
+	router.HandleFunc(
+		"/segments/{segment_id}",
+		handlers.BuildSegmentHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/segments/{segment_id}",
+		handlers.BuildDeleteSegmentHandler(a.db),
+	).Methods("DELETE")
+	router.HandleFunc(
+		"/{id}/segments",
+		handlers.BuildActivitySegmentEffortsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/strava-segments",
+		handlers.BuildStravaSegmentsHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/strava-segments/{segment_id}",
+		handlers.BuildStravaSegmentHandler(a.db),
+	).Methods("GET")
+	router.HandleFunc(
+		"/search/near",
+		handlers.BuildSearchNearHandler(a.db, a.privacy),
+	).Methods("GET")
+	router.HandleFunc(
+		"/search/bbox",
+		handlers.BuildSearchBBoxHandler(a.db, a.privacy),
+	).Methods("GET")
+
+	return nil
+}
+
 func (a *Activities) HTTPHost() string                                       { return "" }
-func (a *Activities) HTTPPath() string                                       { return "" }
+func (a *Activities) HTTPPath() string                                       { return "activities" }
 func (a *Activities) ExternalJobsFuncSet(f func(job apis.ExternalJob) error) {}
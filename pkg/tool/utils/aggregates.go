@@ -0,0 +1,38 @@
+package utils
+
+import "time"
+
+// Periods of activity aggregates
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+// AggregatePeriods are the periods activities are totalled over
+var AggregatePeriods = []string{PeriodWeek, PeriodMonth, PeriodYear}
+
+// ValidAggregatePeriod returns true for the periods in AggregatePeriods
+func ValidAggregatePeriod(period string) bool {
+	for _, p := range AggregatePeriods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// PeriodStart returns the date the period containing the local time starts
+// on, weeks start on Monday to match date_trunc in the database
+func PeriodStart(period string, local time.Time) time.Time {
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case PeriodMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// ErrActivityNotFound is returned when annotating an activity which is not
+// in the archive
+var ErrActivityNotFound = errors.New("activity not found")
+
+// ErrNotFound is returned when removing a note which does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalid is wrapped by errors for tags, keys, notes and values which
+// can't be stored
+var ErrInvalid = errors.New("invalid")
+
+// tagPattern limits tags and annotation keys to simple labels so they can
+// be used in query strings and joined with commas
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9 _-]{0,63}$`)
+
+// maxNoteLength limits the size of notes and annotation values
+const maxNoteLength = 10000
+
+// NormaliseTag returns the tag or annotation key in lower case without
+// surrounding space, and an error when it is not a valid label
+func NormaliseTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("%w label: must be up to 64 letters, numbers, spaces, dashes or underscores", ErrInvalid)
+	}
+	return tag, nil
+}
+
+// AddTag adds a tag to an activity, adding a tag twice has no effect
+func AddTag(ctx context.Context, db *sql.DB, id, tag string) error {
+	goquDB := goqu.New("postgres", db)
+
+	err := checkActivity(ctx, goquDB, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = goquDB.Insert("activities.activity_tags").
+		Rows(goqu.Record{"activity_id": id, "tag": tag}).
+		OnConflict(goqu.DoNothing()).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to add tag to %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// RemoveTag removes a tag from an activity
+func RemoveTag(ctx context.Context, db *sql.DB, id, tag string) error {
+	_, err := goqu.New("postgres", db).
+		Delete("activities.activity_tags").
+		Where(goqu.C("activity_id").Eq(id), goqu.C("tag").Eq(tag)).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag from %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// AddNote adds a note to an activity and returns its ID
+func AddNote(ctx context.Context, db *sql.DB, id, body string) (int64, error) {
+	goquDB := goqu.New("postgres", db)
+
+	body = strings.TrimSpace(body)
+	if body == "" || len(body) > maxNoteLength {
+		return 0, fmt.Errorf("%w note: must be between 1 and %d characters", ErrInvalid, maxNoteLength)
+	}
+
+	err := checkActivity(ctx, goquDB, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var noteID int64
+	_, err = goquDB.Insert("activities.activity_notes").
+		Rows(goqu.Record{"activity_id": id, "body": body}).
+		Returning("id").
+		Executor().ScanValContext(ctx, &noteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add note to %s: %w", id, err)
+	}
+
+	return noteID, nil
+}
+
+// RemoveNote removes a note from an activity, ErrNotFound is returned when
+// the activity has no such note
+func RemoveNote(ctx context.Context, db *sql.DB, id string, noteID int64) error {
+	res, err := goqu.New("postgres", db).
+		Delete("activities.activity_notes").
+		Where(goqu.C("activity_id").Eq(id), goqu.C("id").Eq(noteID)).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove note from %s: %w", id, err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get removed note count: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetAnnotation sets the value of an annotation on an activity, replacing
+// any value it had
+func SetAnnotation(ctx context.Context, db *sql.DB, id, key, value string) error {
+	goquDB := goqu.New("postgres", db)
+
+	value = strings.TrimSpace(value)
+	if len(value) > maxNoteLength {
+		return fmt.Errorf("%w value: must be at most %d characters", ErrInvalid, maxNoteLength)
+	}
+
+	err := checkActivity(ctx, goquDB, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = goquDB.Insert("activities.activity_annotations").
+		Rows(goqu.Record{
+			"activity_id": id,
+			"key":         key,
+			"value":       value,
+			"updated_at":  time.Now(),
+		}).
+		OnConflict(goqu.DoUpdate("activity_id, key", goqu.Record{
+			"value":      goqu.L("EXCLUDED.value"),
+			"updated_at": goqu.L("EXCLUDED.updated_at"),
+		})).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to set annotation on %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// RemoveAnnotation removes an annotation from an activity
+func RemoveAnnotation(ctx context.Context, db *sql.DB, id, key string) error {
+	_, err := goqu.New("postgres", db).
+		Delete("activities.activity_annotations").
+		Where(goqu.C("activity_id").Eq(id), goqu.C("key").Eq(key)).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove annotation from %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func checkActivity(ctx context.Context, goquDB *goqu.Database, id string) error {
+	var count int
+	_, err := goquDB.Select(goqu.COUNT("*")).
+		From("activities.activities").
+		Where(goqu.C("id").Eq(id)).
+		ScanValContext(ctx, &count)
+	if err != nil {
+		return fmt.Errorf("failed to check activity %s: %w", id, err)
+	}
+	if count == 0 {
+		return ErrActivityNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// ErrNoOriginal is returned when an activity has no usable original file
+var ErrNoOriginal = errors.New("activity has no original")
+
+// OriginalFormat returns the format of the original file archived for an
+// activity
+func OriginalFormat(ctx context.Context, db *sql.DB, id string) (string, error) {
+	var originalFormat string
+
+	goquDB := goqu.New("postgres", db)
+	found, err := goquDB.Select("original_format").
+		From("activities.activities").
+		Where(goqu.C("id").Eq(id)).
+		Executor().
+		ScanValContext(ctx, &originalFormat)
+	if err != nil {
+		return "", fmt.Errorf("failed to get original format for %s: %w", id, err)
+	}
+	if !found {
+		return "", fmt.Errorf("activity %s not found: %w", id, ErrNoOriginal)
+	}
+
+	switch originalFormat {
+	case format.FIT, format.GPX, format.TCX:
+		return originalFormat, nil
+	}
+
+	return "", fmt.Errorf("activity %s original format is %q: %w", id, originalFormat, ErrNoOriginal)
+}
+
+// ReadTrack reads and decodes an activity's original into a Track
+func ReadTrack(ctx context.Context, db *sql.DB, bucket *storage.BucketHandle, id string) (*track.Track, error) {
+	originalFormat, err := OriginalFormat(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ReadOriginal(ctx, bucket, id, originalFormat)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("activity %s original not found: %w", id, ErrNoOriginal)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return track.Decode(data, originalFormat)
+}
+
+// ConvertOriginal converts an activity's original to the target format with
+// the privacy policy applied. FIT originals requested as FIT are returned as
+// is when the policy is not enabled, a nil policy leaves all points in place.
+func ConvertOriginal(ctx context.Context, db *sql.DB, bucket *storage.BucketHandle, policy *privacy.Policy, id, targetFormat string) ([]byte, error) {
+	if _, ok := track.ContentTypes[targetFormat]; !ok {
+		return nil, fmt.Errorf("unsupported format: %s", targetFormat)
+	}
+
+	originalFormat, err := OriginalFormat(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ReadOriginal(ctx, bucket, id, originalFormat)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("activity %s original not found: %w", id, ErrNoOriginal)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if originalFormat == targetFormat && targetFormat == format.FIT && !policy.Enabled() {
+		return data, nil
+	}
+
+	t, err := track.Decode(data, originalFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return track.Encode(policy.Track(t, id), targetFormat)
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// MarkCanonical marks the other activities in the duplicate group of an
+// activity as duplicates of it, so only it is counted in totals.
+// ErrNotFound is returned when the activity is not in a duplicate group.
+func MarkCanonical(ctx context.Context, db *sql.DB, id string) error {
+	goquDB := goqu.New("postgres", db)
+
+	var groupID string
+	found, err := goquDB.Select("group_id").
+		From("activities.duplicate_candidates").
+		Where(goqu.C("activity_id").Eq(id)).
+		ScanValContext(ctx, &groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get duplicate group of %s: %w", id, err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s is not in a duplicate group", ErrNotFound, id)
+	}
+
+	members := goquDB.Select("activity_id").
+		From("activities.duplicate_candidates").
+		Where(goqu.C("group_id").Eq(groupID))
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return tx.Wrap(func() error {
+		_, err := tx.Update("activities.activities").
+			Where(goqu.C("id").Eq(id)).
+			Set(goqu.Record{"duplicate_of": nil}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to mark %s as canonical: %w", id, err)
+		}
+
+		_, err = tx.Update("activities.activities").
+			Where(
+				goqu.C("id").In(members),
+				goqu.C("id").Neq(id),
+			).
+			Set(goqu.Record{"duplicate_of": id}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to mark duplicates of %s: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// SetNotDuplicate sets whether an activity was wrongly detected as a
+// duplicate. Activities which are not duplicates are counted in totals and
+// left out of duplicate detection.
+func SetNotDuplicate(ctx context.Context, db *sql.DB, id string, notDuplicate bool) error {
+	goquDB := goqu.New("postgres", db)
+
+	err := checkActivity(ctx, goquDB, id)
+	if err != nil {
+		return err
+	}
+
+	record := goqu.Record{"not_duplicate": notDuplicate}
+	if notDuplicate {
+		record["duplicate_of"] = nil
+	}
+
+	_, err = goquDB.Update("activities.activities").
+		Where(goqu.C("id").Eq(id)).
+		Set(record).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to set not duplicate on %s: %w", id, err)
+	}
+
+	return nil
+}
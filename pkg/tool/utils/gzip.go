@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Gzip compresses data, setting name as the file name in the gzip header.
+func Gzip(name string, data []byte) ([]byte, error) {
+	var compressedBuf bytes.Buffer
+	zw := gzip.NewWriter(&compressedBuf)
+	zw.Name = name
+
+	_, err := zw.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return compressedBuf.Bytes(), nil
+}
+
+// Gunzip decompresses gzipped data.
+func Gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer zr.Close()
+
+	rawBytes, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+
+	return rawBytes, nil
+}
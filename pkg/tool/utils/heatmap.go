@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/tiles"
+)
+
+// HeatmapMinZoom and HeatmapMaxZoom are the range of zoom levels rendered
+// for the heatmap, the tile index is kept at HeatmapMaxZoom
+const (
+	HeatmapMinZoom = 6
+	HeatmapMaxZoom = 14
+)
+
+// HeatmapAllLayer is the heatmap layer which includes every activity type
+const HeatmapAllLayer = "all"
+
+var heatmapLayerPattern = regexp.MustCompile(`^[a-z0-9]+(-[0-9]{4})?$`)
+
+// HeatmapObjectName returns the bucket object name for a heatmap tile
+func HeatmapObjectName(layer string, t tiles.Tile) string {
+	return fmt.Sprintf("activities/heatmap/%s/%d/%d/%d.png", layer, t.Z, t.X, t.Y)
+}
+
+// HeatmapLayers returns the layers an activity is drawn into. Each activity
+// appears in the all layer and the layer for its type, and in the year
+// variant of both, e.g. run and run-2023.
+func HeatmapLayers(activityType string, year int) []string {
+	typeLayer := HeatmapTypeLayer(activityType)
+
+	return []string{
+		HeatmapAllLayer,
+		fmt.Sprintf("%s-%d", HeatmapAllLayer, year),
+		typeLayer,
+		fmt.Sprintf("%s-%d", typeLayer, year),
+	}
+}
+
+// HeatmapTypeLayer returns the layer name for an activity type, which is
+// the lowercase type without punctuation
+func HeatmapTypeLayer(activityType string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(activityType) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "other"
+	}
+	return b.String()
+}
+
+// ValidHeatmapLayer returns true if the layer name could have been
+// returned by HeatmapLayers
+func ValidHeatmapLayer(layer string) bool {
+	return heatmapLayerPattern.MatchString(layer)
+}
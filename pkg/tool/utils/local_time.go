@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	strava "github.com/strava/go.strava"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/fit"
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	internalStrava "github.com/charlieegan3/tool-activities/internal/pkg/strava"
+)
+
+// LocalTimeFromData returns the local start time columns of an activity
+// from its Strava data
+func LocalTimeFromData(activity *strava.ActivityDetailed) goqu.Record {
+	// Strava returns the local start as a UTC time with the local clock
+	// reading, so the difference is the offset
+	offset := activity.StartDateLocal.Sub(activity.StartDate)
+
+	return goqu.Record{
+		"start_date_local": activity.StartDateLocal,
+		"timezone":         internalStrava.TimeZoneName(activity.TimeZone),
+		"utc_offset":       int(offset / time.Second),
+	}
+}
+
+// LocalTimeFromOriginal returns the local start time columns of an activity
+// from its original, which is only possible for FIT files as the other
+// formats only record UTC times. The timezone is left empty as only the
+// offset is known.
+func LocalTimeFromOriginal(data []byte, originalFormat string) (goqu.Record, bool) {
+	if originalFormat != format.FIT {
+		return nil, false
+	}
+
+	f, err := fit.Decode(data)
+	if err != nil {
+		return nil, false
+	}
+
+	offset, ok := f.UTCOffset()
+	if !ok {
+		return nil, false
+	}
+	start, ok := f.StartTime()
+	if !ok {
+		return nil, false
+	}
+
+	local := start.Add(offset).UTC()
+
+	return goqu.Record{
+		"start_date_local": local,
+		"timezone":         "",
+		"utc_offset":       int(offset / time.Second),
+	}, true
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/privacy"
+	"github.com/charlieegan3/tool-activities/internal/pkg/routemap"
+	"github.com/charlieegan3/tool-activities/pkg/tool/queries"
+)
+
+// ErrNoRoute is returned when an activity has no route geometry to draw
+var ErrNoRoute = errors.New("activity has no route")
+
+// MapContentTypes are the content types of the route map formats
+var MapContentTypes = map[string]string{
+	"svg": "image/svg+xml",
+	"png": "image/png",
+}
+
+// MapObjectName returns the bucket object name for an activity's route map
+func MapObjectName(id, format string) string {
+	return fmt.Sprintf("activities/maps/%s.%s", id, format)
+}
+
+// ReadRouteMap reads an activity's route map from the bucket, rendering and
+// storing the maps first if they have not been rendered yet or were rendered
+// from an old route or privacy policy.
+func ReadRouteMap(ctx context.Context, db *sql.DB, bucket *storage.BucketHandle, policy *privacy.Policy, id, format string) ([]byte, error) {
+	if _, ok := MapContentTypes[format]; !ok {
+		return nil, fmt.Errorf("unsupported map format: %s", format)
+	}
+
+	var current bool
+	_, err := goqu.New("postgres", db).
+		Select(goqu.L("m.data_digest = r.data_digest AND m.privacy_digest = ?", policy.Digest())).
+		From(goqu.T("route_maps").Schema("activities").As("m")).
+		Join(
+			goqu.T("routes").Schema("activities").As("r"),
+			goqu.On(goqu.I("r.activity_id").Eq(goqu.I("m.activity_id"))),
+		).
+		Where(goqu.I("m.activity_id").Eq(id)).
+		Executor().
+		ScanValContext(ctx, &current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route map for %s: %w", id, err)
+	}
+
+	render := func() ([]byte, error) {
+		maps, err := StoreRouteMaps(ctx, db, bucket, policy, id)
+		if err != nil {
+			return nil, err
+		}
+		return maps[format], nil
+	}
+
+	if !current {
+		return render()
+	}
+
+	r, err := bucket.Object(MapObjectName(id, format)).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return render()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reader for map %s: %w", id, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map %s: %w", id, err)
+	}
+
+	return data, nil
+}
+
+// StoreRouteMaps renders the route map for an activity in each format with
+// the privacy policy applied, writes them to the bucket and records the
+// route and policy they were rendered from. The rendered maps are returned
+// by format.
+func StoreRouteMaps(ctx context.Context, db *sql.DB, bucket *storage.BucketHandle, policy *privacy.Policy, id string) (map[string][]byte, error) {
+	goquDB := goqu.New("postgres", db)
+
+	var route struct {
+		DataDigest string `db:"data_digest"`
+		Geometry   []byte `db:"geometry"`
+	}
+	found, err := goquDB.Select("data_digest", "geometry").
+		From("activities.routes").
+		Where(goqu.C("activity_id").Eq(id)).
+		Executor().
+		ScanStructContext(ctx, &route)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route for %s: %w", id, err)
+	}
+	if !found || route.Geometry == nil {
+		return nil, fmt.Errorf("activity %s: %w", id, ErrNoRoute)
+	}
+
+	points, err := queries.ParseLineString(route.Geometry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse geometry for %s: %w", id, err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("activity %s: %w", id, ErrNoRoute)
+	}
+
+	// routes entirely inside the privacy zones are drawn as an empty map
+	lines := policy.Line(points, id)
+
+	pngData, err := routemap.PNG(lines, routemap.DefaultOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render map for %s: %w", id, err)
+	}
+	maps := map[string][]byte{
+		"svg": routemap.SVG(lines, routemap.DefaultOptions),
+		"png": pngData,
+	}
+
+	for format, data := range maps {
+		w := bucket.Object(MapObjectName(id, format)).NewWriter(ctx)
+		w.ContentType = MapContentTypes[format]
+
+		_, err = io.Copy(w, bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to write map to google storage: %w", err)
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to close google storage writer: %w", err)
+		}
+	}
+
+	_, err = goquDB.Insert("activities.route_maps").
+		Rows(goqu.Record{
+			"activity_id":    id,
+			"data_digest":    route.DataDigest,
+			"privacy_digest": policy.Digest(),
+			"updated_at":     time.Now(),
+		}).
+		OnConflict(goqu.DoUpdate("activity_id", goqu.Record{
+			"data_digest":    goqu.L("EXCLUDED.data_digest"),
+			"privacy_digest": goqu.L("EXCLUDED.privacy_digest"),
+			"updated_at":     goqu.L("EXCLUDED.updated_at"),
+		})).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert route map for %s: %w", id, err)
+	}
+
+	return maps, nil
+}
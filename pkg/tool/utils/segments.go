@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/format"
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/segments"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// SegmentLineFromGPX returns the positions of the track in a GPX file
+func SegmentLineFromGPX(data []byte) ([]geo.Point, error) {
+	t, err := track.Decode(data, format.GPX)
+	if err != nil {
+		return nil, fmt.Errorf("%w gpx: %s", ErrInvalid, err)
+	}
+
+	var line []geo.Point
+	for _, p := range t.Points {
+		if p.Position != nil {
+			line = append(line, *p.Position)
+		}
+	}
+
+	return line, nil
+}
+
+// SegmentLineFromActivity returns the positions of an activity's track
+// between the points closest to start and end
+func SegmentLineFromActivity(ctx context.Context, db *sql.DB, id string, start, end geo.Point) ([]geo.Point, error) {
+	goquDB := goqu.New("postgres", db)
+
+	err := checkActivity(ctx, goquDB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := LoadTrackPoints(ctx, goquDB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var line []geo.Point
+	for _, p := range points {
+		if p.Position != nil {
+			line = append(line, *p.Position)
+		}
+	}
+
+	section, ok := segments.Section(line, start, end)
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w segment: the activity does not pass within %.0fm of the start and then the end",
+			ErrInvalid,
+			segments.EndpointRadius,
+		)
+	}
+
+	return section, nil
+}
+
+// CreateSegment stores a segment following the line and returns its ID.
+// activityID is the activity the line was taken from, or empty. Efforts
+// are found by the segment matching job.
+func CreateSegment(ctx context.Context, db *sql.DB, name string, line []geo.Point, activityID string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return 0, fmt.Errorf("%w name: must be between 1 and 255 characters", ErrInvalid)
+	}
+
+	segment, ok := segments.NewSegment(line)
+	if !ok {
+		return 0, fmt.Errorf("%w segment: must be at least %.0fm long", ErrInvalid, segments.MinLength)
+	}
+
+	coordinates := make([][2]float64, len(segment.Points))
+	for i, p := range segment.Points {
+		coordinates[i] = [2]float64{p.Lon, p.Lat}
+	}
+	geometry, err := json.Marshal(map[string]any{
+		"type":        "LineString",
+		"coordinates": coordinates,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal segment geometry: %w", err)
+	}
+
+	record := goqu.Record{
+		"name":        name,
+		"activity_id": nil,
+		"geometry":    string(geometry),
+		"length":      segment.Length,
+		"min_lat":     segment.Bounds.MinLat,
+		"min_lon":     segment.Bounds.MinLon,
+		"max_lat":     segment.Bounds.MaxLat,
+		"max_lon":     segment.Bounds.MaxLon,
+	}
+	if activityID != "" {
+		record["activity_id"] = activityID
+	}
+
+	var id int64
+	_, err = goqu.New("postgres", db).
+		Insert("activities.segments").
+		Rows(record).
+		Returning("id").
+		Executor().ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteSegment deletes a segment and its efforts, ErrNotFound is returned
+// when there is no such segment
+func DeleteSegment(ctx context.Context, db *sql.DB, id int64) error {
+	res, err := goqu.New("postgres", db).
+		Delete("activities.segments").
+		Where(goqu.C("id").Eq(id)).
+		Executor().ExecContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete segment %d: %w", id, err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get deleted segment count: %w", err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
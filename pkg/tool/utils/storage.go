@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	strava "github.com/strava/go.strava"
+)
+
+// OriginalObjectName returns the bucket object name for an activity's
+// original file.
+func OriginalObjectName(id, format string) string {
+	return fmt.Sprintf("activities/original/%s.%s.gz", id, format)
+}
+
+// ReadOriginal reads and decompresses an activity's original file from the
+// bucket.
+func ReadOriginal(ctx context.Context, bucket *storage.BucketHandle, id, format string) ([]byte, error) {
+	r, err := bucket.Object(OriginalObjectName(id, format)).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reader for original %s: %w", id, err)
+	}
+	defer r.Close()
+
+	compressedBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original %s: %w", id, err)
+	}
+
+	return Gunzip(compressedBytes)
+}
+
+// ActivityDataObjectName returns the bucket object name for an activity's
+// Strava data.
+func ActivityDataObjectName(id string) string {
+	return fmt.Sprintf("activities/activities/%s.json.gz", id)
+}
+
+// ReadActivityData reads and decodes the Strava data archived for an
+// activity by ActivitySync.
+func ReadActivityData(ctx context.Context, bucket *storage.BucketHandle, id string) (*strava.ActivityDetailed, error) {
+	r, err := bucket.Object(ActivityDataObjectName(id)).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reader for activity data %s: %w", id, err)
+	}
+	defer r.Close()
+
+	compressedBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity data %s: %w", id, err)
+	}
+
+	data, err := Gunzip(compressedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var activity strava.ActivityDetailed
+	err = json.Unmarshal(data, &activity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal activity data %s: %w", id, err)
+	}
+
+	return &activity, nil
+}
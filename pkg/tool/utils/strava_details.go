@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	strava "github.com/strava/go.strava"
+)
+
+// StoreStravaDetails replaces the segment efforts, splits and best efforts
+// of an activity with those in its Strava data, recording digest as the
+// version of the data they were taken from. Segments are updated with the
+// details seen in the data.
+func StoreStravaDetails(ctx context.Context, goquDB *goqu.Database, id, digest string, activity *strava.ActivityDetailed) error {
+	segments := make(map[int64]goqu.Record)
+	var efforts []goqu.Record
+	for _, e := range activity.SegmentEfforts {
+		if e == nil {
+			continue
+		}
+
+		s := e.Segment
+		segments[s.Id] = goqu.Record{
+			"id":             s.Id,
+			"name":           s.Name,
+			"activity_type":  string(s.ActivityType),
+			"distance":       s.Distance,
+			"average_grade":  s.AverageGrade,
+			"maximum_grade":  s.MaximumGrade,
+			"elevation_high": s.ElevationHigh,
+			"elevation_low":  s.ElevationLow,
+			"climb_category": int(s.ClimbCategory),
+			"start_lat":      locationPart(s.StartLocation, 0),
+			"start_lon":      locationPart(s.StartLocation, 1),
+			"end_lat":        locationPart(s.EndLocation, 0),
+			"end_lon":        locationPart(s.EndLocation, 1),
+			"city":           s.City,
+			"state":          s.State,
+			"country":        s.Country,
+			"private":        s.Private,
+			"updated_at":     time.Now(),
+		}
+
+		record := effortRecord(id, e.EffortSummary)
+		record["segment_id"] = s.Id
+		record["average_cadence"] = nonZero(e.AverageCadence)
+		record["average_power"] = nonZero(e.AveragePower)
+		record["average_heart_rate"] = nonZero(e.AverageHeartrate)
+		record["max_heart_rate"] = nonZero(e.MaximumHeartrate)
+		record["kom_rank"] = nonZeroInt(e.KOMRank)
+		record["pr_rank"] = nonZeroInt(e.PRRank)
+		record["hidden"] = e.Hidden
+		efforts = append(efforts, record)
+	}
+
+	var splits []goqu.Record
+	for units, list := range map[string][]*strava.Split{
+		"metric":   activity.SplitsMetric,
+		"standard": activity.SplitsStandard,
+	} {
+		for _, s := range list {
+			if s == nil {
+				continue
+			}
+			splits = append(splits, goqu.Record{
+				"activity_id":          id,
+				"units":                units,
+				"split":                s.Split,
+				"distance":             s.Distance,
+				"elapsed_time":         s.ElapsedTime,
+				"moving_time":          s.MovingTime,
+				"elevation_difference": s.ElevationDifference,
+			})
+		}
+	}
+
+	var bestEfforts []goqu.Record
+	for _, e := range activity.BestEfforts {
+		if e == nil {
+			continue
+		}
+		record := effortRecord(id, e.EffortSummary)
+		record["pr_rank"] = nonZeroInt(e.PRRank)
+		bestEfforts = append(bestEfforts, record)
+	}
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	return tx.Wrap(func() error {
+		_, err := tx.Insert("activities.strava_details").
+			Rows(goqu.Record{
+				"activity_id": id,
+				"data_digest": digest,
+				"updated_at":  time.Now(),
+			}).
+			OnConflict(goqu.DoUpdate("activity_id", goqu.Record{
+				"data_digest": goqu.L("EXCLUDED.data_digest"),
+				"updated_at":  goqu.L("EXCLUDED.updated_at"),
+			})).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to upsert strava details for %s: %w", id, err)
+		}
+
+		for _, table := range []string{"strava_segment_efforts", "strava_splits", "strava_best_efforts"} {
+			_, err = tx.Delete("activities." + table).
+				Where(goqu.C("activity_id").Eq(id)).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s for %s: %w", table, id, err)
+			}
+		}
+
+		for segmentID, record := range segments {
+			updates := goqu.Record{}
+			for k := range record {
+				if k != "id" {
+					updates[k] = goqu.L("EXCLUDED." + k)
+				}
+			}
+			_, err = tx.Insert("activities.strava_segments").
+				Rows(record).
+				OnConflict(goqu.DoUpdate("id", updates)).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to upsert strava segment %d: %w", segmentID, err)
+			}
+		}
+
+		for table, rows := range map[string][]goqu.Record{
+			"strava_segment_efforts": efforts,
+			"strava_splits":          splits,
+			"strava_best_efforts":    bestEfforts,
+		} {
+			if len(rows) == 0 {
+				continue
+			}
+			_, err = tx.Insert("activities." + table).
+				Rows(rows).
+				Executor().ExecContext(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to insert %s for %s: %w", table, id, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// StoreStravaLaps replaces the laps of an activity, recording digest as the
+// version of the Strava data they were requested for. The activity's
+// details must have been stored first.
+func StoreStravaLaps(ctx context.Context, goquDB *goqu.Database, id, digest string, laps []*strava.LapEffortSummary) error {
+	var records []goqu.Record
+	for _, l := range laps {
+		if l == nil {
+			continue
+		}
+		record := effortRecord(id, l.EffortSummary)
+		record["lap_index"] = l.LapIndex
+		record["total_elevation_gain"] = l.TotalElevationGain
+		record["average_speed"] = nonZero(l.AverageSpeed)
+		record["max_speed"] = nonZero(l.MaximunSpeed)
+		record["average_cadence"] = nonZero(l.AverageCadence)
+		record["average_power"] = nonZero(l.AveragePower)
+		record["average_heart_rate"] = nonZero(l.AverageHeartrate)
+		record["max_heart_rate"] = nonZero(l.MaximumHeartrate)
+		records = append(records, record)
+	}
+
+	tx, err := goquDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	return tx.Wrap(func() error {
+		_, err := tx.Update("activities.strava_details").
+			Where(goqu.C("activity_id").Eq(id)).
+			Set(goqu.Record{"laps_digest": digest}).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update laps digest for %s: %w", id, err)
+		}
+
+		_, err = tx.Delete("activities.strava_laps").
+			Where(goqu.C("activity_id").Eq(id)).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete strava laps for %s: %w", id, err)
+		}
+
+		if len(records) == 0 {
+			return nil
+		}
+
+		_, err = tx.Insert("activities.strava_laps").
+			Rows(records).
+			Executor().ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to insert strava laps for %s: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// effortRecord returns the columns common to segment efforts, laps and best
+// efforts
+func effortRecord(id string, e strava.EffortSummary) goqu.Record {
+	return goqu.Record{
+		"id":               e.Id,
+		"activity_id":      id,
+		"name":             e.Name,
+		"start_date":       e.StartDate,
+		"start_date_local": e.StartDateLocal,
+		"elapsed_time":     e.ElapsedTime,
+		"moving_time":      e.MovingTime,
+		"distance":         e.Distance,
+		"start_index":      e.StartIndex,
+		"end_index":        e.EndIndex,
+	}
+}
+
+// nonZero returns nil for zero values, which Strava's data uses for values
+// it did not return
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// nonZeroInt is nonZero for integer values such as ranks
+func nonZeroInt(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// locationPart returns a coordinate of a location, nil when the location is
+// missing
+func locationPart(l strava.Location, i int) *float64 {
+	if l == (strava.Location{}) {
+		return nil
+	}
+	return &l[i]
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/charlieegan3/tool-activities/internal/pkg/geo"
+	"github.com/charlieegan3/tool-activities/internal/pkg/track"
+)
+
+// LoadTrackPoints reads the points stored for an activity by the track
+// points job, in order
+func LoadTrackPoints(ctx context.Context, goquDB *goqu.Database, id string) ([]track.Point, error) {
+	var rows []struct {
+		Time        time.Time `db:"time"`
+		Lat         *float64  `db:"lat"`
+		Lon         *float64  `db:"lon"`
+		Elevation   *float64  `db:"elevation"`
+		HeartRate   *float64  `db:"heart_rate"`
+		Cadence     *float64  `db:"cadence"`
+		Power       *float64  `db:"power"`
+		Speed       *float64  `db:"speed"`
+		Distance    *float64  `db:"distance"`
+		Temperature *float64  `db:"temperature"`
+	}
+
+	err := goquDB.Select(
+		"time", "lat", "lon", "elevation", "heart_rate", "cadence",
+		"power", "speed", "distance", "temperature",
+	).
+		From("activities.track_points").
+		Where(goqu.C("activity_id").Eq(id)).
+		Order(goqu.C("point_offset").Asc()).
+		Executor().ScanStructsContext(ctx, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points for %s: %w", id, err)
+	}
+
+	points := make([]track.Point, len(rows))
+	for i, r := range rows {
+		points[i] = track.Point{
+			Time:        r.Time,
+			Elevation:   r.Elevation,
+			HeartRate:   r.HeartRate,
+			Cadence:     r.Cadence,
+			Power:       r.Power,
+			Speed:       r.Speed,
+			Distance:    r.Distance,
+			Temperature: r.Temperature,
+		}
+		if r.Lat != nil && r.Lon != nil {
+			points[i].Position = &geo.Point{Lat: *r.Lat, Lon: *r.Lon}
+		}
+	}
+
+	return points, nil
+}